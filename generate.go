@@ -0,0 +1,7 @@
+package main
+
+// The Go code of the gRPC API in internal/rpc/pb is generated from proto by buf, at the version below, with the
+// protoc-gen-go and protoc-gen-go-grpc versions of go.mod. buf compiles the definitions itself rather than protoc,
+// which is why the generated files report the protoc version as unknown.
+//
+//go:generate go run github.com/bufbuild/buf/cmd/buf@v1.65.0 generate
@@ -5,118 +5,371 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/maknahar/alpha-flow/internal/db"
+	"github.com/maknahar/alpha-flow/internal/logging"
+	"github.com/maknahar/alpha-flow/internal/models"
+	"github.com/maknahar/alpha-flow/internal/passwords"
+	"github.com/maknahar/alpha-flow/internal/utils"
 
 	"github.com/sirupsen/logrus"
 )
 
+// What is done with the migrations on start.
 const (
-	defaultTokenValidityDuration = time.Second * 3
+	// MigrateOnStartMigrate applies the pending migrations.
+	MigrateOnStartMigrate = "migrate"
+
+	// MigrateOnStartVerify fails to start unless every migration is applied, leaving them to the migrate command.
+	MigrateOnStartVerify = "verify"
+
+	// MigrateOnStartSkip leaves the migrations to the migrate command, the readiness check reporting pending ones.
+	MigrateOnStartSkip = "skip"
 )
 
+// Where the users, their subscriptions, the rates and the audit events are stored.
+const (
+	// StorageBackendPostgres stores them in the Postgres database of the DB_* settings.
+	StorageBackendPostgres = "postgres"
+
+	// StorageBackendSQLite stores them in a SQLite file, for single node deployments.
+	StorageBackendSQLite = "sqlite"
+
+	// StorageBackendMemory keeps them in memory until the service stops, for tests and local runs.
+	StorageBackendMemory = "memory"
+)
+
+const (
+	defaultTokenValidityDuration  = time.Hour
+	defaultMaxRequestBodyBytes    = 1 << 20
+	defaultGraphQLMaxDepth        = 8
+	defaultGraphQLMaxComplexity   = 1000
+	defaultReadinessCheckTimeout  = time.Second * 2
+	defaultShutdownGracePeriod    = time.Second * 5
+	defaultLogSampleInitial       = 100
+	defaultLogSampleThereafter    = 100
+	defaultDBPort                 = 5432
+	defaultDBMaxConn              = 25
+	defaultDBMaxIdleConn          = 5
+	defaultPasswordMinLength      = 8
+	defaultPasswordMaxLength      = 64
+	defaultDBCredentialsRefresh   = time.Minute
+	defaultTxMaxAttempts          = 3
+	defaultDBReplicaStickyWindow  = time.Second * 5
+	defaultDBReplicaCheckInterval = time.Second * 5
+	defaultDBReplicaMaxLag        = time.Second
+)
+
+// txIsolationLevels are the isolation levels of the tx_isolation setting.
+//
+//nolint:gochecknoglobals
+var txIsolationLevels = map[string]sql.IsolationLevel{
+	"read_committed":  sql.LevelReadCommitted,
+	"repeatable_read": sql.LevelRepeatableRead,
+	"serializable":    sql.LevelSerializable,
+}
+
 // Conf contains all the configuration required for the service to run and can be user for dependency ingestion.
 type Conf struct {
 	// Environment indicates the name of the environment the service will be running on. Default: Production.
 	Environment string
 
+	// Logger writes JSON entries at LOG_LEVEL, with secrets redacted. Default level: Info
 	Logger *logrus.Logger
 
+	// LogSampler thins out the logs written on every request: of those sharing a route within a second the first
+	// LOG_SAMPLE_INITIAL are kept, then one in every LOG_SAMPLE_THEREAFTER, none if 0. Default: 100 and 100
+	LogSampler *logging.Sampler
+
 	// Host represents the port on which this service will listen to. Default: :9001
 	Host string
 
-	// DB is a database handle representing a connection pool
+	// GRPCHost represents the port on which the gRPC API will listen to. Default: :9002
+	GRPCHost string
+
+	// DB is a database handle representing a connection pool to the database of the storage backend, Postgres or
+	// SQLite, nil for the memory one.
 	DB *sql.DB
 
-	// AccessTokenValidityDuration indicates the time for which a user token is valid for after a successful login. Default: 3s
-	AccessTokenValidityDuration time.Duration
+	// Database migrates DB when the storage backend is postgres, nil otherwise: the SQLite database is migrated as it
+	// is opened.
+	Database db.DB
+
+	// Replicas are the read replicas of DB_REPLICA_HOSTS, nil if none. The reads of the users are sent to the healthy
+	// ones, at most DB_REPLICA_MAX_LAG behind, those of a user written to within DB_REPLICA_STICKY_WINDOW going to DB.
+	// Default: none, 1s, 5s
+	Replicas *db.Replicas
+
+	// ReplicaCheckInterval is the time between the health checks of the replicas. Default: 5s
+	ReplicaCheckInterval time.Duration
+
+	// DSN provides the connection string of DB when it is Postgres, for the connections opened outside of its pool
+	// such as the one the migrations run on.
+	DSN db.DSN
+
+	// MigrateOnStart is what is done with the migrations on start: migrate, verify or skip. Migrations are never
+	// reverted nor the database dropped but by the migrate command. Default: migrate
+	MigrateOnStart string
+
+	// Users stores the users and their subscriptions in the configured backend: postgres, sqlite or memory. Default:
+	// postgres
+	Users models.UserModel
+
+	// UnitOfWork runs several operations on the users atomically, in the same backend as Users.
+	UnitOfWork models.UnitOfWork
+
+	// Rates stores the observed rates, in the same backend as Users.
+	Rates models.RateModel
+
+	// Audit stores the audit events, in the same backend as Users.
+	Audit models.AuditModel
+
+	// TxOptions are the options of the units of work of the services: the isolation level of TX_ISOLATION and the
+	// runs on serialization failures of TX_MAX_ATTEMPTS. Default: serializable, 3 runs
+	TxOptions models.TxOptions
+
+	// PasswordHasher hashes new passwords and verifies existing ones. Legacy pgcrypto hashes are verified as bcrypt.
+	PasswordHasher passwords.Hasher
+
+	// MaxRequestBodyBytes is the largest request body accepted. Default: 1048576 (1 MiB)
+	MaxRequestBodyBytes int64
+
+	// LegacyRoutes keeps the unversioned root routes as deprecated aliases of /v1. Default: true
+	LegacyRoutes bool
+
+	// LegacyRoutesSunset is announced in the Sunset header of the root aliases, if set. Format: RFC 3339
+	LegacyRoutesSunset time.Time
+
+	// GraphQLMaxDepth is the deepest nesting of fields a GraphQL query may have. Default: 8
+	GraphQLMaxDepth int
+
+	// GraphQLMaxComplexity is the highest cost a GraphQL query may have, counting every field once per item of the
+	// lists it is selected in. Default: 1000
+	GraphQLMaxComplexity int
+
+	// TracingExporter is where spans are sent to: none, stdout or otlp. Default: none
+	TracingExporter string
+
+	// TracingEndpoint is the address of the OTLP collector receiving spans over gRPC. Default: localhost:4317
+	TracingEndpoint string
+
+	// TracingSampleRatio is the share of new traces recorded, between 0 and 1. Traces continued from an incoming
+	// request are recorded if the caller recorded them. Default: 1
+	TracingSampleRatio float64
+
+	// ReadinessCheckTimeout is the time each readiness check is given to complete. Default: 2s
+	ReadinessCheckTimeout time.Duration
+
+	// ShutdownGracePeriod is the time the service keeps serving while reported as not ready before shutting down, so
+	// that it is taken out of rotation first. Default: 5s
+	ShutdownGracePeriod time.Duration
+
+	// AdminEmails are the emails of the users allowed to query the audit events, separated by commas in ADMIN_EMAILS.
+	// Default: none
+	AdminEmails []string
+
+	// AuditHashChain chains every new audit event to the previous one with a hash, so that changed or deleted events
+	// can be detected. Default: false
+	AuditHashChain bool
+
+	// runtime holds the current *Runtime, see Runtime and Reload.
+	runtime  atomic.Value
+	reloadMu sync.Mutex
+	settings *Settings
 }
 
-// Configure reads the env variables for service to start. Default values are set for optional env vars.
-func Configure(ctx context.Context) (conf *Conf, err error) {
-	logrus.SetFormatter(&logrus.JSONFormatter{})
+// Configure connects to the storage backend and builds the configuration of the service from settings, which Load has
+// validated.
+func Configure(ctx context.Context, settings *Settings) (conf *Conf, err error) {
+	logrus.SetFormatter(logging.Formatter())
+
+	// The level was validated along with the other settings.
+	logLevel, _ := logrus.ParseLevel(settings.LogLevel)
+
 	conf = &Conf{
-		Environment: getEnvOrSetDefault("ENVIRONMENT", "Local"),
-		Host:        getEnvOrSetDefault("HOST", ":9001"),
-		Logger:      logrus.New(),
+		Environment:           settings.Environment,
+		MigrateOnStart:        settings.MigrateOnStart,
+		Logger:                logging.New(logLevel),
+		LogSampler:            logging.NewSampler(settings.LogSampleInitial, settings.LogSampleThereafter),
+		Host:                  settings.Host,
+		GRPCHost:              settings.GRPCHost,
+		MaxRequestBodyBytes:   settings.MaxRequestBodyBytes,
+		LegacyRoutes:          settings.LegacyRoutes,
+		GraphQLMaxDepth:       settings.GraphQLMaxDepth,
+		GraphQLMaxComplexity:  settings.GraphQLMaxComplexity,
+		TracingExporter:       settings.TracingExporter,
+		TracingEndpoint:       settings.TracingEndpoint,
+		TracingSampleRatio:    settings.TracingSampleRatio,
+		ReadinessCheckTimeout: settings.ReadinessCheckTimeout,
+		ShutdownGracePeriod:   settings.ShutdownGracePeriod,
+		ReplicaCheckInterval:  settings.DBReplicaCheckInterval,
+		AdminEmails:           settings.AdminEmails,
+		AuditHashChain:        settings.AuditHashChain,
+		TxOptions: models.TxOptions{
+			Isolation:   txIsolationLevels[settings.TxIsolation],
+			MaxAttempts: settings.TxMaxAttempts,
+		},
+		settings: settings,
 	}
 
-	logLevel, err := logrus.ParseLevel(getEnvOrSetDefault("LOG_LEVEL", "Info"))
-	if err != nil {
-		logrus.Warn("Invalid value set for env var LOG_LEVEL. " +
-			"Valid options: Trace, Debug, Info, Warning, Error, Fatal and Panic, Defaulting to Info")
-
-		logLevel = logrus.InfoLevel
+	if settings.LegacyRoutesSunset != "" {
+		conf.LegacyRoutesSunset, _ = time.Parse(time.RFC3339, settings.LegacyRoutesSunset)
 	}
 
-	conf.Logger.Level = logLevel
-	conf.Logger.SetFormatter(&logrus.JSONFormatter{})
+	if err = configureStorage(ctx, settings, conf); err != nil {
+		return conf, err
+	}
 
-	dbURL := fmt.Sprintf("host=%s sslmode=disable", getEnvOrSetDefault("DB_HOST", "localhost"))
+	conf.PasswordHasher = configurePasswordHasher(settings)
 
-	if getEnvOrSetDefault("DB_USER", "") != "" {
-		dbURL += fmt.Sprintf(" user=%s", getEnvOrSetDefault("DB_USER", ""))
+	runtime, err := newRuntime(settings, conf.PasswordHasher)
+	if err != nil {
+		return conf, err
 	}
 
-	if getEnvOrSetDefault("DB_PASS", "") != "" {
-		dbURL += fmt.Sprintf(" password=%s", getEnvOrSetDefault("DB_PASS", ""))
-	}
+	conf.runtime.Store(runtime)
+
+	return conf, nil
+}
 
-	dbName := getEnvOrSetDefault("DB_NAME", "userapi")
+// configureStorage connects to the storage backend and builds the models stored in it. Postgres, along with its
+// replicas, is only connected to for the postgres backend. The SQLite database is created and migrated if needed.
+func configureStorage(ctx context.Context, settings *Settings, conf *Conf) (err error) {
+	switch settings.StorageBackend {
+	case StorageBackendSQLite:
+		conf.DB, err = db.OpenSQLite(ctx, settings.SQLitePath)
+		if err != nil {
+			return err
+		}
 
-	database := db.New(conf.Environment)
+		conf.Users, conf.UnitOfWork = models.NewSQLiteUser(conf.DB), models.NewSQLiteUnitOfWork(conf.DB)
+		conf.Rates, conf.Audit = models.NewSQLiteRate(conf.DB), models.NewSQLiteAudit(conf.DB)
+	case StorageBackendMemory:
+		conf.Users = models.NewMemoryUser()
 
-	//if conf.Environment == "local" {
-	//	err = database.Recreate(dbURL, dbName)
-	//	if err != nil {
-	//		return conf, err
-	//	}
-	//}
+		conf.UnitOfWork, err = models.NewMemoryUnitOfWork(conf.Users)
+		if err != nil {
+			return err
+		}
 
-	dbMaxConn, err := strconv.Atoi(getEnvOrSetDefault("DB_MAX_CONN", "25"))
-	if err != nil {
-		logrus.Warn("Invalid value set for env var DB_MAX_CONN. Valid options: Number; " +
-			"A rule of thumb is (Max DB connection-10)/max number of instance. Defaulting to 25")
+		conf.Rates, conf.Audit = models.NewMemoryRate(), models.NewMemoryAudit()
+	default:
+		postgres := &db.Postgres{}
+
+		conf.Database = postgres
+		conf.DSN = newDSN(settings, settings.DBHost)
+
+		conf.DB, err = postgres.Connect(ctx, conf.DSN, settings.DBMaxConn, settings.DBMaxIdleConn)
+		if err != nil {
+			return err
+		}
 
-		dbMaxConn = 25
+		var replication *models.Replication
+
+		if len(settings.DBReplicaHosts) > 0 {
+			dsns := make([]db.DSN, len(settings.DBReplicaHosts))
+			for i, host := range settings.DBReplicaHosts {
+				dsns[i] = newDSN(settings, host)
+			}
+
+			conf.Replicas = postgres.ConnectReplicas(ctx, dsns, settings.DBMaxConn, settings.DBMaxIdleConn,
+				settings.ReadinessCheckTimeout, settings.DBReplicaMaxLag)
+			replication = models.NewReplication(conf.Replicas, settings.DBReplicaStickyWindow)
+		}
+
+		conf.Users, conf.UnitOfWork = models.NewUser(conf.DB, replication), models.NewUnitOfWork(conf.DB, replication)
+		conf.Rates, conf.Audit = models.NewRate(conf.DB), models.NewAudit(conf.DB)
 	}
 
-	dbMaxIdleConn, err := strconv.Atoi(getEnvOrSetDefault("DB_MAX_IDLE_CONN", "5"))
-	if err != nil {
-		logrus.Warn("Invalid value set for env var DB_MAX_IDLE_CONN. " +
-			"Valid options: Number less than DB_MAX_CONN. Defaulting to 5")
+	return nil
+}
 
-		dbMaxConn = 5
+// configurePasswordHasher builds the hasher of the configured algorithm. Hashes of the other supported algorithms are
+// still verified and upgraded on the next login.
+func configurePasswordHasher(settings *Settings) passwords.Hasher {
+	argon2id := passwords.NewArgon2id(passwords.Argon2Params{
+		Memory:      uint32(settings.Argon2Memory),
+		Iterations:  uint32(settings.Argon2Iterations),
+		Parallelism: uint8(settings.Argon2Parallelism),
+	})
+	bcrypt := passwords.NewBcrypt(settings.BcryptCost)
+
+	if settings.PasswordHashAlgorithm == "bcrypt" {
+		return passwords.NewHasher(bcrypt, argon2id)
 	}
 
-	dbURL += fmt.Sprintf(" dbname=%s", dbName)
+	return passwords.NewHasher(argon2id, bcrypt)
+}
 
-	conf.DB, err = database.Connect(ctx, dbURL, dbMaxConn, dbMaxIdleConn)
-	if err != nil {
-		return conf, err
+// configurePasswordPolicy builds the rules for new passwords. The breached password dataset is loaded from
+// password_breached_dataset, a directory of range files or a single file of SHA-1 hashes, if set.
+func configurePasswordPolicy(settings *Settings, hasher passwords.Hasher) (*passwords.Policy, error) {
+	policy := &passwords.Policy{
+		Hasher:              hasher,
+		MinLength:           settings.PasswordMinLength,
+		MaxLength:           settings.PasswordMaxLength,
+		MinCharacterClasses: settings.PasswordMinCharacterClasses,
+		HistorySize:         settings.PasswordHistorySize,
+		MinEntropy:          settings.PasswordMinEntropy,
 	}
 
-	conf.AccessTokenValidityDuration, err = time.ParseDuration(getEnvOrSetDefault("ACCESS_TOKEN_VALIDITY_DURATION", "3s"))
-	if err != nil {
-		logrus.Warn("Invalid value set for env var TOKEN_VALIDITY_DURATION. " +
-			"Valid format: <number><s/m/h>. Defaulting to 3s (3 seconds)")
+	if path := settings.PasswordBreachedDataset; path != "" {
+		var err error
 
-		conf.AccessTokenValidityDuration = defaultTokenValidityDuration
+		policy.Breached, err = passwords.LoadRangesFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w; unable to load breached password dataset", err)
+		}
 	}
 
-	return conf, database.Migrate(conf.DB, "", conf.Environment)
+	return policy, nil
 }
 
-// getEnvOrSetDefault returns the value set in envVar if exists or defaultValue. Trims any accidental spaces.
-func getEnvOrSetDefault(envVar, defaultValue string) string {
-	if v, ok := os.LookupEnv(envVar); ok && strings.TrimSpace(v) != "" {
-		return strings.TrimSpace(v)
-	}
+// configureEmailValidator builds the checks an email address has to pass besides its syntax, out of mx, disposable
+// and domains.
+func configureEmailValidator(settings *Settings) (*utils.EmailValidator, error) {
+	var checks []utils.EmailCheck
+
+	for _, name := range settings.EmailChecks {
+		switch strings.ToLower(name) {
+		case "mx":
+			checks = append(checks, utils.NewMXCheck(nil, settings.EmailMXCacheTTL))
+		case "disposable":
+			domains := utils.NewDomainSet()
+			for d := range utils.DisposableDomains {
+				domains[d] = struct{}{}
+			}
+
+			if path := settings.EmailDisposableDomainsFile; path != "" {
+				f, err := os.Open(path)
+				if err != nil {
+					return nil, fmt.Errorf("%w; unable to open disposable email domains", err)
+				}
 
-	logrus.Warnf("No value set for env var %s. Defaulting to %s", envVar, defaultValue)
+				extra, err := utils.ReadDomainSet(f)
+				f.Close()
+
+				if err != nil {
+					return nil, fmt.Errorf("%w; unable to read disposable email domains", err)
+				}
+
+				for d := range extra {
+					domains[d] = struct{}{}
+				}
+			}
+
+			checks = append(checks, utils.DisposableCheck{Domains: domains})
+		case "domains":
+			checks = append(checks, utils.DomainListCheck{
+				Allowed: utils.NewDomainSet(settings.EmailAllowedDomains...),
+				Denied:  utils.NewDomainSet(settings.EmailDeniedDomains...),
+			})
+		}
+	}
 
-	return defaultValue
+	return utils.NewEmailValidator(checks...), nil
 }
@@ -0,0 +1,64 @@
+package configs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maknahar/alpha-flow/internal/models"
+)
+
+func TestConfigureStorage(t *testing.T) {
+	tests := []struct {
+		backend string
+		wantDB  bool
+	}{
+		{backend: StorageBackendMemory},
+		{backend: StorageBackendSQLite, wantDB: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.backend, func(t *testing.T) {
+			// Postgres is not connected to, which would fail against this port.
+			settings, _, err := Load([]string{"--db-host", "127.0.0.1", "--db-port", "1",
+				"--storage-backend", tt.backend, "--sqlite-path", filepath.Join(t.TempDir(), "alpha-flow.db")})
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			conf, err := Configure(ctx, settings)
+			if err != nil {
+				t.Fatalf("Configure() error = %v", err)
+			}
+
+			if conf.DB != nil {
+				defer conf.DB.Close()
+			}
+
+			if (conf.DB != nil) != tt.wantDB || conf.Database != nil || conf.DSN != nil || conf.Replicas != nil {
+				t.Errorf("Configure() DB = %v, Database = %v, DSN = %v, Replicas = %v; want a database %v and no "+
+					"Postgres", conf.DB, conf.Database, conf.DSN, conf.Replicas, tt.wantDB)
+			}
+
+			// Everything is stored in the backend.
+			if _, err = conf.Users.Create(ctx, "alice@example.com", "hash"); err != nil {
+				t.Errorf("Users.Create() error = %v", err)
+			}
+
+			err = conf.Rates.Record(ctx, []models.Rate{{Pair: "BTC-USD", Rate: 1, ObservedAt: time.Now()}})
+			if err != nil {
+				t.Errorf("Rates.Record() error = %v", err)
+			}
+
+			if err = conf.Audit.Append(ctx, &models.AuditEvent{Type: "sign_up"}, true); err != nil {
+				t.Errorf("Audit.Append() error = %v", err)
+			}
+		})
+	}
+}
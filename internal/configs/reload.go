@@ -0,0 +1,129 @@
+package configs
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/maknahar/alpha-flow/internal/passwords"
+	"github.com/maknahar/alpha-flow/internal/utils"
+)
+
+// reloadable are the keys of the settings that Conf.Reload applies while serving.
+//
+//nolint:gochecknoglobals
+var reloadable = map[string]bool{
+	"log_level":                      true,
+	"log_sample_initial":             true,
+	"log_sample_thereafter":          true,
+	"access_token_validity_duration": true,
+	"pair_provider_url":              true,
+	"cors_allowed_origins":           true,
+	"password_min_length":            true,
+	"password_max_length":            true,
+	"password_min_character_classes": true,
+	"password_history_size":          true,
+	"password_min_entropy":           true,
+	"password_breached_dataset":      true,
+	"email_checks":                   true,
+	"email_mx_cache_ttl":             true,
+	"email_disposable_domains_file":  true,
+	"email_allowed_domains":          true,
+	"email_denied_domains":           true,
+}
+
+// Runtime is the part of the configuration read on every request, which can be reloaded while serving. It is never
+// changed once built: a reload swaps it for a new one, so that a request sees either all the old or all the new values.
+type Runtime struct {
+	// AccessTokenValidityDuration is the time for which a token is valid after a successful login. Default: 1h
+	AccessTokenValidityDuration time.Duration
+
+	// PasswordPolicy is the set of rules a new password has to satisfy. Default: at least 8 and at most 64 characters
+	PasswordPolicy *passwords.Policy
+
+	// EmailValidator validates email addresses of new and updated accounts.
+	// Default: syntax only in Local, syntax, MX lookup and disposable domains otherwise
+	EmailValidator *utils.EmailValidator
+
+	// PairProviderURL is the base URL of the pair provider. Default: https://shapeshift.io
+	PairProviderURL string
+
+	// CORSAllowedOrigins are the origins allowed to call the HTTP API from a browser. Default: *
+	CORSAllowedOrigins []string
+}
+
+// newRuntime builds the runtime configuration of settings, which Load has validated.
+func newRuntime(settings *Settings, hasher passwords.Hasher) (*Runtime, error) {
+	policy, err := configurePasswordPolicy(settings, hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	emails, err := configureEmailValidator(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runtime{
+		AccessTokenValidityDuration: settings.AccessTokenValidityDuration,
+		PasswordPolicy:              policy,
+		EmailValidator:              emails,
+		PairProviderURL:             settings.PairProviderURL,
+		CORSAllowedOrigins:          settings.CORSAllowedOrigins,
+	}, nil
+}
+
+// Runtime returns the current runtime configuration. A Conf never configured returns an empty one.
+func (c *Conf) Runtime() *Runtime {
+	if runtime, ok := c.runtime.Load().(*Runtime); ok {
+		return runtime
+	}
+
+	return &Runtime{}
+}
+
+// Reload applies the reloadable settings of settings, which Load has validated, without dropping connections: the log
+// level and sampling, and the runtime configuration. It returns the reloadable settings that changed, along with the
+// other ones that changed but are only applied by restarting. Nothing is applied if an error is returned.
+func (c *Conf) Reload(settings *Settings) (changed, restart []string, err error) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	current := *c.settings
+	next := settingFields(&current)
+
+	for i, f := range settingFields(settings) {
+		if reflect.DeepEqual(f.value.Interface(), next[i].value.Interface()) {
+			continue
+		}
+
+		if !f.reload {
+			restart = append(restart, f.key)
+			continue
+		}
+
+		changed = append(changed, fmt.Sprintf("%s: %s -> %s", f.key, next[i].display(), f.display()))
+		next[i].value.Set(f.value)
+	}
+
+	if len(changed) == 0 {
+		return nil, restart, nil
+	}
+
+	runtime, err := newRuntime(&current, c.PasswordHasher)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The level was validated along with the other settings.
+	level, _ := logrus.ParseLevel(current.LogLevel)
+
+	c.Logger.SetLevel(level)
+	c.LogSampler.SetRates(current.LogSampleInitial, current.LogSampleThereafter)
+	c.runtime.Store(runtime)
+	c.settings = &current
+
+	return changed, restart, nil
+}
@@ -0,0 +1,70 @@
+package configs
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/maknahar/alpha-flow/internal/logging"
+)
+
+func TestReload(t *testing.T) {
+	settings := Defaults()
+	settings.EmailChecks = []string{"domains"}
+
+	conf := &Conf{
+		Logger:     logging.New(logrus.InfoLevel),
+		LogSampler: logging.NewSampler(settings.LogSampleInitial, settings.LogSampleThereafter),
+		settings:   settings,
+	}
+
+	runtime, err := newRuntime(settings, conf.PasswordHasher)
+	if err != nil {
+		t.Fatalf("newRuntime() error = %v", err)
+	}
+
+	conf.runtime.Store(runtime)
+
+	next := *settings
+	next.LogLevel = "Debug"
+	next.AccessTokenValidityDuration = time.Minute
+	next.DBHost = "replica"
+
+	changed, restart, err := conf.Reload(&next)
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	wantChanged := []string{"log_level: Info -> Debug", "access_token_validity_duration: 1h0m0s -> 1m0s"}
+	if !reflect.DeepEqual(changed, wantChanged) {
+		t.Errorf("Reload() changed = %q; want %q", changed, wantChanged)
+	}
+
+	if !reflect.DeepEqual(restart, []string{"db_host"}) {
+		t.Errorf("Reload() restart = %q; want [db_host]", restart)
+	}
+
+	if conf.Logger.GetLevel() != logrus.DebugLevel {
+		t.Errorf("Logger level = %v; want debug", conf.Logger.GetLevel())
+	}
+
+	if conf.Runtime().AccessTokenValidityDuration != time.Minute {
+		t.Errorf("AccessTokenValidityDuration = %v; want 1m", conf.Runtime().AccessTokenValidityDuration)
+	}
+
+	// A reload failing to build the runtime configuration leaves the current one in place.
+	current := conf.Runtime()
+	next.PasswordMinLength = 12
+	next.PasswordBreachedDataset = filepath.Join(t.TempDir(), "missing")
+
+	if _, _, err = conf.Reload(&next); err == nil {
+		t.Fatal("Reload() with a missing breached password dataset succeeded; want an error")
+	}
+
+	if conf.Runtime() != current || conf.settings.PasswordMinLength != settings.PasswordMinLength {
+		t.Error("Reload() failing changed the configuration")
+	}
+}
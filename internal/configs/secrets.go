@@ -0,0 +1,220 @@
+package configs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/maknahar/alpha-flow/internal/db"
+)
+
+// Secret providers, where the database credentials are read from.
+const (
+	SecretProviderNone  = "none"
+	SecretProviderFile  = "file"
+	SecretProviderVault = "vault"
+)
+
+// Names of the secrets read from the secret provider.
+const (
+	SecretDBUser = "db_user"
+	SecretDBPass = "db_pass"
+)
+
+// ErrSecretNotFound is returned by a SecretProvider not holding the secret asked for.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider fetches the secrets kept out of the configuration. Secrets may be rotated, every call returns the
+// current value.
+type SecretProvider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// NewSecretProvider returns the secret provider of settings, nil if none is configured.
+func NewSecretProvider(settings *Settings) SecretProvider {
+	switch settings.SecretProvider {
+	case SecretProviderFile:
+		return FileSecrets{Dir: settings.SecretDir}
+	case SecretProviderVault:
+		return &VaultSecrets{Address: settings.VaultAddr, Token: settings.VaultToken, Path: settings.VaultPath}
+	default:
+		return nil
+	}
+}
+
+// FileSecrets reads every secret from the file of the same name in Dir, such as a mounted Kubernetes secret or a
+// Docker secret in /run/secrets. Trailing line breaks are trimmed.
+type FileSecrets struct {
+	Dir string
+}
+
+func (f FileSecrets) Secret(_ context.Context, name string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(f.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("%w; unable to read secret %s", err, name)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// VaultSecrets reads every secret from the field of the same name of the Vault KV secret at Path, e.g.
+// secret/data/userapi for version 2 of the KV engine or secret/userapi for version 1. Anything serving the Vault HTTP
+// API can be used.
+type VaultSecrets struct {
+	// Address is the URL of the Vault server, e.g. https://vault:8200.
+	Address string
+	Token   string
+	Path    string
+
+	// Client sends the requests. Default: a client timing out after 10 seconds
+	Client *http.Client
+}
+
+//nolint:gochecknoglobals
+var vaultClient = &http.Client{Timeout: 10 * time.Second}
+
+func (v *VaultSecrets) Secret(ctx context.Context, name string) (string, error) {
+	url := strings.TrimRight(v.Address, "/") + "/v1/" + strings.TrimLeft(v.Path, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("X-Vault-Token", v.Token)
+
+	client := v.Client
+	if client == nil {
+		client = vaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w; unable to reach Vault", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, v.Path)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault answered %d reading %s", resp.StatusCode, v.Path)
+	}
+
+	// Version 2 of the KV engine nests the fields in data.data along with data.metadata, version 1 has them in data.
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("%w; unable to decode Vault secret %s", err, v.Path)
+	}
+
+	fields := body.Data
+
+	if nested, ok := body.Data["data"]; ok {
+		if err = json.Unmarshal(nested, &fields); err != nil {
+			return "", fmt.Errorf("%w; unable to decode Vault secret %s", err, v.Path)
+		}
+	}
+
+	var value string
+
+	field, ok := fields[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s in %s", ErrSecretNotFound, name, v.Path)
+	}
+
+	if err = json.Unmarshal(field, &value); err != nil {
+		return "", fmt.Errorf("%w; field %s of Vault secret %s is not a string", err, name, v.Path)
+	}
+
+	return value, nil
+}
+
+// rotatingDSN builds the connection string of the database on host with the credentials of a secret provider, fetched
+// again once refresh has passed or the database rejected them. The user of the settings is used if the provider has
+// none.
+type rotatingDSN struct {
+	settings *Settings
+	host     string
+	secrets  SecretProvider
+	refresh  time.Duration
+
+	mu        sync.Mutex
+	dsn       string
+	fetchedAt time.Time
+}
+
+func (r *rotatingDSN) DSN(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.dsn != "" && time.Since(r.fetchedAt) < r.refresh {
+		return r.dsn, nil
+	}
+
+	user, err := r.secrets.Secret(ctx, SecretDBUser)
+	if errors.Is(err, ErrSecretNotFound) {
+		user, err = r.settings.DBUser, nil
+	}
+
+	var pass string
+
+	if err == nil {
+		pass, err = r.secrets.Secret(ctx, SecretDBPass)
+	}
+
+	if err != nil {
+		// Existing credentials may still be valid, keep using them until the provider is back.
+		if r.dsn != "" {
+			logrus.WithError(err).Warn("Unable to refresh database credentials. Using the previous ones")
+			r.fetchedAt = time.Now()
+
+			return r.dsn, nil
+		}
+
+		return "", err
+	}
+
+	dsn := r.settings.dsn(r.host, user, pass)
+	if r.dsn != "" && dsn != r.dsn {
+		logrus.Info("Database credentials rotated")
+	}
+
+	r.dsn, r.fetchedAt = dsn, time.Now()
+
+	return r.dsn, nil
+}
+
+func (r *rotatingDSN) Invalidate() {
+	r.mu.Lock()
+	r.fetchedAt = time.Time{}
+	r.mu.Unlock()
+}
+
+// newDSN returns the connection string of the database on host, the primary or a replica, built with the credentials
+// of the secret provider if one is configured.
+func newDSN(settings *Settings, host string) db.DSN {
+	secrets := NewSecretProvider(settings)
+	if secrets == nil {
+		return db.StaticDSN(settings.dsn(host, settings.DBUser, settings.DBPass))
+	}
+
+	return &rotatingDSN{settings: settings, host: host, secrets: secrets, refresh: settings.DBCredentialsRefresh}
+}
@@ -0,0 +1,165 @@
+package configs
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSecrets(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, SecretDBPass), []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := FileSecrets{Dir: dir}
+
+	got, err := secrets.Secret(context.Background(), SecretDBPass)
+	if err != nil || got != "s3cret" {
+		t.Errorf("Secret(%q) = %q, %v; want %q", SecretDBPass, got, err, "s3cret")
+	}
+
+	if _, err = secrets.Secret(context.Background(), SecretDBUser); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Secret(%q) error = %v; want ErrSecretNotFound", SecretDBUser, err)
+	}
+}
+
+func TestVaultSecrets(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/userapi":
+			w.Write([]byte(`{"data":{"data":{"db_pass":"v2-pass"},"metadata":{"version":3}}}`)) //nolint:errcheck
+		case "/v1/secret/userapi":
+			w.Write([]byte(`{"data":{"db_pass":"v1-pass"}}`)) //nolint:errcheck
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	tests := []struct {
+		name    string
+		token   string
+		path    string
+		secret  string
+		want    string
+		wantErr bool
+		missing bool
+	}{
+		{name: "kv version 2", token: "root", path: "secret/data/userapi", secret: SecretDBPass, want: "v2-pass"},
+		{name: "kv version 1", token: "root", path: "secret/userapi", secret: SecretDBPass, want: "v1-pass"},
+		{name: "missing field", token: "root", path: "secret/data/userapi", secret: SecretDBUser, missing: true},
+		{name: "missing secret", token: "root", path: "secret/data/other", secret: SecretDBPass, missing: true},
+		{name: "denied", token: "wrong", path: "secret/data/userapi", secret: SecretDBPass, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets := &VaultSecrets{Address: vault.URL + "/", Token: tt.token, Path: tt.path}
+
+			got, err := secrets.Secret(context.Background(), tt.secret)
+
+			switch {
+			case tt.missing:
+				if !errors.Is(err, ErrSecretNotFound) {
+					t.Errorf("Secret() error = %v; want ErrSecretNotFound", err)
+				}
+			case tt.wantErr:
+				if err == nil || errors.Is(err, ErrSecretNotFound) {
+					t.Errorf("Secret() error = %v; want an error", err)
+				}
+			case err != nil || got != tt.want:
+				t.Errorf("Secret() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotatingDSN(t *testing.T) {
+	dir := t.TempDir()
+	write := func(pass string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, SecretDBPass), []byte(pass), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("first")
+
+	settings := Defaults()
+	settings.DBUser = "userapi"
+	dsn := &rotatingDSN{settings: settings, host: settings.DBHost, secrets: FileSecrets{Dir: dir},
+		refresh: settings.DBCredentialsRefresh}
+
+	want := "host=localhost port=5432 sslmode=disable user=userapi password=first dbname=userapi"
+	if got, err := dsn.DSN(context.Background()); err != nil || got != want {
+		t.Fatalf("DSN() = %q, %v; want %q", got, err, want)
+	}
+
+	write("second")
+
+	if got, _ := dsn.DSN(context.Background()); got != want {
+		t.Errorf("DSN() before refresh = %q; want %q", got, want)
+	}
+
+	dsn.Invalidate()
+
+	want = "host=localhost port=5432 sslmode=disable user=userapi password=second dbname=userapi"
+	if got, err := dsn.DSN(context.Background()); err != nil || got != want {
+		t.Errorf("DSN() after Invalidate() = %q, %v; want %q", got, err, want)
+	}
+
+	// The previous credentials are kept while the provider fails.
+	if err := os.Remove(filepath.Join(dir, SecretDBPass)); err != nil {
+		t.Fatal(err)
+	}
+
+	dsn.Invalidate()
+
+	if got, err := dsn.DSN(context.Background()); err != nil || got != want {
+		t.Errorf("DSN() with the secret missing = %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestLoadReadsFileEnvVars(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db_pass")
+	if err := ioutil.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	setenv(t, "DB_PASS_FILE", path)
+
+	settings, _, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if settings.DBPass != "from-file" {
+		t.Errorf("DBPass = %q; want %q", settings.DBPass, "from-file")
+	}
+
+	setenv(t, "DB_PASS", "from-env")
+
+	if _, _, err = Load(nil); err == nil {
+		t.Error("Load() with both DB_PASS and DB_PASS_FILE set succeeded; want an error")
+	}
+}
+
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.Unsetenv(key) })
+}
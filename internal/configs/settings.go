@@ -0,0 +1,682 @@
+package configs
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/maknahar/alpha-flow/internal/logging"
+	"github.com/maknahar/alpha-flow/internal/passwords"
+	"github.com/maknahar/alpha-flow/internal/tracing"
+)
+
+// Settings are the raw configuration values Conf is built from. They are layered: the defaults, overridden by the
+// config file, overridden by the env vars, overridden by the command line flags.
+//
+// Every setting has a key, used as is in the config file. The env var is the key in upper case and the flag the key
+// with dashes, e.g. db_max_conn, DB_MAX_CONN and --db-max-conn. Lists are comma separated in env vars and flags.
+// Instead of the env var, the env var suffixed with _FILE can name a file holding the value, e.g. a Docker secret.
+// Some settings can be changed while serving, see Conf.Reload.
+type Settings struct {
+	Environment string `config:"environment" usage:"name of the environment the service runs on"`
+	Host        string `config:"host" usage:"address the HTTP API listens on"`
+	GRPCHost    string `config:"grpc_host" usage:"address the gRPC API listens on"`
+
+	LogLevel            string `config:"log_level" usage:"Trace, Debug, Info, Warning, Error, Fatal or Panic"`
+	LogSampleInitial    int    `config:"log_sample_initial" usage:"request logs of a route kept each second"`
+	LogSampleThereafter int    `config:"log_sample_thereafter" usage:"then one request log kept in every"`
+
+	DBHost        string `config:"db_host" usage:"host of the database"`
+	DBPort        int    `config:"db_port" usage:"port of the database, shared by the replicas"`
+	DBUser        string `config:"db_user" usage:"user of the database"`
+	DBPass        string `config:"db_pass" secret:"true" usage:"password of the database user"`
+	DBName        string `config:"db_name" usage:"name of the database"`
+	DBMaxConn     int    `config:"db_max_conn" usage:"open connections to the database, at most"`
+	DBMaxIdleConn int    `config:"db_max_idle_conn" usage:"idle connections to the database, at most db_max_conn"`
+
+	// DBReplicaHosts are read replicas of db_host, sharing its database and credentials. The reads of a user go to
+	// the primary for db_replica_sticky_window after its writes, so that they see them despite the replication lag.
+	// Replicas further behind than db_replica_max_lag are not read from.
+	DBReplicaHosts         []string      `config:"db_replica_hosts" usage:"hosts of the read replicas, if any"`
+	DBReplicaStickyWindow  time.Duration `config:"db_replica_sticky_window" usage:"time reads follow writes to primary"`
+	DBReplicaCheckInterval time.Duration `config:"db_replica_check_interval" usage:"time between replica checks"`
+	DBReplicaMaxLag        time.Duration `config:"db_replica_max_lag" usage:"replication lag replicas are read within"`
+
+	MigrateOnStart string `config:"migrate_on_start" usage:"migrate, verify or skip the migrations on start"`
+
+	// StorageBackend is where the users, their subscriptions, the rates and the audit events are stored. The db_*
+	// settings only apply to postgres.
+	StorageBackend string `config:"storage_backend" usage:"postgres, sqlite or memory"`
+	SQLitePath     string `config:"sqlite_path" usage:"file of the SQLite database"`
+
+	TxIsolation   string `config:"tx_isolation" usage:"read_committed, repeatable_read or serializable"`
+	TxMaxAttempts int    `config:"tx_max_attempts" usage:"runs of a transaction failing to serialize, at most"`
+
+	// SecretProvider is where the database credentials are read from instead of db_user and db_pass, so that they can
+	// be rotated without restarting.
+	SecretProvider       string        `config:"secret_provider" usage:"none, file or vault"`
+	SecretDir            string        `config:"secret_dir" usage:"directory of the secrets of the file provider"`
+	VaultAddr            string        `config:"vault_addr" usage:"URL of the Vault server"`
+	VaultToken           string        `config:"vault_token" secret:"true" usage:"token read Vault with"`
+	VaultPath            string        `config:"vault_path" usage:"path of the KV secret, e.g. secret/data/userapi"`
+	DBCredentialsRefresh time.Duration `config:"db_credentials_refresh" usage:"time database credentials are reused"`
+
+	AccessTokenValidityDuration time.Duration `config:"access_token_validity_duration" usage:"lifetime of a token"`
+	MaxRequestBodyBytes         int64         `config:"max_request_body_bytes" usage:"largest request body accepted"`
+	LegacyRoutes                bool          `config:"legacy_routes" usage:"keep the root routes as aliases of /v1"`
+	LegacyRoutesSunset          string        `config:"legacy_routes_sunset" usage:"RFC 3339 sunset of the root routes"`
+	GraphQLMaxDepth             int           `config:"graphql_max_depth" usage:"deepest nesting of a GraphQL query"`
+	GraphQLMaxComplexity        int           `config:"graphql_max_complexity" usage:"highest cost of a GraphQL query"`
+
+	TracingExporter    string  `config:"tracing_exporter" usage:"none, stdout or otlp"`
+	TracingEndpoint    string  `config:"tracing_endpoint" usage:"address of the OTLP collector"`
+	TracingSampleRatio float64 `config:"tracing_sample_ratio" usage:"share of new traces recorded, between 0 and 1"`
+
+	ReadinessCheckTimeout time.Duration `config:"readiness_check_timeout" usage:"time given to each readiness check"`
+	ShutdownGracePeriod   time.Duration `config:"shutdown_grace_period" usage:"time served while not ready on shutdown"`
+
+	PairProviderURL    string   `config:"pair_provider_url" usage:"URL of the pair provider"`
+	CORSAllowedOrigins []string `config:"cors_allowed_origins" usage:"origins allowed by CORS, * for any"`
+
+	AdminEmails    []string `config:"admin_emails" usage:"emails of the users allowed to query the audit events"`
+	AuditHashChain bool     `config:"audit_hash_chain" usage:"chain the audit events with a hash"`
+
+	PasswordHashAlgorithm string `config:"password_hash_algorithm" usage:"argon2id or bcrypt"`
+	BcryptCost            int    `config:"bcrypt_cost" usage:"cost of bcrypt, between 4 and 31"`
+	Argon2Memory          int    `config:"argon2_memory" usage:"memory used by argon2id in KiB"`
+	Argon2Iterations      int    `config:"argon2_iterations" usage:"passes of argon2id over the memory"`
+	Argon2Parallelism     int    `config:"argon2_parallelism" usage:"threads used by argon2id, between 1 and 255"`
+
+	PasswordMinLength           int     `config:"password_min_length" usage:"fewest characters of a password"`
+	PasswordMaxLength           int     `config:"password_max_length" usage:"most characters of a password"`
+	PasswordMinCharacterClasses int     `config:"password_min_character_classes" usage:"fewest character classes"`
+	PasswordHistorySize         int     `config:"password_history_size" usage:"previous passwords not reusable"`
+	PasswordMinEntropy          float64 `config:"password_min_entropy" usage:"fewest bits of entropy, 0 to disable"`
+	PasswordBreachedDataset     string  `config:"password_breached_dataset" usage:"file or directory of SHA-1 ranges"`
+
+	// EmailChecks defaults to domains in Local and to mx, disposable and domains otherwise.
+	EmailChecks                []string      `config:"email_checks" usage:"syntax, mx, disposable and domains"`
+	EmailMXCacheTTL            time.Duration `config:"email_mx_cache_ttl" usage:"time MX lookups are cached for"`
+	EmailDisposableDomainsFile string        `config:"email_disposable_domains_file" usage:"more disposable domains"`
+	EmailAllowedDomains        []string      `config:"email_allowed_domains" usage:"only domains allowed, if set"`
+	EmailDeniedDomains         []string      `config:"email_denied_domains" usage:"domains denied"`
+}
+
+// Defaults returns the settings used when no source sets them.
+func Defaults() *Settings {
+	return &Settings{
+		Environment:                 "Local",
+		Host:                        ":9001",
+		GRPCHost:                    ":9002",
+		LogLevel:                    "Info",
+		LogSampleInitial:            defaultLogSampleInitial,
+		LogSampleThereafter:         defaultLogSampleThereafter,
+		DBHost:                      "localhost",
+		DBPort:                      defaultDBPort,
+		DBName:                      "userapi",
+		DBMaxConn:                   defaultDBMaxConn,
+		DBMaxIdleConn:               defaultDBMaxIdleConn,
+		DBReplicaStickyWindow:       defaultDBReplicaStickyWindow,
+		DBReplicaCheckInterval:      defaultDBReplicaCheckInterval,
+		DBReplicaMaxLag:             defaultDBReplicaMaxLag,
+		MigrateOnStart:              MigrateOnStartMigrate,
+		StorageBackend:              StorageBackendPostgres,
+		SQLitePath:                  "alpha-flow.db",
+		TxIsolation:                 "serializable",
+		TxMaxAttempts:               defaultTxMaxAttempts,
+		SecretProvider:              SecretProviderNone,
+		SecretDir:                   "/run/secrets",
+		DBCredentialsRefresh:        defaultDBCredentialsRefresh,
+		AccessTokenValidityDuration: defaultTokenValidityDuration,
+		MaxRequestBodyBytes:         defaultMaxRequestBodyBytes,
+		LegacyRoutes:                true,
+		GraphQLMaxDepth:             defaultGraphQLMaxDepth,
+		GraphQLMaxComplexity:        defaultGraphQLMaxComplexity,
+		PairProviderURL:             "https://shapeshift.io",
+		CORSAllowedOrigins:          []string{"*"},
+		TracingExporter:             tracing.ExporterNone,
+		TracingEndpoint:             "localhost:4317",
+		TracingSampleRatio:          1,
+		ReadinessCheckTimeout:       defaultReadinessCheckTimeout,
+		ShutdownGracePeriod:         defaultShutdownGracePeriod,
+		PasswordHashAlgorithm:       "argon2id",
+		BcryptCost:                  passwords.DefaultBcryptCost,
+		Argon2Memory:                passwords.DefaultArgon2Memory,
+		Argon2Iterations:            passwords.DefaultArgon2Iterations,
+		Argon2Parallelism:           passwords.DefaultArgon2Parallelism,
+		PasswordMinLength:           defaultPasswordMinLength,
+		PasswordMaxLength:           defaultPasswordMaxLength,
+		EmailMXCacheTTL:             time.Hour,
+	}
+}
+
+// Flags are the command line flags besides the settings.
+type Flags struct {
+	// Config is the YAML or TOML file the settings are read from, told apart by its extension. Default: CONFIG_FILE
+	Config string
+
+	// PrintConfig asks for the effective settings to be printed, with the secrets masked, instead of starting.
+	PrintConfig bool
+
+	// Args are the arguments left after the flags.
+	Args []string
+}
+
+// Errors are all the problems found while loading the settings, reported at once.
+type Errors []string
+
+func (e Errors) Error() string {
+	return "invalid configuration: " + strings.Join(e, "; ")
+}
+
+// Load reads the settings from their layered sources and validates them. args are the command line arguments, without
+// the name of the program. flag.ErrHelp is returned if the usage was asked for.
+func Load(args []string) (*Settings, *Flags, error) {
+	settings := Defaults()
+	fields := settingFields(settings)
+
+	flags := &Flags{}
+	set := flag.NewFlagSet("alpha-flow", flag.ContinueOnError)
+	set.StringVar(&flags.Config, "config", os.Getenv("CONFIG_FILE"), "YAML or TOML `file` to read the settings from")
+	set.BoolVar(&flags.PrintConfig, "print-config", false, "print the settings with the secrets masked and exit")
+
+	// The flags are only recorded while parsing, so that they override the config file named by --config and that
+	// their invalid values are reported along with all the others.
+	var flagValues []rawValue
+
+	for _, f := range fields {
+		set.Var(&recordedFlag{field: f, values: &flagValues}, f.flag(), f.usage)
+	}
+
+	if err := set.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	flags.Args = set.Args()
+
+	var errs Errors
+
+	if flags.Config != "" {
+		values, err := readConfigFile(flags.Config)
+		if err != nil {
+			return nil, flags, err
+		}
+
+		errs = append(errs, apply(fields, values)...)
+	}
+
+	env, envErrs := envValues(fields)
+	errs = append(errs, envErrs...)
+	errs = append(errs, apply(fields, env)...)
+	errs = append(errs, apply(fields, flagValues)...)
+
+	if settings.EmailChecks == nil {
+		settings.EmailChecks = []string{"mx", "disposable", "domains"}
+		if settings.Environment == "Local" {
+			settings.EmailChecks = []string{"domains"}
+		}
+	}
+
+	errs = append(errs, settings.validate()...)
+
+	if len(errs) > 0 {
+		return nil, flags, errs
+	}
+
+	return settings, flags, nil
+}
+
+// Validate checks every setting and returns Errors listing all the invalid ones, nil if they are all valid.
+func (s *Settings) Validate() error {
+	if errs := s.validate(); len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (s *Settings) validate() Errors {
+	var errs Errors
+
+	check := func(ok bool, key, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, key+": "+fmt.Sprintf(format, args...))
+		}
+	}
+
+	_, err := logrus.ParseLevel(s.LogLevel)
+	check(err == nil, "log_level", "must be one of Trace, Debug, Info, Warning, Error, Fatal and Panic")
+	check(s.LogSampleInitial >= 0, "log_sample_initial", "must not be negative")
+	check(s.LogSampleThereafter >= 0, "log_sample_thereafter", "must not be negative")
+
+	check(s.DBHost != "", "db_host", "must be set")
+	check(s.DBPort > 0 && s.DBPort <= math.MaxUint16, "db_port", "must be between 1 and %d", math.MaxUint16)
+	check(s.DBName != "", "db_name", "must be set")
+	check(s.DBMaxConn > 0, "db_max_conn", "must be positive")
+	check(s.DBMaxIdleConn >= 0 && s.DBMaxIdleConn <= s.DBMaxConn, "db_max_idle_conn",
+		"must be between 0 and db_max_conn (%d)", s.DBMaxConn)
+
+	for _, host := range s.DBReplicaHosts {
+		check(host != "" && host != s.DBHost, "db_replica_hosts", "%q must be set and differ from db_host", host)
+	}
+
+	check(s.DBReplicaStickyWindow >= 0, "db_replica_sticky_window", "must not be negative")
+	check(s.DBReplicaCheckInterval > 0, "db_replica_check_interval", "must be positive")
+	check(s.DBReplicaMaxLag > 0, "db_replica_max_lag", "must be positive")
+
+	check(s.MigrateOnStart == MigrateOnStartMigrate || s.MigrateOnStart == MigrateOnStartVerify ||
+		s.MigrateOnStart == MigrateOnStartSkip, "migrate_on_start", "must be one of migrate, verify and skip")
+
+	switch s.StorageBackend {
+	case StorageBackendPostgres, StorageBackendMemory:
+	case StorageBackendSQLite:
+		check(s.SQLitePath != "", "sqlite_path", "must be set for the sqlite storage backend")
+	default:
+		check(false, "storage_backend", "must be one of postgres, sqlite and memory")
+	}
+
+	_, ok := txIsolationLevels[s.TxIsolation]
+	check(ok, "tx_isolation", "must be one of read_committed, repeatable_read and serializable")
+	check(s.TxMaxAttempts > 0, "tx_max_attempts", "must be positive")
+
+	switch s.SecretProvider {
+	case SecretProviderNone:
+	case SecretProviderFile:
+		check(s.SecretDir != "", "secret_dir", "must be set for the file secret provider")
+	case SecretProviderVault:
+		check(s.VaultAddr != "", "vault_addr", "must be set for the vault secret provider")
+		check(s.VaultToken != "", "vault_token", "must be set for the vault secret provider")
+		check(s.VaultPath != "", "vault_path", "must be set for the vault secret provider")
+	default:
+		check(false, "secret_provider", "must be one of none, file and vault")
+	}
+
+	check(s.DBCredentialsRefresh > 0, "db_credentials_refresh", "must be positive")
+
+	check(s.AccessTokenValidityDuration > 0, "access_token_validity_duration", "must be positive")
+
+	if u, err := url.Parse(s.PairProviderURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		check(false, "pair_provider_url", "must be an http or https URL")
+	}
+
+	check(s.MaxRequestBodyBytes > 0, "max_request_body_bytes", "must be positive")
+
+	if s.LegacyRoutesSunset != "" {
+		_, err = time.Parse(time.RFC3339, s.LegacyRoutesSunset)
+		check(err == nil, "legacy_routes_sunset", "must be an RFC 3339 time, e.g. 2021-06-30T00:00:00Z")
+	}
+
+	check(s.GraphQLMaxDepth > 0, "graphql_max_depth", "must be positive")
+	check(s.GraphQLMaxComplexity > 0, "graphql_max_complexity", "must be positive")
+
+	check(s.TracingExporter == tracing.ExporterNone || s.TracingExporter == tracing.ExporterStdout ||
+		s.TracingExporter == tracing.ExporterOTLP, "tracing_exporter", "must be one of none, stdout and otlp")
+	check(s.TracingSampleRatio >= 0 && s.TracingSampleRatio <= 1, "tracing_sample_ratio", "must be between 0 and 1")
+
+	check(s.ReadinessCheckTimeout > 0, "readiness_check_timeout", "must be positive")
+	check(s.ShutdownGracePeriod >= 0, "shutdown_grace_period", "must not be negative")
+
+	check(s.PasswordHashAlgorithm == "argon2id" || s.PasswordHashAlgorithm == "bcrypt", "password_hash_algorithm",
+		"must be one of argon2id and bcrypt")
+	check(s.BcryptCost >= 4 && s.BcryptCost <= 31, "bcrypt_cost", "must be between 4 and 31")
+	check(s.Argon2Memory >= 8*s.Argon2Parallelism && s.Argon2Memory <= passwords.MaxArgon2Memory, "argon2_memory",
+		"must be between 8 KiB a thread and 4 GiB, in KiB")
+	check(s.Argon2Iterations > 0 && uint64(s.Argon2Iterations) <= math.MaxUint32, "argon2_iterations",
+		"must be positive")
+	check(s.Argon2Parallelism >= 1 && s.Argon2Parallelism <= 255, "argon2_parallelism", "must be between 1 and 255")
+
+	check(s.PasswordMinLength >= 0, "password_min_length", "must not be negative")
+	check(s.PasswordMaxLength >= s.PasswordMinLength, "password_max_length",
+		"must be at least password_min_length (%d)", s.PasswordMinLength)
+	check(s.PasswordMinCharacterClasses >= 0, "password_min_character_classes", "must not be negative")
+	check(s.PasswordHistorySize >= 0, "password_history_size", "must not be negative")
+	check(s.PasswordMinEntropy >= 0, "password_min_entropy", "must not be negative")
+
+	for _, name := range s.EmailChecks {
+		switch strings.ToLower(name) {
+		case "syntax", "mx", "disposable", "domains":
+		default:
+			check(false, "email_checks", "%q must be one of syntax, mx, disposable and domains", name)
+		}
+	}
+
+	check(s.EmailMXCacheTTL > 0, "email_mx_cache_ttl", "must be positive")
+
+	return errs
+}
+
+// Print writes the settings to w as YAML, in the format of the config file. Secrets are masked.
+func (s *Settings) Print(w io.Writer) error {
+	var out yaml.MapSlice
+
+	for _, f := range settingFields(s) {
+		var value interface{}
+
+		switch v := f.value.Interface().(type) {
+		case time.Duration:
+			value = v.String()
+		case []string:
+			value = v
+			if v == nil {
+				value = []string{}
+			}
+		default:
+			value = v
+		}
+
+		if f.secret && !f.value.IsZero() {
+			value = logging.Redacted
+		}
+
+		out = append(out, yaml.MapItem{Key: f.key, Value: value})
+	}
+
+	data, err := yaml.Marshal(out)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+// settingField is a field of Settings along with the names it is set by.
+type settingField struct {
+	key    string
+	usage  string
+	secret bool
+	reload bool
+	value  reflect.Value
+}
+
+func (f settingField) env() string {
+	return strings.ToUpper(f.key)
+}
+
+func (f settingField) flag() string {
+	return strings.ReplaceAll(f.key, "_", "-")
+}
+
+// display returns the value of the setting as written in env vars and flags, masked if secret.
+func (f settingField) display() string {
+	if f.secret && !f.value.IsZero() {
+		return logging.Redacted
+	}
+
+	switch v := f.value.Interface().(type) {
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func settingFields(s *Settings) []settingField {
+	v := reflect.ValueOf(s).Elem()
+	fields := make([]settingField, 0, v.NumField())
+
+	for i := 0; i < v.NumField(); i++ {
+		tag := v.Type().Field(i).Tag
+		fields = append(fields, settingField{
+			key:    tag.Get("config"),
+			usage:  tag.Get("usage"),
+			secret: tag.Get("secret") == "true",
+			reload: reloadable[tag.Get("config")],
+			value:  v.Field(i),
+		})
+	}
+
+	return fields
+}
+
+// rawValue is the value of a setting as read from a source. value is a string, or for the config file anything
+// YAML or TOML decodes to.
+type rawValue struct {
+	source string
+	key    string
+	value  interface{}
+}
+
+// apply sets the fields from values and returns the values that are unknown or invalid.
+func apply(fields []settingField, values []rawValue) Errors {
+	byKey := make(map[string]settingField, len(fields))
+	for _, f := range fields {
+		byKey[f.key] = f
+	}
+
+	var errs Errors
+
+	for _, v := range values {
+		f, ok := byKey[v.key]
+		if !ok {
+			errs = append(errs, v.source+": unknown setting")
+			continue
+		}
+
+		if err := setField(f.value, v.value); err != nil {
+			errs = append(errs, v.source+": "+err.Error())
+		}
+	}
+
+	return errs
+}
+
+//nolint:gochecknoglobals
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setField(field reflect.Value, value interface{}) error {
+	switch value.(type) {
+	case map[interface{}]interface{}, map[string]interface{}:
+		return fmt.Errorf("must be a single value, not a table")
+	}
+
+	if field.Kind() == reflect.Slice {
+		var items []string
+
+		switch v := value.(type) {
+		case string:
+			items = strings.Split(v, ",")
+		case []interface{}:
+			for _, item := range v {
+				items = append(items, scalar(item))
+			}
+		default:
+			return fmt.Errorf("must be a list")
+		}
+
+		list := make([]string, 0, len(items))
+
+		for _, item := range items {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+
+		field.Set(reflect.ValueOf(list))
+
+		return nil
+	}
+
+	s := strings.TrimSpace(scalar(value))
+
+	switch {
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("must be a duration, e.g. 3s, 250ms or 1h")
+		}
+
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(s)
+	case field.Kind() == reflect.Int || field.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be a whole number")
+		}
+
+		field.SetInt(n)
+	case field.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+
+		field.SetFloat(n)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+
+		field.SetBool(b)
+	}
+
+	return nil
+}
+
+// scalar returns the text of a value decoded from the config file.
+func scalar(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func readConfigFile(path string) ([]rawValue, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w; unable to read config file", err)
+	}
+
+	values := make(map[string]interface{})
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q. Valid options: .yaml, .yml and .toml", ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w; unable to parse config file %s", err, path)
+	}
+
+	raw := make([]rawValue, 0, len(values))
+
+	for key, value := range values {
+		raw = append(raw, rawValue{source: path + ": " + key, key: key, value: value})
+	}
+
+	// Map order is random, report the errors in a stable one.
+	sort.Slice(raw, func(i, j int) bool { return raw[i].key < raw[j].key })
+
+	return raw, nil
+}
+
+// envValues returns the values set by env vars, read from the file named by the _FILE env var if set instead.
+func envValues(fields []settingField) ([]rawValue, Errors) {
+	var (
+		raw  []rawValue
+		errs Errors
+	)
+
+	for _, f := range fields {
+		v, ok := lookupEnv(f.env())
+		path, fromFile := lookupEnv(f.env() + "_FILE")
+
+		switch {
+		case ok && fromFile:
+			errs = append(errs, "env var "+f.env()+": set only one of "+f.env()+" and "+f.env()+"_FILE")
+		case ok:
+			raw = append(raw, rawValue{source: "env var " + f.env(), key: f.key, value: v})
+		case fromFile:
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				errs = append(errs, "env var "+f.env()+"_FILE: "+err.Error())
+				continue
+			}
+
+			raw = append(raw, rawValue{source: "env var " + f.env() + "_FILE", key: f.key,
+				value: strings.TrimRight(string(data), "\r\n")})
+		}
+	}
+
+	return raw, errs
+}
+
+func lookupEnv(name string) (string, bool) {
+	v, ok := os.LookupEnv(name)
+	return v, ok && strings.TrimSpace(v) != ""
+}
+
+// dsn returns the connection string of the database with user and pass.
+func (s *Settings) dsn(host, user, pass string) string {
+	dsn := fmt.Sprintf("host=%s port=%d sslmode=disable", dsnValue(host), s.DBPort)
+
+	if user != "" {
+		dsn += " user=" + dsnValue(user)
+	}
+
+	if pass != "" {
+		dsn += " password=" + dsnValue(pass)
+	}
+
+	return dsn + " dbname=" + dsnValue(s.DBName)
+}
+
+// dsnValue quotes v for a key/value connection string if it is empty or holds white space, quotes or backslashes,
+// escaping the quotes and backslashes the way lib/pq reads them.
+func dsnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, `'\`) && strings.IndexFunc(v, unicode.IsSpace) < 0 {
+		return v
+	}
+
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// recordedFlag records the value of a setting flag, to be applied once the other sources are.
+type recordedFlag struct {
+	field  settingField
+	values *[]rawValue
+}
+
+// String returns the default of the setting, for the usage. The flag package also calls it on a zero recordedFlag.
+func (f *recordedFlag) String() string {
+	if !f.field.value.IsValid() {
+		return ""
+	}
+
+	return f.field.display()
+}
+
+func (f *recordedFlag) Set(value string) error {
+	*f.values = append(*f.values, rawValue{source: "flag --" + f.field.flag(), key: f.field.key, value: value})
+	return nil
+}
+
+func (f *recordedFlag) IsBoolFlag() bool {
+	return f.field.value.Kind() == reflect.Bool
+}
@@ -0,0 +1,321 @@
+package configs
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/maknahar/alpha-flow/internal/logging"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, "config.yaml", "db_host: file\ndb_name: file\ndb_max_conn: 30\nlog_level: Debug\n")
+
+	setenv(t, "DB_NAME", "env")
+	setenv(t, "DB_MAX_CONN", "40")
+
+	// The flags override the config file whatever their position.
+	settings, flags, err := Load([]string{"--db-max-conn=50", "--config", path, "--print-config", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := Defaults()
+	want.DBHost = "file"
+	want.LogLevel = "Debug"
+	want.DBName = "env"
+	want.DBMaxConn = 50
+	want.EmailChecks = []string{"domains"}
+
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("Load() = %+v; want %+v", settings, want)
+	}
+
+	if flags.Config != path || !flags.PrintConfig || !reflect.DeepEqual(flags.Args, []string{"migrate", "up"}) {
+		t.Errorf("Load() flags = %+v; want the config file and the arguments left", flags)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `environment: Production
+db_port: 6543
+db_replica_hosts: [replica-1, replica-2]
+db_replica_sticky_window: 2s
+legacy_routes: false
+tracing_sample_ratio: 0.25
+cors_allowed_origins: "https://example.com, https://example.org"
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `environment = "Production"
+db_port = 6543
+db_replica_hosts = ["replica-1", "replica-2"]
+db_replica_sticky_window = "2s"
+legacy_routes = false
+tracing_sample_ratio = 0.25
+cors_allowed_origins = "https://example.com, https://example.org"
+`,
+		},
+		{name: "yml", file: "config.yml", content: "environment: Production\ncolour: blue\n",
+			wantErr: "config.yml: colour: unknown setting"},
+		{name: "unknown toml key", file: "config.toml", content: "[db]\nhost = \"db\"\n",
+			wantErr: "config.toml: db: unknown setting"},
+		{name: "table", file: "config.yaml", content: "db_host:\n  name: db\n",
+			wantErr: "config.yaml: db_host: must be a single value, not a table"},
+		{name: "table as a list", file: "config.yaml", content: "admin_emails: {a: b}\n",
+			wantErr: "config.yaml: admin_emails: must be a single value, not a table"},
+		{name: "not a list", file: "config.yaml", content: "admin_emails: 3\n",
+			wantErr: "config.yaml: admin_emails: must be a list"},
+		{name: "malformed yaml", file: "config.yaml", content: "db_host: [\n", wantErr: "unable to parse config file"},
+		{name: "malformed toml", file: "config.toml", content: "db_host = \n", wantErr: "unable to parse config file"},
+		{name: "unsupported extension", file: "config.json", content: "{}",
+			wantErr: `unsupported config file extension ".json"`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.file, tt.content)
+
+			settings, _, err := Load([]string{"--config", path})
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Load() error = %v; want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			want := Defaults()
+			want.Environment = "Production"
+			want.DBPort = 6543
+			want.DBReplicaHosts = []string{"replica-1", "replica-2"}
+			want.DBReplicaStickyWindow = 2 * time.Second
+			want.LegacyRoutes = false
+			want.TracingSampleRatio = 0.25
+			want.CORSAllowedOrigins = []string{"https://example.com", "https://example.org"}
+			want.EmailChecks = []string{"mx", "disposable", "domains"}
+
+			if !reflect.DeepEqual(settings, want) {
+				t.Errorf("Load() = %+v; want %+v", settings, want)
+			}
+		})
+	}
+}
+
+func TestLoadMissingConfigFile(t *testing.T) {
+	if _, _, err := Load([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("Load() of a missing config file succeeded; want an error")
+	}
+}
+
+func TestLoadReportsEveryError(t *testing.T) {
+	path := writeConfig(t, "config.yaml", "colour: blue\ndb_port: many\n")
+
+	setenv(t, "READINESS_CHECK_TIMEOUT", "2")
+
+	_, _, err := Load([]string{"--config", path, "--legacy-routes=maybe", "--db-max-conn", "0"})
+
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Load() error = %v; want Errors", err)
+	}
+
+	want := Errors{
+		path + ": colour: unknown setting",
+		path + ": db_port: must be a whole number",
+		"env var READINESS_CHECK_TIMEOUT: must be a duration, e.g. 3s, 250ms or 1h",
+		"flag --legacy-routes: must be true or false",
+		"db_max_conn: must be positive",
+		"db_max_idle_conn: must be between 0 and db_max_conn (0)",
+	}
+
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("Load() errors =\n%s\nwant\n%s", strings.Join(errs, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoadArgon2Memory(t *testing.T) {
+	tests := []struct {
+		memory, parallelism string
+		wantErr             bool
+	}{
+		{memory: "16", parallelism: "2"},
+		{memory: "4194304", parallelism: "1"},
+		{memory: "15", parallelism: "2", wantErr: true},
+		{memory: "4194305", parallelism: "1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.memory+"/"+tt.parallelism, func(t *testing.T) {
+			_, _, err := Load([]string{"--argon2-memory", tt.memory, "--argon2-parallelism", tt.parallelism})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v; want an error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadHelp(t *testing.T) {
+	if _, _, err := Load([]string{"--help"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Load(--help) error = %v; want flag.ErrHelp", err)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	settings := Defaults()
+	settings.DBPass = "hunter2"
+	settings.VaultToken = "s.token"
+	settings.AdminEmails = []string{"admin@example.com"}
+	settings.EmailChecks = []string{"domains"}
+
+	var out bytes.Buffer
+	if err := settings.Print(&out); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+
+	printed := out.String()
+
+	for _, line := range []string{
+		"db_pass: '" + logging.Redacted + "'", "vault_token: '" + logging.Redacted + "'", "db_user: \"\"",
+		"admin_emails:\n- admin@example.com", "db_replica_hosts: []", "access_token_validity_duration: 1h0m0s",
+	} {
+		if !strings.Contains(printed, line+"\n") {
+			t.Errorf("Print() = %s; want the line %q", printed, line)
+		}
+	}
+
+	if strings.Contains(printed, "hunter2") || strings.Contains(printed, "s.token") {
+		t.Errorf("Print() = %s; want the secrets masked", printed)
+	}
+
+	// The settings are printed in the format of the config file, so that they can be loaded again.
+	settings.DBPass, settings.VaultToken = "", ""
+
+	out.Reset()
+
+	if err := settings.Print(&out); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+
+	loaded, _, err := Load([]string{"--config", writeConfig(t, "config.yaml", out.String())})
+	if err != nil {
+		t.Fatalf("Load() of the printed settings error = %v", err)
+	}
+
+	var reprinted bytes.Buffer
+	if err = loaded.Print(&reprinted); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+
+	if reprinted.String() != out.String() {
+		t.Errorf("Print() of the loaded settings = %s; want %s", reprinted.String(), out.String())
+	}
+}
+
+func TestDSN(t *testing.T) {
+	settings := Defaults()
+
+	tests := []struct {
+		name string
+		user string
+		pass string
+		want string
+	}{
+		{
+			name: "plain", user: "userapi", pass: "open1234",
+			want: "host=localhost port=5432 sslmode=disable user=userapi password=open1234 dbname=userapi",
+		},
+		{
+			name: "without credentials",
+			want: "host=localhost port=5432 sslmode=disable dbname=userapi",
+		},
+		{
+			name: "special characters", user: "user api", pass: `it's a \secret`,
+			want: `host=localhost port=5432 sslmode=disable user='user api' password='it\'s a \\secret' dbname=userapi`,
+		},
+		{
+			name: "option in the password", user: "userapi", pass: "x sslmode=require",
+			want: "host=localhost port=5432 sslmode=disable user=userapi password='x sslmode=require' dbname=userapi",
+		},
+		{
+			name: "other white space", user: "userapi", pass: "tab\there ",
+			want: "host=localhost port=5432 sslmode=disable user=userapi password='tab\there ' dbname=userapi",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			dsn := settings.dsn(settings.DBHost, tt.user, tt.pass)
+			if dsn != tt.want {
+				t.Errorf("dsn() = %s; want %s", dsn, tt.want)
+			}
+
+			// lib/pq reads the credentials back as they were, defaulting the user to that of the process.
+			opts := pqOptions(t, dsn)
+			if tt.user != "" && opts["user"] != tt.user || opts["password"] != tt.pass || opts["sslmode"] != "disable" {
+				t.Errorf("dsn() read as user %q, password %q, sslmode %q; want %q, %q, disable", opts["user"],
+					opts["password"], opts["sslmode"], tt.user, tt.pass)
+			}
+		})
+	}
+}
+
+// pqOptions returns the options lib/pq parses from dsn. They are not exported, so they are read by reflection.
+func pqOptions(t *testing.T, dsn string) map[string]string {
+	t.Helper()
+
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		t.Fatalf("NewConnector(%s) error = %v", dsn, err)
+	}
+
+	values := reflect.ValueOf(connector).Elem().FieldByName("opts")
+	opts := make(map[string]string, values.Len())
+
+	for _, key := range values.MapKeys() {
+		opts[key.String()] = values.MapIndex(key).String()
+	}
+
+	return opts
+}
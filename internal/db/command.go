@@ -0,0 +1,160 @@
+package db
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MigrateUsage describes the arguments of the migrate command.
+const MigrateUsage = "migrate [--allow-destructive] up | down N | goto VERSION | version | force VERSION | status | drop"
+
+// ErrDestructive is returned for the operations of the migrate command losing data or changing the recorded version
+// when --allow-destructive is not given.
+var ErrDestructive = errors.New("operation may lose data or corrupt the schema version, rerun with --allow-destructive")
+
+// RunMigrateCommand runs the migrate command given by args on migrations and writes its outcome to w. Reverting
+// migrations, forcing a version and dropping the database are only run with --allow-destructive.
+func RunMigrateCommand(migrations Migrations, args []string, w io.Writer) error {
+	set := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	set.SetOutput(w)
+	allowDestructive := set.Bool("allow-destructive", false, "allow down, goto to an older version, force and drop")
+
+	// The flag may come before, between or after the arguments. Negative numbers, as in force -1, are arguments.
+	var positional []string
+
+	for {
+		if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+			if _, err := strconv.Atoi(args[0]); err == nil {
+				positional = append(positional, args[0])
+				args = args[1:]
+
+				continue
+			}
+		}
+
+		if err := set.Parse(args); err != nil {
+			return err
+		}
+
+		if set.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, set.Arg(0))
+		args = set.Args()[1:]
+	}
+
+	if len(positional) == 0 {
+		return fmt.Errorf("missing operation. Usage: %s", MigrateUsage)
+	}
+
+	op, operands := positional[0], positional[1:]
+
+	wantOperands := 0
+	if op == "down" || op == "goto" || op == "force" {
+		wantOperands = 1
+	}
+
+	if len(operands) != wantOperands {
+		return fmt.Errorf("wrong number of arguments for %s. Usage: %s", op, MigrateUsage)
+	}
+
+	switch op {
+	case "up":
+		if err := migrations.Up(); err != nil {
+			return err
+		}
+	case "down":
+		n, err := strconv.Atoi(operands[0])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of migrations %q to revert", operands[0])
+		}
+
+		if !*allowDestructive {
+			return ErrDestructive
+		}
+
+		if err = migrations.Down(n); err != nil {
+			return err
+		}
+	case "goto":
+		version, err := strconv.ParseUint(operands[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", operands[0])
+		}
+
+		current, _, err := migrations.Version()
+		if err != nil {
+			return err
+		}
+
+		if uint(version) < current && !*allowDestructive {
+			return ErrDestructive
+		}
+
+		if err = migrations.Goto(uint(version)); err != nil {
+			return err
+		}
+	case "force":
+		version, err := strconv.Atoi(operands[0])
+		if err != nil || version < -1 {
+			return fmt.Errorf("invalid version %q", operands[0])
+		}
+
+		if !*allowDestructive {
+			return ErrDestructive
+		}
+
+		if err = migrations.Force(version); err != nil {
+			return err
+		}
+	case "drop":
+		if !*allowDestructive {
+			return ErrDestructive
+		}
+
+		if err := migrations.Drop(); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w, "Dropped every table of the database")
+
+		return nil
+	case "version":
+	case "status":
+		statuses, err := migrations.Status()
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, state, s.Name)
+		}
+	default:
+		return fmt.Errorf("unknown operation %q. Usage: %s", op, MigrateUsage)
+	}
+
+	version, dirty, err := migrations.Version()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Schema version: %d", version)
+
+	if dirty {
+		fmt.Fprint(w, " (dirty)")
+	}
+
+	fmt.Fprintln(w)
+
+	return nil
+}
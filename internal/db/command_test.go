@@ -0,0 +1,170 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeMigrations records the operations run on it, the schema being at version.
+type fakeMigrations struct {
+	version uint
+	dirty   bool
+	err     error
+	calls   []string
+}
+
+func (m *fakeMigrations) record(call string) error {
+	m.calls = append(m.calls, call)
+	return m.err
+}
+
+func (m *fakeMigrations) Up() error { return m.record("up") }
+
+func (m *fakeMigrations) Down(n int) error { return m.record(fmt.Sprint("down ", n)) }
+
+func (m *fakeMigrations) Goto(version uint) error { return m.record(fmt.Sprint("goto ", version)) }
+
+func (m *fakeMigrations) Version() (uint, bool, error) {
+	m.calls = append(m.calls, "version")
+	return m.version, m.dirty, nil
+}
+
+func (m *fakeMigrations) Force(version int) error { return m.record(fmt.Sprint("force ", version)) }
+
+func (m *fakeMigrations) Drop() error { return m.record("drop") }
+
+func (m *fakeMigrations) Status() ([]MigrationStatus, error) {
+	m.calls = append(m.calls, "status")
+
+	return []MigrationStatus{
+		{Version: 1, Name: "create_users", Applied: true},
+		{Version: 2, Name: "create_subscriptions"},
+	}, m.err
+}
+
+func (m *fakeMigrations) Close() error { return nil }
+
+func TestRunMigrateCommand(t *testing.T) {
+	errMigration := errors.New("migration failed")
+
+	tests := []struct {
+		name        string
+		args        []string
+		dirty       bool
+		err         error
+		wantCalls   []string
+		wantOutput  string
+		wantErr     error
+		wantErrText string
+	}{
+		{name: "up", args: []string{"up"}, wantCalls: []string{"up", "version"}, wantOutput: "Schema version: 5\n"},
+		{
+			name: "dirty", args: []string{"version"}, dirty: true, wantCalls: []string{"version"},
+			wantOutput: "Schema version: 5 (dirty)\n",
+		},
+		{
+			name: "status", args: []string{"status"}, wantCalls: []string{"status", "version"},
+			wantOutput: "1\tapplied\tcreate_users\n2\tpending\tcreate_subscriptions\nSchema version: 5\n",
+		},
+		{
+			name: "failing up", args: []string{"up"}, err: errMigration, wantCalls: []string{"up"},
+			wantErr: errMigration,
+		},
+
+		{name: "down", args: []string{"down", "1"}, wantErr: ErrDestructive},
+		{
+			name: "down allowed", args: []string{"--allow-destructive", "down", "2"},
+			wantCalls: []string{"down 2", "version"}, wantOutput: "Schema version: 5\n",
+		},
+		{
+			name: "down of no migration", args: []string{"--allow-destructive", "down", "0"},
+			wantErrText: "invalid number",
+		},
+
+		{name: "goto older", args: []string{"goto", "3"}, wantCalls: []string{"version"}, wantErr: ErrDestructive},
+		{
+			name: "goto older allowed", args: []string{"goto", "--allow-destructive", "3"},
+			wantCalls: []string{"version", "goto 3", "version"}, wantOutput: "Schema version: 5\n",
+		},
+		{name: "goto newer", args: []string{"goto", "7"}, wantCalls: []string{"version", "goto 7", "version"}},
+		{name: "goto current", args: []string{"goto", "5"}, wantCalls: []string{"version", "goto 5", "version"}},
+		{name: "goto invalid version", args: []string{"goto", "v3"}, wantErrText: `invalid version "v3"`},
+
+		{name: "force", args: []string{"force", "4"}, wantErr: ErrDestructive},
+		{
+			name: "force allowed", args: []string{"force", "4", "--allow-destructive"},
+			wantCalls: []string{"force 4", "version"},
+		},
+		{
+			name: "force no migration allowed", args: []string{"--allow-destructive", "force", "-1"},
+			wantCalls: []string{"force -1", "version"},
+		},
+		{
+			name: "force invalid version", args: []string{"--allow-destructive", "force", "-2"},
+			wantErrText: `invalid version "-2"`,
+		},
+
+		{name: "drop", args: []string{"drop"}, wantErr: ErrDestructive},
+		{
+			name: "drop allowed", args: []string{"drop", "--allow-destructive"}, wantCalls: []string{"drop"},
+			wantOutput: "Dropped every table of the database\n",
+		},
+		{
+			name: "failing drop", args: []string{"drop", "--allow-destructive"}, err: errMigration,
+			wantCalls: []string{"drop"}, wantErr: errMigration,
+		},
+
+		{name: "no operation", args: nil, wantErrText: "missing operation"},
+		{name: "only the flag", args: []string{"--allow-destructive"}, wantErrText: "missing operation"},
+		{name: "unknown flag", args: []string{"--force", "up"}, wantErrText: "flag provided but not defined"},
+		{name: "unknown operation", args: []string{"sideways"}, wantErrText: `unknown operation "sideways"`},
+		{name: "operand of up", args: []string{"up", "1"}, wantErrText: "wrong number of arguments for up"},
+		{name: "missing operand", args: []string{"down"}, wantErrText: "wrong number of arguments for down"},
+		{
+			name: "extra operand", args: []string{"--allow-destructive", "goto", "1", "2"},
+			wantErrText: "wrong number of arguments for goto",
+		},
+		{
+			name: "operand of drop", args: []string{"drop", "--allow-destructive", "now"},
+			wantErrText: "wrong number of arguments for drop",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			migrations := &fakeMigrations{version: 5, dirty: tt.dirty, err: tt.err}
+
+			var out bytes.Buffer
+
+			err := RunMigrateCommand(migrations, tt.args, &out)
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("RunMigrateCommand(%q) error = %v; want %v", tt.args, err, tt.wantErr)
+				}
+			case tt.wantErrText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrText) {
+					t.Errorf("RunMigrateCommand(%q) error = %v; want %q", tt.args, err, tt.wantErrText)
+				}
+			case err != nil:
+				t.Errorf("RunMigrateCommand(%q) error = %v", tt.args, err)
+			}
+
+			// Nothing is run on the migrations when the command is refused.
+			if !reflect.DeepEqual(migrations.calls, tt.wantCalls) {
+				t.Errorf("RunMigrateCommand(%q) ran %q; want %q", tt.args, migrations.calls, tt.wantCalls)
+			}
+
+			if tt.wantOutput != "" && out.String() != tt.wantOutput {
+				t.Errorf("RunMigrateCommand(%q) output = %q; want %q", tt.args, out.String(), tt.wantOutput)
+			}
+		})
+	}
+}
@@ -3,22 +3,47 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 // DB provide the contract that needs to be adhered to by any database used in this service.
 type DB interface {
-	// Connect should establish the connection with DB and return a connection pool
-	Connect(ctx context.Context, dbURL string, maxConn, maxIdleConn int) (*sql.DB, error)
+	// Connect should establish the connection with DB and return a connection pool. New connections of the pool should
+	// ask dsn for the connection string, so that rotated credentials are used without restarting.
+	Connect(ctx context.Context, dsn DSN, maxConn, maxIdleConn int) (*sql.DB, error)
 
-	// Migrate should migrate the database to latest state
-	Migrate(conn *sql.DB, sourceURL, env string) error
+	// ConnectReplicas should open a connection pool for each read replica of dsns and check them, each within timeout.
+	// Replicas failing their check, as they are unreachable or lag behind by more than maxLag, are not read from until
+	// they pass one.
+	ConnectReplicas(ctx context.Context, dsns []DSN, maxConn, maxIdleConn int, timeout,
+		maxLag time.Duration) *Replicas
+
+	// Migrate should migrate the database to latest state. It should never drop or revert anything. The migrations
+	// of sourceURL are run, the ones embedded in the binary if empty.
+	Migrate(dsn DSN, sourceURL string) error
+
+	// Migrations should return the migrations of sourceURL, to be run one operation at a time
+	Migrations(dsn DSN, sourceURL string) (Migrations, error)
+
+	// CheckMigrations should fail if the schema of the database is dirty or behind the latest migration
+	CheckMigrations(ctx context.Context, conn *sql.DB, sourceURL string) error
+}
+
+// DSN provides the connection string of the database.
+type DSN interface {
+	// DSN returns the connection string of a new connection.
+	DSN(ctx context.Context) (string, error)
+
+	// Invalidate tells that the database rejected the credentials of the last connection string, so that they are
+	// fetched again.
+	Invalidate()
 }
 
-func New(environment string) DB {
-	switch environment {
-	case "local":
-		return &Postgres{}
-	default:
-		return &Postgres{}
-	}
+// StaticDSN is a connection string that never changes.
+type StaticDSN string
+
+func (s StaticDSN) DSN(context.Context) (string, error) {
+	return string(s), nil
 }
+
+func (s StaticDSN) Invalidate() {}
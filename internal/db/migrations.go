@@ -0,0 +1,175 @@
+package db
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/httpfs"
+
+	// file system migrations, for the source URLs given instead of the embedded migrations.
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// migrationsDir is the directory of the embedded migrations.
+const migrationsDir = "migrations/postgres"
+
+// migrationFiles are the migrations of the databases, built into the binary so that it runs from any directory.
+//
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// openSource opens the migrations of sourceURL, e.g. file://path/to/migrations, or the embedded ones if empty.
+func openSource(sourceURL string) (source.Driver, error) {
+	if sourceURL == "" {
+		src, err := httpfs.New(http.FS(migrationFiles), migrationsDir)
+		if err != nil {
+			return nil, fmt.Errorf("%w; Unable to open embedded migrations", err)
+		}
+
+		return src, nil
+	}
+
+	src, err := source.Open(sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w; Unable to open migration source", err)
+	}
+
+	return src, nil
+}
+
+// Migrations runs the migrations of a database one operation at a time, e.g. from the migrate command.
+type Migrations interface {
+	// Up applies every pending migration.
+	Up() error
+
+	// Down reverts the last n applied migrations.
+	Down(n int) error
+
+	// Goto applies or reverts migrations until the schema is at version.
+	Goto(version uint) error
+
+	// Version returns the version of the last applied migration, 0 if none, and whether it failed half way and left
+	// the schema dirty.
+	Version() (version uint, dirty bool, err error)
+
+	// Force sets the version without running any migration and clears the dirty flag, once a failed migration has been
+	// fixed by hand. -1 means no migration applied.
+	Force(version int) error
+
+	// Drop drops every table of the database.
+	Drop() error
+
+	// Status lists the migrations of the source, oldest first, along with whether they are applied.
+	Status() ([]MigrationStatus, error)
+
+	// Close releases the connection the migrations are run on.
+	Close() error
+}
+
+// MigrationStatus is a migration of the source and whether it is applied.
+type MigrationStatus struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+type postgresMigrations struct {
+	m         *migrate.Migrate
+	sourceURL string
+}
+
+func (p *Postgres) Migrations(dsn DSN, sourceURL string) (Migrations, error) {
+	m, err := newMigrate(dsn, sourceURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &postgresMigrations{m: m, sourceURL: sourceURL}, nil
+}
+
+func (p *postgresMigrations) Up() error {
+	return ignoreNoChange(p.m.Up())
+}
+
+func (p *postgresMigrations) Down(n int) error {
+	return ignoreNoChange(p.m.Steps(-n))
+}
+
+func (p *postgresMigrations) Goto(version uint) error {
+	return ignoreNoChange(p.m.Migrate(version))
+}
+
+func (p *postgresMigrations) Version() (uint, bool, error) {
+	version, dirty, err := p.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
+
+func (p *postgresMigrations) Force(version int) error {
+	return p.m.Force(version)
+}
+
+func (p *postgresMigrations) Drop() error {
+	return p.m.Drop()
+}
+
+func (p *postgresMigrations) Status() ([]MigrationStatus, error) {
+	current, _, err := p.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := openSource(p.sourceURL)
+	if err != nil {
+		return nil, err
+	}
+
+	defer src.Close()
+
+	var statuses []MigrationStatus
+
+	version, err := src.First()
+
+	for err == nil {
+		r, name, readErr := src.ReadUp(version)
+		if readErr != nil {
+			return nil, fmt.Errorf("%w; Unable to read migration %d", readErr, version)
+		}
+
+		r.Close()
+
+		statuses = append(statuses, MigrationStatus{Version: version, Name: name, Applied: version <= current})
+		version, err = src.Next(version)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w; Unable to read migrations", err)
+	}
+
+	return statuses, nil
+}
+
+func (p *postgresMigrations) Close() error {
+	sourceErr, databaseErr := p.m.Close()
+	if sourceErr != nil {
+		return sourceErr
+	}
+
+	return databaseErr
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS set_updated_time ON subscriptions;
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS password_history
+(
+    id         bigserial PRIMARY KEY,
+    user_id    bigint                   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    password   text                     NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history (user_id, id DESC);
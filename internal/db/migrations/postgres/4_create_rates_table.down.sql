@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_rates_pair_observed_at;
+DROP TABLE IF EXISTS rates;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS rates
+(
+    id          bigserial PRIMARY KEY,
+    pair        text                     NOT NULL,
+    rate        numeric                  NOT NULL,
+    observed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rates_pair_observed_at ON rates (pair, observed_at DESC);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_audit_events_subject_id;
+DROP INDEX IF EXISTS idx_audit_events_actor_id;
+DROP INDEX IF EXISTS idx_audit_events_type;
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS reject_audit_event_change();
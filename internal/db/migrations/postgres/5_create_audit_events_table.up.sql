@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS audit_events
+(
+    id          bigserial PRIMARY KEY,
+    type        text                     NOT NULL,
+    actor_id    bigint,
+    subject_id  bigint,
+    ip          text                     NOT NULL DEFAULT '',
+    user_agent  text                     NOT NULL DEFAULT '',
+    changes     jsonb,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    prev_hash   text,
+    hash        text
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events (type, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_subject_id ON audit_events (subject_id, id DESC);
+
+CREATE OR REPLACE FUNCTION reject_audit_event_change()
+    RETURNS TRIGGER AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE
+    ON audit_events
+    FOR EACH ROW
+EXECUTE PROCEDURE reject_audit_event_change();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE
+    ON audit_events
+    FOR EACH STATEMENT
+EXECUTE PROCEDURE reject_audit_event_change();
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS password_history;
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+    email               TEXT     NOT NULL UNIQUE COLLATE NOCASE,
+    password            TEXT     NOT NULL,
+    token               TEXT,
+    token_creation_time DATETIME NOT NULL,
+    secret              TEXT              DEFAULT 'All your base are belong to us',
+    created_at          DATETIME NOT NULL,
+    updated_at          DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_token ON users (token);
+
+CREATE TABLE IF NOT EXISTS subscriptions
+(
+    user_id    INTEGER  NOT NULL REFERENCES users (id),
+    pair       TEXT     NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME,
+
+    PRIMARY KEY (user_id, pair)
+);
+
+CREATE TABLE IF NOT EXISTS password_history
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    password   TEXT     NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history (user_id, id DESC);
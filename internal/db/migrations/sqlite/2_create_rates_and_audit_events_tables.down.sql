@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS audit_events_no_delete;
+DROP TRIGGER IF EXISTS audit_events_no_update;
+DROP INDEX IF EXISTS idx_audit_events_subject_id;
+DROP INDEX IF EXISTS idx_audit_events_actor_id;
+DROP INDEX IF EXISTS idx_audit_events_type;
+DROP TABLE IF EXISTS audit_events;
+DROP INDEX IF EXISTS idx_rates_pair_observed_at;
+DROP TABLE IF EXISTS rates;
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS rates
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    pair        TEXT     NOT NULL,
+    rate        REAL     NOT NULL,
+    observed_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rates_pair_observed_at ON rates (pair, observed_at DESC);
+
+CREATE TABLE IF NOT EXISTS audit_events
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    type        TEXT     NOT NULL,
+    actor_id    INTEGER,
+    subject_id  INTEGER,
+    ip          TEXT     NOT NULL DEFAULT '',
+    user_agent  TEXT     NOT NULL DEFAULT '',
+    changes     TEXT,
+    occurred_at DATETIME NOT NULL,
+    prev_hash   TEXT,
+    hash        TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events (type, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_subject_id ON audit_events (subject_id, id DESC);
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update
+    BEFORE UPDATE
+    ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_delete
+    BEFORE DELETE
+    ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
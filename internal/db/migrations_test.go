@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+func TestEveryMigrationHasADown(t *testing.T) {
+	for _, dir := range []string{migrationsDir, sqliteMigrationsDir} {
+		t.Run(dir, func(t *testing.T) {
+			testEveryMigrationHasADown(t, dir)
+		})
+	}
+}
+
+func testEveryMigrationHasADown(t *testing.T, dir string) {
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+
+	directions := make(map[uint]map[source.Direction]string)
+
+	for _, e := range entries {
+		m, err := source.DefaultParse(e.Name())
+		if err != nil {
+			t.Errorf("migration %s: %v", e.Name(), err)
+			continue
+		}
+
+		if directions[m.Version] == nil {
+			directions[m.Version] = make(map[source.Direction]string)
+		}
+
+		if other, ok := directions[m.Version][m.Direction]; ok {
+			t.Errorf("migrations %s and %s share version %d", other, e.Name(), m.Version)
+		}
+
+		directions[m.Version][m.Direction] = e.Name()
+	}
+
+	if len(directions) == 0 {
+		t.Fatal("no migration embedded")
+	}
+
+	for version, files := range directions {
+		if files[source.Up] == "" {
+			t.Errorf("migration %d has no up migration", version)
+		}
+
+		if files[source.Down] == "" {
+			t.Errorf("migration %s has no down migration", files[source.Up])
+		}
+	}
+}
+
+func TestLatestEmbeddedMigration(t *testing.T) {
+	entries, err := fs.ReadDir(migrationFiles, migrationsDir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+
+	var want uint
+
+	for _, e := range entries {
+		if m, err := source.DefaultParse(e.Name()); err == nil && m.Version > want {
+			want = m.Version
+		}
+	}
+
+	got, err := latestMigration("")
+	if err != nil || got != want {
+		t.Errorf("latestMigration() = %d, %v; want %d", got, err, want)
+	}
+}
+
+func TestOpenSQLite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "alpha-flow.db")
+
+	for i := 0; i < 2; i++ {
+		conn, err := OpenSQLite(ctx, path)
+		if err != nil {
+			t.Fatalf("OpenSQLite() #%d error = %v", i, err)
+		}
+
+		var version uint
+		if err = conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil || version != 2 {
+			t.Errorf("user_version = %d, %v; want 2", version, err)
+		}
+
+		conn.Close()
+	}
+}
@@ -3,20 +3,20 @@ package db
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
-	// sql driver for database/sql.
-	_ "github.com/lib/pq"
-	"github.com/mattes/migrate"
-	"github.com/mattes/migrate/database"
-	"github.com/mattes/migrate/database/postgres"
+	"github.com/maknahar/alpha-flow/internal/metrics"
 
-	// file system migrations.
-	_ "github.com/mattes/migrate/source/file"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/lib/pq"
 )
 
 //nolint:gochecknoglobals
@@ -27,15 +27,12 @@ var (
 type Postgres struct {
 }
 
-func (p *Postgres) Connect(ctx context.Context, dbURL string, maxConn, maxIdleConn int) (*sql.DB, error) {
+func (p *Postgres) Connect(ctx context.Context, dsn DSN, maxConn, maxIdleConn int) (*sql.DB, error) {
 	if db != nil {
 		return db, nil
 	}
 
-	db, err := sql.Open("postgres", dbURL)
-	if err != nil {
-		return nil, fmt.Errorf("%w; Unable to open db connection", err)
-	}
+	db := sql.OpenDB(connector{dsn: dsn})
 
 	if maxConn == 0 {
 		maxConn = 25
@@ -45,7 +42,7 @@ func (p *Postgres) Connect(ctx context.Context, dbURL string, maxConn, maxIdleCo
 	db.SetMaxIdleConns(maxIdleConn)
 
 	for i := 0; ; i++ {
-		err = db.PingContext(ctx)
+		err := db.PingContext(ctx)
 		if err != nil {
 			if i < 10 {
 				logrus.WithError(err).Warn("Unable to ping database. Retrying after 1 second")
@@ -59,24 +56,96 @@ func (p *Postgres) Connect(ctx context.Context, dbURL string, maxConn, maxIdleCo
 		break
 	}
 
+	if err := metrics.RegisterDB(db, "primary"); err != nil {
+		logrus.WithError(err).Warn("Unable to register database pool metrics")
+	}
+
 	return db, nil
 }
 
-func (p *Postgres) Migrate(conn *sql.DB, sourceURL, env string) error {
+// invalidPassword is the code of the error Postgres fails a connection with when its credentials are rejected.
+const invalidPassword = "28P01"
+
+// connector opens the connections of the pool with the current connection string of dsn. If the credentials are
+// rejected, e.g. as they were rotated, they are fetched again once.
+type connector struct {
+	dsn DSN
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	for i := 0; ; i++ {
+		dsn, err := c.dsn.DSN(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%w; Unable to get database credentials", err)
+		}
+
+		pqConnector, err := pq.NewConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := pqConnector.Connect(ctx)
+
+		var pqErr *pq.Error
+		if i == 0 && errors.As(err, &pqErr) && pqErr.Code == invalidPassword {
+			c.dsn.Invalidate()
+			continue
+		}
+
+		return conn, err
+	}
+}
+
+func (c connector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// newMigrate returns a migrate instance running the migrations of sourceURL, the embedded ones if empty. It holds a
+// connection of its own until closed.
+func newMigrate(dsn DSN, sourceURL string) (*migrate.Migrate, error) {
+	src, err := openSource(sourceURL)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := sql.OpenDB(connector{dsn: dsn})
+	conn.SetMaxOpenConns(1)
+
 	driver, err := postgres.WithInstance(conn, &postgres.Config{})
 	if err != nil {
-		return fmt.Errorf("%w; Unable to create migration instance", err)
+		conn.Close()
+		src.Close()
+
+		return nil, fmt.Errorf("%w; Unable to create migration instance", err)
 	}
 
-	if sourceURL == "" {
-		sourceURL = "file://internal/db/migrations/postgres"
+	m, err := migrate.NewWithInstance("migrations", src, "postgres", driver)
+	if err != nil {
+		driver.Close()
+		src.Close()
+
+		return nil, fmt.Errorf("%w; Unable to create Migrate instance for database", err)
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(sourceURL, "postgres", driver)
+	return m, nil
+}
+
+// The attempts at migrating while another instance holds the lock of the migrations, and the time between them.
+const (
+	migrateLockAttempts = 5
+	migrateLockRetry    = time.Minute
+)
+
+// Migrate applies the pending migrations of sourceURL, the embedded ones if empty. It fails rather than leave the
+// schema behind, e.g. when the lock is still held by another instance after migrateLockAttempts.
+func (p *Postgres) Migrate(dsn DSN, sourceURL string) error {
+	m, err := newMigrate(dsn, sourceURL)
 	if err != nil {
-		return fmt.Errorf("%w; Unable to create Migrate instance for database", err)
+		return err
 	}
 
+	defer m.Close()
+
 	version, _, err := m.Version()
 	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
 		return fmt.Errorf("%w; unable to get existing migration version for database", err)
@@ -84,41 +153,100 @@ func (p *Postgres) Migrate(conn *sql.DB, sourceURL, env string) error {
 
 	logrus.WithField("version", version).Infoln("current schema version of database")
 
-	if env == "Local" {
-		err = m.Drop()
-		if err != nil {
+	err = upUnlocked(m.Up, migrateLockAttempts, migrateLockRetry)
+
+	switch {
+	case errors.Is(err, migrate.ErrNoChange):
+		logrus.Infoln("No pending migrations in database")
+	case err != nil:
+		return fmt.Errorf("%w; error to run migration in database", err)
+	}
+
+	newVersion, _, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("%w; unable to get new migration version for database", err)
+	}
+
+	if newVersion != version {
+		logrus.WithField("old", version).WithField("new", newVersion).Infoln("Migration Successful")
+	}
+
+	return nil
+}
+
+// upUnlocked runs up until it fails with another error than the lock being held, by another instance starting at the
+// same time, at most attempts times, wait apart.
+func upUnlocked(up func() error, attempts int, wait time.Duration) error {
+	for i := 1; ; i++ {
+		err := up()
+		if !errors.Is(err, migrate.ErrLocked) && !errors.Is(err, database.ErrLocked) {
 			return err
 		}
 
-		logrus.Warn("Dropped database")
+		if i >= attempts {
+			return fmt.Errorf("%w; database still locked", err)
+		}
+
+		logrus.WithField("retry_in", wait.String()).Warn("database locked. Assuming another instance working on it.")
+		time.Sleep(wait)
 	}
+}
 
-	for i := 0; i < 5; i++ {
-		err = m.Up()
-		if err != nil {
-			switch err {
-			case migrate.ErrNoChange:
-				logrus.Infoln("No pending migrations in database")
-			case migrate.ErrLocked, database.ErrLocked:
-				logrus.Warn("database locked. Assuming another instance working on it. Will retry in a minute")
-				time.Sleep(time.Minute)
+func (p *Postgres) CheckMigrations(ctx context.Context, conn *sql.DB, sourceURL string) error {
+	latest, err := latestMigration(sourceURL)
+	if err != nil {
+		return err
+	}
 
-				continue
-			default:
-				return fmt.Errorf("%w; error to run migration in database", err)
-			}
-		}
+	var (
+		version uint
+		dirty   bool
+	)
 
-		break
+	err = conn.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("no migration applied")
 	}
 
-	newVersion, dirty, err := m.Version()
 	if err != nil {
-		logrus.WithField("dirty", dirty).
-			WithError(err).Panic("unable to get new migration version for database")
-	} else if newVersion != version {
-		logrus.WithField("old", version).WithField("new", newVersion).Infoln("Migration Successful")
+		return fmt.Errorf("%w; unable to get migration version of database", err)
+	}
+
+	if dirty {
+		return fmt.Errorf("migration %d failed and left the database dirty", version)
+	}
+
+	if version < latest {
+		return fmt.Errorf("schema version %d is behind latest migration %d", version, latest)
 	}
 
 	return nil
 }
+
+// latestMigration returns the version of the last migration of sourceURL.
+func latestMigration(sourceURL string) (uint, error) {
+	src, err := openSource(sourceURL)
+	if err != nil {
+		return 0, err
+	}
+
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, fmt.Errorf("%w; Unable to read first migration", err)
+	}
+
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("%w; Unable to read migration after %d", err, version)
+		}
+
+		version = next
+	}
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+)
+
+func TestUpUnlocked(t *testing.T) {
+	failure := errors.New("syntax error")
+
+	tests := []struct {
+		name      string
+		results   []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "migrated", results: []error{nil}, wantCalls: 1},
+		{name: "no change", results: []error{migrate.ErrNoChange}, wantCalls: 1, wantErr: migrate.ErrNoChange},
+		{name: "failed", results: []error{failure}, wantCalls: 1, wantErr: failure},
+		{name: "unlocked", results: []error{migrate.ErrLocked, database.ErrLocked, nil}, wantCalls: 3},
+		{
+			name: "still locked", results: []error{migrate.ErrLocked, database.ErrLocked, migrate.ErrLocked},
+			wantCalls: 3, wantErr: migrate.ErrLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			up := func() error {
+				calls++
+				return tt.results[calls-1]
+			}
+
+			if err := upUnlocked(up, 3, 0); !errors.Is(err, tt.wantErr) {
+				t.Errorf("upUnlocked() error = %v; want %v", err, tt.wantErr)
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("upUnlocked() ran up %d times; want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/maknahar/alpha-flow/internal/metrics"
+)
+
+var (
+	// ErrNoHealthyReplica is returned by Replicas.Ping while every replica is failing its health check.
+	ErrNoHealthyReplica = errors.New("no healthy read replica")
+
+	// ErrReplicaLagging fails the health check of a replica further behind the primary than the maximum lag.
+	ErrReplicaLagging = errors.New("read replica lagging")
+)
+
+// replicationLagQuery returns the time in seconds by which a replica is behind its primary: 0 once it replayed all
+// it received, otherwise the age of the last transaction replayed. It is NULL while nothing was replayed yet.
+const replicationLagQuery = `SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END`
+
+// Replicas are the read replicas of the database. They are health checked, so that the unreachable ones and those
+// lagging behind the primary by more than maxLag are not read from until they are back. A replica checked healthy
+// may fall behind before the next check, so its reads are at most maxLag and the check interval old.
+type Replicas struct {
+	replicas []*replica
+	next     uint32
+	maxLag   time.Duration
+
+	// lag returns the replication lag of a replica.
+	lag func(ctx context.Context, conn *sql.DB) (time.Duration, error)
+}
+
+type replica struct {
+	name string
+	conn *sql.DB
+
+	// healthy is 1 if the last health check passed, 0 if it failed and -1 before the first one.
+	healthy int32
+}
+
+func (p *Postgres) ConnectReplicas(ctx context.Context, dsns []DSN, maxConn, maxIdleConn int,
+	timeout, maxLag time.Duration) *Replicas {
+	r := &Replicas{maxLag: maxLag, lag: postgresLag}
+
+	for i, dsn := range dsns {
+		conn := sql.OpenDB(connector{dsn: dsn})
+		conn.SetMaxOpenConns(maxConn)
+		conn.SetMaxIdleConns(maxIdleConn)
+
+		name := "replica_" + strconv.Itoa(i+1)
+		if err := metrics.RegisterDB(conn, name); err != nil {
+			logrus.WithError(err).Warn("Unable to register database pool metrics")
+		}
+
+		r.replicas = append(r.replicas, &replica{name: name, conn: conn, healthy: -1})
+	}
+
+	r.Check(ctx, timeout)
+
+	return r
+}
+
+// Reader returns the pool of a healthy replica, taking turns between them, or nil if none is healthy.
+func (r *Replicas) Reader() *sql.DB {
+	n := uint32(len(r.replicas))
+	start := atomic.AddUint32(&r.next, 1)
+
+	for i := uint32(0); i < n; i++ {
+		if rep := r.replicas[(start+i)%n]; atomic.LoadInt32(&rep.healthy) == 1 {
+			return rep.conn
+		}
+	}
+
+	return nil
+}
+
+// postgresLag returns the replication lag of the Postgres replica conn.
+func postgresLag(ctx context.Context, conn *sql.DB) (time.Duration, error) {
+	var seconds sql.NullFloat64
+
+	if err := conn.QueryRowContext(ctx, replicationLagQuery).Scan(&seconds); err != nil {
+		return 0, err
+	}
+
+	if !seconds.Valid {
+		return 0, errors.New("replication lag unknown, no transaction replayed yet")
+	}
+
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
+
+// Check checks every replica, each within timeout, and records whether it is healthy: reachable and not lagging
+// behind the primary by more than the maximum lag.
+func (r *Replicas) Check(ctx context.Context, timeout time.Duration) {
+	for _, rep := range r.replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := r.check(checkCtx, rep)
+		cancel()
+
+		healthy := int32(0)
+		if err == nil {
+			healthy = 1
+		}
+
+		if atomic.SwapInt32(&rep.healthy, healthy) == healthy {
+			continue
+		}
+
+		if err != nil {
+			logrus.WithError(err).WithField("pool", rep.name).Warn("Read replica unhealthy. Reading from the primary")
+		} else {
+			logrus.WithField("pool", rep.name).Info("Read replica healthy")
+		}
+	}
+}
+
+// check fails if rep is unreachable or lagging.
+func (r *Replicas) check(ctx context.Context, rep *replica) error {
+	if err := rep.conn.PingContext(ctx); err != nil {
+		return err
+	}
+
+	lag, err := r.lag(ctx, rep.conn)
+	if err != nil {
+		return fmt.Errorf("%w; unable to get the replication lag", err)
+	}
+
+	if lag > r.maxLag {
+		return fmt.Errorf("%w: %s behind, at most %s", ErrReplicaLagging, lag, r.maxLag)
+	}
+
+	return nil
+}
+
+// Watch checks the replicas every interval until ctx is done.
+func (r *Replicas) Watch(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Check(ctx, timeout)
+		}
+	}
+}
+
+// Ping fails if no replica passed its last health check, the reads all going to the primary.
+func (r *Replicas) Ping(context.Context) error {
+	for _, rep := range r.replicas {
+		if atomic.LoadInt32(&rep.healthy) == 1 {
+			return nil
+		}
+	}
+
+	return ErrNoHealthyReplica
+}
+
+// Close closes the pools of the replicas.
+func (r *Replicas) Close() error {
+	var err error
+
+	for _, rep := range r.replicas {
+		if cErr := rep.conn.Close(); cErr != nil {
+			err = cErr
+		}
+	}
+
+	return err
+}
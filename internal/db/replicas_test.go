@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplicasHealth(t *testing.T) {
+	ctx := context.Background()
+
+	up, err := OpenSQLite(ctx, filepath.Join(t.TempDir(), "up.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite() error = %v", err)
+	}
+
+	defer up.Close()
+
+	down, err := OpenSQLite(ctx, filepath.Join(t.TempDir(), "down.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite() error = %v", err)
+	}
+
+	// SQLite has no replication, so the lag of each replica is set by the test.
+	lags := map[*sql.DB]time.Duration{}
+	r := &Replicas{
+		replicas: []*replica{{name: "up", conn: up, healthy: -1}, {name: "down", conn: down, healthy: -1}},
+		maxLag:   time.Second,
+		lag: func(_ context.Context, conn *sql.DB) (time.Duration, error) {
+			return lags[conn], nil
+		},
+	}
+
+	if r.Reader() != nil || !errors.Is(r.Ping(ctx), ErrNoHealthyReplica) {
+		t.Error("replicas never checked are read from; want none until checked")
+	}
+
+	r.Check(ctx, time.Second)
+
+	for i := 0; i < 4; i++ {
+		if got := r.Reader(); got != up && got != down {
+			t.Fatalf("Reader() = %v; want a replica", got)
+		}
+	}
+
+	// A replica lagging behind by more than the maximum is not read from until it catches up.
+	lags[down] = 2 * time.Second
+	r.Check(ctx, time.Second)
+
+	for i := 0; i < 4; i++ {
+		if got := r.Reader(); got != up {
+			t.Errorf("Reader() with a replica lagging = %v; want the other one", got)
+		}
+	}
+
+	lags[down] = time.Second
+	r.Check(ctx, time.Second)
+
+	if got, other := r.Reader(), r.Reader(); got == other {
+		t.Errorf("Reader() once caught up = %v, %v; want both replicas in turn", got, other)
+	}
+
+	down.Close()
+	r.Check(ctx, time.Second)
+
+	for i := 0; i < 4; i++ {
+		if got := r.Reader(); got != up {
+			t.Errorf("Reader() with a replica down = %v; want the other one", got)
+		}
+	}
+
+	if err = r.Ping(ctx); err != nil {
+		t.Errorf("Ping() with a healthy replica error = %v", err)
+	}
+
+	up.Close()
+	r.Check(ctx, time.Second)
+
+	if r.Reader() != nil || !errors.Is(r.Ping(ctx), ErrNoHealthyReplica) {
+		t.Error("Reader() with every replica down returned one; want nil")
+	}
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/sirupsen/logrus"
+
+	"github.com/maknahar/alpha-flow/internal/metrics"
+
+	// SQLite driver without cgo, registered as "sqlite".
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrationsDir is the directory of the embedded SQLite migrations.
+const sqliteMigrationsDir = "migrations/sqlite"
+
+// OpenSQLite opens the SQLite database at path, a new one in memory if ":memory:", and applies its pending migrations.
+// The pool holds a single connection: SQLite serializes the writes anyway, and every connection to ":memory:" opens
+// a database of its own.
+func OpenSQLite(ctx context.Context, path string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("%w; Unable to open SQLite database", err)
+	}
+
+	conn.SetMaxOpenConns(1)
+	conn.SetMaxIdleConns(1)
+	conn.SetConnMaxLifetime(0)
+
+	if _, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%w; Unable to enable SQLite foreign keys", err)
+	}
+
+	if err = migrateSQLite(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err = metrics.RegisterDB(conn, "sqlite"); err != nil {
+		logrus.WithError(err).Warn("Unable to register database pool metrics")
+	}
+
+	return conn, nil
+}
+
+// migrateSQLite applies the embedded SQLite migrations newer than the user_version of the database, each in a
+// transaction along with the new user_version. They are never reverted.
+func migrateSQLite(ctx context.Context, conn *sql.DB) error {
+	entries, err := fs.ReadDir(migrationFiles, sqliteMigrationsDir)
+	if err != nil {
+		return fmt.Errorf("%w; Unable to read embedded SQLite migrations", err)
+	}
+
+	var ups []*source.Migration
+
+	for _, e := range entries {
+		m, err := source.DefaultParse(e.Name())
+		if err != nil {
+			return fmt.Errorf("%w; Unable to parse SQLite migration %s", err, e.Name())
+		}
+
+		if m.Direction == source.Up {
+			ups = append(ups, m)
+		}
+	}
+
+	sort.Slice(ups, func(i, j int) bool { return ups[i].Version < ups[j].Version })
+
+	var current uint
+	if err = conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&current); err != nil {
+		return fmt.Errorf("%w; Unable to read SQLite schema version", err)
+	}
+
+	for _, m := range ups {
+		if m.Version <= current {
+			continue
+		}
+
+		statements, err := fs.ReadFile(migrationFiles, path.Join(sqliteMigrationsDir, m.Raw))
+		if err != nil {
+			return fmt.Errorf("%w; Unable to read SQLite migration %s", err, m.Raw)
+		}
+
+		if err = applySQLiteMigration(ctx, conn, m.Version, string(statements)); err != nil {
+			return fmt.Errorf("%w; Unable to apply SQLite migration %s", err, m.Raw)
+		}
+	}
+
+	return nil
+}
+
+func applySQLiteMigration(ctx context.Context, conn *sql.DB, version uint, statements string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err = tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+
+	// PRAGMA does not take parameters.
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,83 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+
+	"github.com/maknahar/alpha-flow/internal/logging"
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+//nolint:gochecknoglobals
+var (
+	ErrInvalidQuery     = services.NewError(services.CodeInvalidQuery, http.StatusBadRequest, "invalid query")
+	ErrQueryTooComplex  = services.NewError(services.CodeQueryTooComplex, http.StatusBadRequest, "query too complex")
+	ErrUnknownOperation = services.NewError(services.CodeInvalidQuery, http.StatusBadRequest, "unknown operation")
+)
+
+// requestErrors returns the errors that failed a request before execution, each tagged with the code of appErr.
+func requestErrors(appErr *services.Error, errs ...gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	if len(errs) == 0 {
+		errs = []gqlerrors.FormattedError{gqlerrors.NewFormattedError(appErr.Message)}
+	}
+
+	for i := range errs {
+		errs[i].Extensions = extensions(appErr)
+	}
+
+	return errs
+}
+
+// formatErrors replaces the message of every error raised by a resolver with that of the application error in its
+// chain and adds the error code and field errors as extensions, the same way routes.WriteError does for REST. Any
+// other resolver error becomes an internal error without its message. Errors the executor raises about the request
+// itself, such as invalid variables, are tagged as ErrInvalidQuery.
+func formatErrors(ctx context.Context, errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	for i := range errs {
+		err := originalError(errs[i])
+		if err == nil {
+			errs[i].Extensions = extensions(ErrInvalidQuery)
+			continue
+		}
+
+		appErr := services.ErrInternal.Wrap(err)
+		errors.As(err, &appErr)
+
+		if appErr.Status >= http.StatusInternalServerError {
+			logging.FromContext(ctx).WithError(err).WithField("path", errs[i].Path).WithField("code", appErr.Code).
+				Error("Error in resolving field")
+		}
+
+		errs[i].Message = appErr.Message
+		errs[i].Extensions = extensions(appErr)
+	}
+
+	return errs
+}
+
+// originalError returns the error returned by a resolver, unwrapping the errors the executor wraps it in.
+func originalError(err error) error {
+	for {
+		switch e := err.(type) {
+		case gqlerrors.FormattedError:
+			err = e.OriginalError()
+		case *gqlerrors.Error:
+			err = e.OriginalError
+		default:
+			return err
+		}
+	}
+}
+
+func extensions(appErr *services.Error) map[string]interface{} {
+	ext := map[string]interface{}{"code": appErr.Code}
+
+	if len(appErr.Details) > 0 {
+		ext["errors"] = appErr.Details
+	}
+
+	return ext
+}
@@ -0,0 +1,191 @@
+package graph
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+// defaultListSize is the number of items a list field without a limit is assumed to return.
+const defaultListSize = 10
+
+// cost walks the selections of an operation to compute its depth and complexity. Every field costs 1 plus the cost
+// of its selections, multiplied by the number of items it may return if it is a list. The size of a list is taken
+// from its limit argument or the length of a list argument, falling back to defaultListSize. Introspection fields
+// are not counted.
+type cost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// checkLimits returns ErrQueryTooComplex if op is nested deeper than maxDepth or costs more than maxComplexity.
+// Limits of zero or less are not enforced.
+func checkLimits(schema *graphql.Schema, doc *ast.Document, op *ast.OperationDefinition,
+	variables map[string]interface{}, maxDepth, maxComplexity int) error {
+	depth, complexity := measure(schema, doc, op, variables)
+
+	var details []services.FieldError
+
+	if maxDepth > 0 && depth > maxDepth {
+		details = append(details, services.FieldError{Field: "query", Rule: "depth",
+			Message: "is nested " + strconv.Itoa(depth) + " levels deep, at most " + strconv.Itoa(maxDepth) +
+				" are allowed"})
+	}
+
+	if maxComplexity > 0 && complexity > maxComplexity {
+		details = append(details, services.FieldError{Field: "query", Rule: "complexity",
+			Message: "has a complexity of " + strconv.Itoa(complexity) + ", at most " + strconv.Itoa(maxComplexity) +
+				" is allowed"})
+	}
+
+	if len(details) > 0 {
+		return ErrQueryTooComplex.WithDetails(details...)
+	}
+
+	return nil
+}
+
+// measure returns the depth and complexity of op.
+func measure(schema *graphql.Schema, doc *ast.Document, op *ast.OperationDefinition,
+	variables map[string]interface{}) (depth, complexity int) {
+	c := cost{fragments: make(map[string]*ast.FragmentDefinition), variables: variables}
+
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			c.fragments[f.Name.Value] = f
+		}
+	}
+
+	root := schema.QueryType()
+	if op.Operation == ast.OperationTypeMutation {
+		root = schema.MutationType()
+	}
+
+	return c.selections(root, op.SelectionSet, 0)
+}
+
+// selections returns the depth and complexity of set, selected on parent at depth.
+func (c cost) selections(parent *graphql.Object, set *ast.SelectionSet, depth int) (maxDepth, complexity int) {
+	if set == nil || parent == nil {
+		return depth, 0
+	}
+
+	maxDepth = depth
+
+	for _, selection := range set.Selections {
+		var d, n int
+
+		switch s := selection.(type) {
+		case *ast.Field:
+			d, n = c.field(parent, s, depth)
+		case *ast.InlineFragment:
+			d, n = c.selections(parent, s.SelectionSet, depth)
+		case *ast.FragmentSpread:
+			if f, ok := c.fragments[s.Name.Value]; ok {
+				d, n = c.selections(parent, f.SelectionSet, depth)
+			}
+		}
+
+		if d > maxDepth {
+			maxDepth = d
+		}
+
+		complexity += n
+	}
+
+	return maxDepth, complexity
+}
+
+func (c cost) field(parent *graphql.Object, f *ast.Field, depth int) (maxDepth, complexity int) {
+	if strings.HasPrefix(f.Name.Value, "__") {
+		return depth, 0
+	}
+
+	def, ok := parent.Fields()[f.Name.Value]
+	if !ok {
+		return depth + 1, 1
+	}
+
+	object, _ := graphql.GetNamed(def.Type).(*graphql.Object)
+
+	maxDepth, complexity = c.selections(object, f.SelectionSet, depth+1)
+
+	if isList(def.Type) {
+		complexity *= c.listSize(def, f)
+	}
+
+	return maxDepth, complexity + 1
+}
+
+// listSize returns the number of items the list field f may return. Limits are clamped to the range the rate
+// service accepts, so that a limit of zero or less cannot cancel out the cost of the selections.
+func (c cost) listSize(def *graphql.FieldDefinition, f *ast.Field) int {
+	for _, arg := range f.Arguments {
+		value := c.value(arg.Value)
+
+		if n, ok := value.(int); ok && arg.Name.Value == "limit" {
+			return clampLimit(n)
+		}
+
+		if list, ok := value.([]interface{}); ok {
+			return len(list)
+		}
+	}
+
+	for _, arg := range def.Args {
+		if n, ok := arg.DefaultValue.(int); ok && arg.Name() == "limit" {
+			return clampLimit(n)
+		}
+	}
+
+	return defaultListSize
+}
+
+func clampLimit(n int) int {
+	switch {
+	case n < 1:
+		return 1
+	case n > services.MaxRateHistoryLimit:
+		return services.MaxRateHistoryLimit
+	default:
+		return n
+	}
+}
+
+// value returns ints and lists of an argument value, resolving variables.
+func (c cost) value(v ast.Value) interface{} {
+	switch v := v.(type) {
+	case *ast.IntValue:
+		n, _ := strconv.Atoi(v.Value)
+		return n
+	case *ast.ListValue:
+		return make([]interface{}, len(v.Values))
+	case *ast.Variable:
+		switch value := c.variables[v.Name.Value].(type) {
+		case float64:
+			// Variables are decoded from JSON, so bound the value before converting it.
+			return int(math.Max(math.Min(value, math.MaxInt32), math.MinInt32))
+		case int:
+			return value
+		case []interface{}:
+			return value
+		}
+	}
+
+	return nil
+}
+
+func isList(t graphql.Type) bool {
+	if nonNull, ok := t.(*graphql.NonNull); ok {
+		t = nonNull.OfType
+	}
+
+	_, ok := t.(*graphql.List)
+
+	return ok
+}
@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+func parse(t *testing.T, query string) (*graphql.Schema, *ast.Document, *ast.OperationDefinition) {
+	t.Helper()
+
+	schema, err := graphql.NewSchema((&Schema{}).config())
+	if err != nil {
+		t.Fatalf("NewSchema() error = %v", err)
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		t.Fatalf("Parse(%s) error = %v", query, err)
+	}
+
+	if result := graphql.ValidateDocument(&schema, doc, nil); !result.IsValid {
+		t.Fatalf("ValidateDocument(%s) errors = %v", query, result.Errors)
+	}
+
+	return &schema, doc, operation(doc, "")
+}
+
+func TestMeasure(t *testing.T) {
+	const history = `query($limit: Int) { rateHistory(pair: "BTC-USD", limit: $limit) { rate } }`
+
+	tests := []struct {
+		name           string
+		query          string
+		variables      map[string]interface{}
+		wantDepth      int
+		wantComplexity int
+	}{
+		{name: "fields", query: `{ me { id email } }`, wantDepth: 2, wantComplexity: 3},
+		{
+			name:  "limit",
+			query: `{ rateHistory(pair: "BTC-USD", limit: 5) { rate observedAt } }`, wantDepth: 2, wantComplexity: 11,
+		},
+		{name: "default limit", query: `{ rateHistory(pair: "BTC-USD") { rate } }`, wantDepth: 2, wantComplexity: 11},
+		{name: "zero limit", query: `{ rateHistory(pair: "BTC-USD", limit: 0) { rate } }`, wantDepth: 2,
+			wantComplexity: 2},
+		{name: "negative limit", query: `{ rateHistory(pair: "BTC-USD", limit: -100) { rate } }`, wantDepth: 2,
+			wantComplexity: 2},
+		{name: "limit above the maximum", query: `{ rateHistory(pair: "BTC-USD", limit: 1000) { rate } }`,
+			wantDepth: 2, wantComplexity: services.MaxRateHistoryLimit + 1},
+		{name: "limit variable", query: history, variables: map[string]interface{}{"limit": 7.0}, wantDepth: 2,
+			wantComplexity: 8},
+		{name: "negative limit variable", query: history, variables: map[string]interface{}{"limit": -5.0},
+			wantDepth: 2, wantComplexity: 2},
+		{name: "huge limit variable", query: history, variables: map[string]interface{}{"limit": 1e30},
+			wantDepth: 2, wantComplexity: services.MaxRateHistoryLimit + 1},
+		{name: "missing limit variable", query: history, wantDepth: 2, wantComplexity: 11},
+		{
+			name:      "nested lists",
+			query:     `{ me { subscriptions { rates(limit: 100) { rate pair } } } }`,
+			wantDepth: 4, wantComplexity: (2*100+1)*defaultListSize + 2,
+		},
+		{
+			name:      "negative limit beside other fields",
+			query:     `{ me { subscriptions { pair rates(limit: -1000) { rate } } } validPairs }`,
+			wantDepth: 4, wantComplexity: (1+2)*defaultListSize + 2 + 1,
+		},
+		{
+			name:      "fragments",
+			query:     `query { me { ...fields } } fragment fields on User { id ... on User { email } }`,
+			wantDepth: 2, wantComplexity: 3,
+		},
+		{name: "introspection", query: `{ __typename me { __typename id } }`, wantDepth: 2, wantComplexity: 2},
+		{
+			name:      "mutation",
+			query:     `mutation { login(email: "a@example.com", password: "open1234") { token } }`,
+			wantDepth: 2, wantComplexity: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			schema, doc, op := parse(t, tt.query)
+
+			depth, complexity := measure(schema, doc, op, tt.variables)
+			if depth != tt.wantDepth || complexity != tt.wantComplexity {
+				t.Errorf("measure(%s) = %d, %d; want %d, %d", tt.query, depth, complexity, tt.wantDepth,
+					tt.wantComplexity)
+			}
+		})
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	// The query is 4 levels deep and has a complexity of 2012.
+	const query = `{ me { subscriptions { rates(limit: 100) { rate pair } } } }`
+
+	tests := []struct {
+		name          string
+		maxDepth      int
+		maxComplexity int
+		wantRules     []string
+	}{
+		{name: "not enforced"},
+		{name: "within limits", maxDepth: 4, maxComplexity: 2012},
+		{name: "too deep", maxDepth: 3, maxComplexity: 2012, wantRules: []string{"depth"}},
+		{name: "too complex", maxDepth: 4, maxComplexity: 2011, wantRules: []string{"complexity"}},
+		{name: "both", maxDepth: 3, maxComplexity: 2011, wantRules: []string{"depth", "complexity"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			schema, doc, op := parse(t, query)
+
+			err := checkLimits(schema, doc, op, nil, tt.maxDepth, tt.maxComplexity)
+
+			if tt.wantRules == nil {
+				if err != nil {
+					t.Errorf("checkLimits() error = %v; want nil", err)
+				}
+
+				return
+			}
+
+			var appErr *services.Error
+			if !errors.Is(err, ErrQueryTooComplex) || !errors.As(err, &appErr) {
+				t.Fatalf("checkLimits() error = %v; want ErrQueryTooComplex", err)
+			}
+
+			var rules []string
+			for _, d := range appErr.Details {
+				rules = append(rules, d.Rule)
+			}
+
+			if !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("checkLimits() rules = %q; want %q", rules, tt.wantRules)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"context"
+	"sync"
+)
+
+// batchFunc loads the values of all keys at once, keyed like the keys. Keys missing from the result have no value.
+type batchFunc func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error)
+
+// loader collects the keys requested by the resolvers of a request and loads them with a single call of batch once
+// the first value is needed. Resolvers return the thunk of Load, so the executor calls every resolver of a level
+// before any thunk and all keys of the level end up in the same batch. Loaded values are cached for the request.
+type loader struct {
+	batch batchFunc
+
+	mu      sync.Mutex
+	pending []interface{}
+	results map[interface{}]loaded
+}
+
+type loaded struct {
+	value interface{}
+	err   error
+}
+
+func newLoader(batch batchFunc) *loader {
+	return &loader{batch: batch, results: make(map[interface{}]loaded)}
+}
+
+// Load queues key for the next batch and returns a thunk resolving to its value.
+func (l *loader) Load(ctx context.Context, key interface{}) func() (interface{}, error) {
+	l.mu.Lock()
+
+	if _, ok := l.results[key]; !ok {
+		l.results[key] = loaded{}
+		l.pending = append(l.pending, key)
+	}
+
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.dispatch(ctx)
+
+		r := l.results[key]
+
+		return r.value, r.err
+	}
+}
+
+// dispatch loads every pending key. The lock must be held.
+func (l *loader) dispatch(ctx context.Context) {
+	if len(l.pending) == 0 {
+		return
+	}
+
+	keys := l.pending
+	l.pending = nil
+
+	values, err := l.batch(ctx, keys)
+
+	for _, key := range keys {
+		l.results[key] = loaded{value: values[key], err: err}
+	}
+}
@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recordingBatch returns the square of every int key but 0, recording the keys of each batch.
+type recordingBatch struct {
+	batches [][]interface{}
+	err     error
+}
+
+func (b *recordingBatch) load(_ context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+	b.batches = append(b.batches, keys)
+
+	values := make(map[interface{}]interface{})
+
+	for _, k := range keys {
+		if n := k.(int); n != 0 {
+			values[k] = n * n
+		}
+	}
+
+	return values, b.err
+}
+
+func TestLoader(t *testing.T) {
+	batch := &recordingBatch{}
+	l := newLoader(batch.load)
+	ctx := context.Background()
+
+	// Every key queued before the first thunk is called ends up in the same batch, once.
+	thunks := []func() (interface{}, error){l.Load(ctx, 2), l.Load(ctx, 3), l.Load(ctx, 2), l.Load(ctx, 0)}
+
+	for i, want := range []interface{}{4, 9, 4, nil} {
+		if got, err := thunks[i](); got != want || err != nil {
+			t.Errorf("thunk #%d = %v, %v; want %v", i+1, got, err, want)
+		}
+	}
+
+	// Loaded keys are cached and only new keys make up the next batch.
+	thunks = []func() (interface{}, error){l.Load(ctx, 3), l.Load(ctx, 4)}
+
+	for i, want := range []interface{}{9, 16} {
+		if got, err := thunks[i](); got != want || err != nil {
+			t.Errorf("thunk #%d = %v, %v; want %v", i+1, got, err, want)
+		}
+	}
+
+	if want := [][]interface{}{{2, 3, 0}, {4}}; !reflect.DeepEqual(batch.batches, want) {
+		t.Errorf("batches = %v; want %v", batch.batches, want)
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	errBatch := errors.New("batch failed")
+	batch := &recordingBatch{err: errBatch}
+	l := newLoader(batch.load)
+	ctx := context.Background()
+
+	first, second := l.Load(ctx, 1), l.Load(ctx, 2)
+
+	for i, thunk := range []func() (interface{}, error){first, second, l.Load(ctx, 1)} {
+		if _, err := thunk(); !errors.Is(err, errBatch) {
+			t.Errorf("thunk #%d error = %v; want %v", i+1, err, errBatch)
+		}
+	}
+
+	if len(batch.batches) != 1 {
+		t.Errorf("batches = %v; want a single batch", batch.batches)
+	}
+}
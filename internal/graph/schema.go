@@ -0,0 +1,488 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+
+	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+// Request is a GraphQL request. Token is set by the server from the request and never bound from the body.
+type Request struct {
+	Query         string                 `json:"query" validate:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Token         string                 `json:"-"`
+}
+
+// Response is a GraphQL response. Errors carry the stable error code and field errors in their extensions.
+type Response struct {
+	Data   interface{}                `json:"data,omitempty"`
+	Errors []gqlerrors.FormattedError `json:"errors,omitempty"`
+}
+
+// Schema executes GraphQL requests against the same service layer as the REST API. Every field other than the
+// signUp and login mutations requires the bearer token of the request.
+type Schema struct {
+	schema        graphql.Schema
+	users         services.UserServicer
+	rates         services.RateServicer
+	maxDepth      int
+	maxComplexity int
+}
+
+// NewSchema builds the schema. Queries nested deeper than conf.GraphQLMaxDepth or costing more than
+// conf.GraphQLMaxComplexity are rejected before execution.
+func NewSchema(conf *configs.Conf) *Schema {
+	s := &Schema{
+		users:         services.NewUserService(services.UserDepsFrom(conf)),
+		rates:         services.NewRateService(conf),
+		maxDepth:      conf.GraphQLMaxDepth,
+		maxComplexity: conf.GraphQLMaxComplexity,
+	}
+
+	schema, err := graphql.NewSchema(s.config())
+	if err != nil {
+		panic(fmt.Sprintf("graph: invalid schema: %v", err))
+	}
+
+	s.schema = schema
+
+	return s
+}
+
+// Execute parses, validates and executes req. Failures are reported in the errors of the response.
+func (s *Schema) Execute(ctx context.Context, req *Request) *Response {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &Response{Errors: requestErrors(ErrInvalidQuery, gqlerrors.FormatError(err))}
+	}
+
+	if result := graphql.ValidateDocument(&s.schema, doc, nil); !result.IsValid {
+		return &Response{Errors: requestErrors(ErrInvalidQuery, result.Errors...)}
+	}
+
+	op := operation(doc, req.OperationName)
+	if op == nil {
+		return &Response{Errors: requestErrors(ErrUnknownOperation)}
+	}
+
+	if err = checkLimits(&s.schema, doc, op, req.Variables, s.maxDepth, s.maxComplexity); err != nil {
+		return &Response{Errors: requestErrors(err.(*services.Error))}
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       context.WithValue(ctx, requestKey{}, s.newRequest(req.Token)),
+	})
+
+	return &Response{Data: result.Data, Errors: formatErrors(ctx, result.Errors)}
+}
+
+// operation returns the operation of doc named name, or its only operation if name is empty.
+func operation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		if name == "" && found != nil {
+			return nil
+		}
+
+		if name == "" || (op.Name != nil && op.Name.Value == name) {
+			found = op
+		}
+	}
+
+	return found
+}
+
+type requestKey struct{}
+
+// request holds the state shared by the resolvers of a single request: the authenticated user and the loaders
+// batching the calls to the service layer.
+type request struct {
+	token string
+
+	authOnce sync.Once
+	user     *services.GetSecretResponseDTO
+	authErr  error
+
+	users         *loader
+	subscriptions *loader
+	latestRates   *loader
+	rateHistory   *loader
+}
+
+type historyKey struct {
+	pair  string
+	limit int
+}
+
+func (s *Schema) newRequest(token string) *request {
+	return &request{
+		token: token,
+		users: newLoader(func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+			users, err := s.users.Users(ctx, int64Keys(keys))
+
+			values := make(map[interface{}]interface{}, len(users))
+			for id, u := range users {
+				values[id] = u
+			}
+
+			return values, err
+		}),
+		subscriptions: newLoader(func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+			subscriptions, err := s.users.Subscriptions(ctx, int64Keys(keys))
+
+			values := make(map[interface{}]interface{}, len(keys))
+			for _, id := range keys {
+				values[id] = subscriptions[id.(int64)]
+			}
+
+			return values, err
+		}),
+		latestRates: newLoader(func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+			rates, err := s.rates.LatestRates(ctx, stringKeys(keys))
+
+			values := make(map[interface{}]interface{}, len(rates))
+			for pair, r := range rates {
+				values[pair] = r
+			}
+
+			return values, err
+		}),
+		rateHistory: newLoader(func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+			byLimit := make(map[int][]string)
+			for _, k := range keys {
+				key := k.(historyKey)
+				byLimit[key.limit] = append(byLimit[key.limit], key.pair)
+			}
+
+			values := make(map[interface{}]interface{}, len(keys))
+
+			for limit, pairs := range byLimit {
+				history, err := s.rates.RateHistory(ctx, pairs, limit)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, pair := range pairs {
+					values[historyKey{pair: pair, limit: limit}] = history[pair]
+				}
+			}
+
+			return values, nil
+		}),
+	}
+}
+
+func int64Keys(keys []interface{}) []int64 {
+	ids := make([]int64, len(keys))
+	for i, k := range keys {
+		ids[i] = k.(int64)
+	}
+
+	return ids
+}
+
+func stringKeys(keys []interface{}) []string {
+	s := make([]string, len(keys))
+	for i, k := range keys {
+		s[i] = k.(string)
+	}
+
+	return s
+}
+
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+// authenticate returns the user owning the token of the request. The token is checked once per request.
+func (s *Schema) authenticate(ctx context.Context) (*services.GetSecretResponseDTO, error) {
+	r := requestFrom(ctx)
+
+	r.authOnce.Do(func() {
+		if r.token == "" {
+			r.authErr = services.ErrMissingToken
+			return
+		}
+
+		r.user, r.authErr = s.users.GetSecret(ctx, r.token)
+	})
+
+	return r.user, r.authErr
+}
+
+func (s *Schema) config() graphql.SchemaConfig {
+	rate := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Rate",
+		Description: "The exchange rate of a pair observed at a point in time.",
+		Fields: graphql.Fields{
+			"pair":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"rate":       &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"observedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+
+	limitArg := &graphql.ArgumentConfig{
+		Type:         graphql.Int,
+		DefaultValue: services.DefaultRateHistoryLimit,
+		Description:  "Number of rates to return, at most " + strconv.Itoa(services.MaxRateHistoryLimit) + ".",
+	}
+
+	subscription := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Subscription",
+		Description: "A pair the user subscribed to.",
+		Fields: graphql.Fields{
+			"pair":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"latestRate": &graphql.Field{
+				Type:        rate,
+				Description: "The current rate of the pair, null if the pair provider does not offer it.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					pair := p.Source.(services.SubscriptionDTO).Pair
+
+					return requestFrom(p.Context).latestRates.Load(p.Context, pair), nil
+				},
+			},
+			"rates": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(rate)),
+				Description: "The most recently observed rates of the pair, newest first.",
+				Args:        graphql.FieldConfigArgument{"limit": limitArg},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					key := historyKey{pair: p.Source.(services.SubscriptionDTO).Pair, limit: p.Args["limit"].(int)}
+
+					return requestFrom(p.Context).rateHistory.Load(p.Context, key), nil
+				},
+			},
+		},
+	})
+
+	user := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"email":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"createdAt": &graphql.Field{Type: graphql.DateTime},
+			"updatedAt": &graphql.Field{Type: graphql.DateTime},
+			"secret": &graphql.Field{
+				Type:        graphql.String,
+				Description: "The secret of the user, only visible to the user themselves.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					current, err := s.authenticate(p.Context)
+					if err != nil || current.ID != p.Source.(*services.SignUpResponseDTO).ID {
+						return nil, nil
+					}
+
+					return current.Secret, nil
+				},
+			},
+			"subscriptions": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(subscription)),
+				Description: "The subscriptions of the user, oldest first. Only visible to the user themselves.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := s.self(p)
+					if err != nil {
+						return nil, err
+					}
+
+					return requestFrom(p.Context).subscriptions.Load(p.Context, id), nil
+				},
+			},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Type:        user,
+				Description: "The authenticated user.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					current, err := s.authenticate(p.Context)
+					if err != nil {
+						return nil, err
+					}
+
+					return requestFrom(p.Context).users.Load(p.Context, current.ID), nil
+				},
+			},
+			"validPairs": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+				Description: "The pairs that can be subscribed to.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if _, err := s.authenticate(p.Context); err != nil {
+						return nil, err
+					}
+
+					return s.users.GetAllValidPairs(p.Context)
+				},
+			},
+			"latestRate": &graphql.Field{
+				Type:        rate,
+				Description: "The current rate of a pair, null if the pair provider does not offer it.",
+				Args: graphql.FieldConfigArgument{
+					"pair": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if _, err := s.authenticate(p.Context); err != nil {
+						return nil, err
+					}
+
+					return requestFrom(p.Context).latestRates.Load(p.Context, p.Args["pair"].(string)), nil
+				},
+			},
+			"rateHistory": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(rate)),
+				Description: "The most recently observed rates of a pair, newest first.",
+				Args: graphql.FieldConfigArgument{
+					"pair":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"limit": limitArg,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if _, err := s.authenticate(p.Context); err != nil {
+						return nil, err
+					}
+
+					key := historyKey{pair: p.Args["pair"].(string), limit: p.Args["limit"].(int)}
+
+					return requestFrom(p.Context).rateHistory.Load(p.Context, key), nil
+				},
+			},
+		},
+	})
+
+	loginPayload := graphql.NewObject(graphql.ObjectConfig{
+		Name: "LoginPayload",
+		Fields: graphql.Fields{
+			"token": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	credentials := graphql.FieldConfigArgument{
+		"email":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"password": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	}
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"signUp": &graphql.Field{
+				Type:        user,
+				Description: "Create an account.",
+				Args:        credentials,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return s.users.SignUp(p.Context, &services.SignUpRequestDTO{
+						Email:    p.Args["email"].(string),
+						Password: p.Args["password"].(string),
+					})
+				},
+			},
+			"login": &graphql.Field{
+				Type:        loginPayload,
+				Description: "Exchange credentials for an access token.",
+				Args:        credentials,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return s.users.Login(p.Context, &services.LoginRequestDTO{
+						Email:    p.Args["email"].(string),
+						Password: p.Args["password"].(string),
+					})
+				},
+			},
+			"updateCredentials": &graphql.Field{
+				Type:        user,
+				Description: "Change the email and/or password of the authenticated user.",
+				Args: graphql.FieldConfigArgument{
+					"id":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"email":    &graphql.ArgumentConfig{Type: graphql.String},
+					"password": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := strconv.ParseInt(p.Args["id"].(string), 10, 64)
+					if err != nil {
+						return nil, services.ErrInvalidUserID
+					}
+
+					email, _ := p.Args["email"].(string)
+					password, _ := p.Args["password"].(string)
+
+					return s.users.Update(p.Context, &services.UpdateCredentialsRequestDTO{
+						Email:    email,
+						Password: password,
+						Token:    requestFrom(p.Context).token,
+						ID:       id,
+					})
+				},
+			},
+			"subscribe": &graphql.Field{
+				Type:        subscription,
+				Description: "Subscribe the authenticated user to a pair.",
+				Args: graphql.FieldConfigArgument{
+					"pair": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					current, err := s.authenticate(p.Context)
+					if err != nil {
+						return nil, err
+					}
+
+					pair := p.Args["pair"].(string)
+
+					if err = s.users.CreateSubscription(p.Context, current.ID, pair); err != nil {
+						return nil, err
+					}
+
+					subscriptions, err := s.users.Subscriptions(p.Context, []int64{current.ID})
+					if err != nil {
+						return nil, err
+					}
+
+					for _, sub := range subscriptions[current.ID] {
+						if sub.Pair == pair {
+							return sub, nil
+						}
+					}
+
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	return graphql.SchemaConfig{Query: query, Mutation: mutation}
+}
+
+// self returns the id of the user the field is resolved on, if it is the authenticated user.
+func (s *Schema) self(p graphql.ResolveParams) (int64, error) {
+	current, err := s.authenticate(p.Context)
+	if err != nil {
+		return 0, err
+	}
+
+	id := p.Source.(*services.SignUpResponseDTO).ID
+	if current.ID != id {
+		return 0, services.ErrAccessDenied
+	}
+
+	return id, nil
+}
@@ -0,0 +1,13 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+func TestRequestRules(t *testing.T) {
+	if err := services.CheckRules(&Request{}); err != nil {
+		t.Errorf("CheckRules(Request) error = %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/maknahar/alpha-flow/internal/logging"
+)
+
+// Statuses of a check and of the readiness of the service.
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusDegraded     = "degraded"
+	StatusShuttingDown = "shutting_down"
+)
+
+// CheckFunc reports whether a dependency is usable. It must give up once ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	timeout  time.Duration
+	critical bool
+	run      CheckFunc
+}
+
+// Checker tells whether the service is ready to take traffic by checking its dependencies.
+type Checker struct {
+	mu           sync.RWMutex
+	checks       []check
+	shuttingDown int32
+}
+
+func New() *Checker {
+	return &Checker{}
+}
+
+// Add registers a check named name, cancelled after timeout. The service is not ready while a critical check fails,
+// a failing non-critical check only degrades it.
+func (c *Checker) Add(name string, timeout time.Duration, critical bool, run CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, check{name: name, timeout: timeout, critical: critical, run: run})
+}
+
+// Shutdown reports the service as not ready from now on, so that it is taken out of rotation before it stops
+// accepting connections.
+func (c *Checker) Shutdown() {
+	atomic.StoreInt32(&c.shuttingDown, 1)
+}
+
+// ShuttingDown tells whether Shutdown has been called.
+func (c *Checker) ShuttingDown() bool {
+	return atomic.LoadInt32(&c.shuttingDown) == 1
+}
+
+// Report is the outcome of the checks.
+type Report struct {
+	// Status is ok, degraded, failing or shutting_down.
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Ready tells whether the service can take traffic, which it does while degraded.
+func (r *Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+// Result is the outcome of a single check. The reason of a failure is logged rather than reported, as the report is
+// public.
+type Result struct {
+	// Status is ok or failing.
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Duration string `json:"duration"`
+}
+
+// Check runs every check concurrently, each with its own timeout. No check is run once the service is shutting down.
+func (c *Checker) Check(ctx context.Context) *Report {
+	if c.ShuttingDown() {
+		return &Report{Status: StatusShuttingDown}
+	}
+
+	c.mu.RLock()
+	checks := c.checks
+	c.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+
+	for i := range checks {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			results[i] = checks[i].do(ctx)
+		}(i)
+	}
+
+	wg.Wait()
+
+	report := &Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+
+	for i, ch := range checks {
+		report.Checks[ch.name] = results[i]
+
+		if results[i].Status == StatusOK {
+			continue
+		}
+
+		if ch.critical {
+			report.Status = StatusFailing
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report
+}
+
+func (ch check) do(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, ch.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ch.run(ctx)
+
+	result := Result{Status: StatusOK, Critical: ch.critical, Duration: time.Since(start).String()}
+
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		result.Status = StatusFailing
+
+		logging.FromContext(ctx).WithError(err).WithField("check", ch.name).WithField("critical", ch.critical).
+			Warn("Health check failing")
+	}
+
+	return result
+}
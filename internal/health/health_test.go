@@ -0,0 +1,112 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ok(context.Context) error { return nil }
+
+func failing(context.Context) error { return errors.New("dial tcp 10.0.0.5:5432: connection refused") }
+
+// hanging ignores its timeout until ctx is done and then reports success, which still fails the check.
+func hanging(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+type checkConfig struct {
+	name     string
+	critical bool
+	run      CheckFunc
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     []checkConfig
+		wantStatus string
+		wantChecks map[string]string
+	}{
+		{name: "no checks", wantStatus: StatusOK, wantChecks: map[string]string{}},
+		{
+			name:       "ok",
+			checks:     []checkConfig{{"database", true, ok}, {"pair_provider", false, ok}},
+			wantStatus: StatusOK,
+			wantChecks: map[string]string{"database": StatusOK, "pair_provider": StatusOK},
+		},
+		{
+			name:       "non critical failing",
+			checks:     []checkConfig{{"database", true, ok}, {"pair_provider", false, failing}},
+			wantStatus: StatusDegraded,
+			wantChecks: map[string]string{"database": StatusOK, "pair_provider": StatusFailing},
+		},
+		{
+			name:       "critical failing",
+			checks:     []checkConfig{{"database", true, failing}, {"pair_provider", false, failing}},
+			wantStatus: StatusFailing,
+			wantChecks: map[string]string{"database": StatusFailing, "pair_provider": StatusFailing},
+		},
+		{
+			name:       "timeout",
+			checks:     []checkConfig{{"database", true, hanging}},
+			wantStatus: StatusFailing,
+			wantChecks: map[string]string{"database": StatusFailing},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			for _, ch := range tt.checks {
+				c.Add(ch.name, 10*time.Millisecond, ch.critical, ch.run)
+			}
+
+			report := c.Check(context.Background())
+			if report.Status != tt.wantStatus {
+				t.Errorf("Check() status = %s; want %s", report.Status, tt.wantStatus)
+			}
+
+			got := make(map[string]string, len(report.Checks))
+			for name, result := range report.Checks {
+				got[name] = result.Status
+			}
+
+			for name, want := range tt.wantChecks {
+				if got[name] != want {
+					t.Errorf("Check() %s = %s; want %s", name, got[name], want)
+				}
+			}
+
+			// The report is public, so the reason of a failure stays out of it.
+			body, err := json.Marshal(report)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			if strings.Contains(string(body), "10.0.0.5") {
+				t.Errorf("Check() report = %s; want no error details", body)
+			}
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	c := New()
+	c.Add("database", time.Second, true, func(context.Context) error {
+		t.Error("check run while shutting down")
+		return nil
+	})
+
+	c.Shutdown()
+
+	if report := c.Check(context.Background()); report.Status != StatusShuttingDown || report.Ready() {
+		t.Errorf("Check() = %+v; want not ready and shutting down", report)
+	}
+}
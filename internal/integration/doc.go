@@ -0,0 +1,4 @@
+// Package integration tests the HTTP API end to end: the router of routes.Get served by httptest, on a Postgres
+// server started for the tests with the migrations applied and a fake pair provider. Without a Postgres server to run
+// on the tests are skipped, see startPostgres.
+package integration
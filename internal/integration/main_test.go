@@ -0,0 +1,268 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/health"
+	"github.com/maknahar/alpha-flow/internal/routes"
+)
+
+// The state shared by the tests, set up once by TestMain.
+//
+//nolint:gochecknoglobals
+var (
+	// api serves the router of routes.Get.
+	api *httptest.Server
+
+	// provider is the fake pair provider of api.
+	provider *fakePairProvider
+
+	// skipped tells why the tests are skipped, empty if they are run.
+	skipped string
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	flag.Parse()
+
+	if testing.Short() {
+		skipped = "integration tests skipped in short mode"
+		return m.Run()
+	}
+
+	ctx := context.Background()
+
+	// In CI or with INTEGRATION=1, a missing Postgres fails the tests rather than skipping them unnoticed.
+	server, err := startPostgres(ctx)
+	if errors.Is(err, errNoPostgres) && !postgresRequired() {
+		skipped = err.Error()
+		return m.Run()
+	}
+
+	if err != nil {
+		logrus.WithError(err).Error("Unable to start Postgres")
+		return 1
+	}
+
+	defer server.stop()
+
+	provider = newFakePairProvider()
+	defer provider.Close()
+
+	settings, _, err := configs.Load([]string{
+		"--db-host", server.host,
+		"--db-port", strconv.Itoa(server.port),
+		"--db-user", server.user,
+		"--db-pass", server.password,
+		"--db-name", server.name,
+		"--storage-backend", configs.StorageBackendPostgres,
+		"--pair-provider-url", provider.URL,
+		"--log-level", "Error",
+		// The cheapest hash keeps the tests fast.
+		"--password-hash-algorithm", "bcrypt",
+		"--bcrypt-cost", "4",
+	})
+	if err != nil {
+		logrus.WithError(err).Error("Invalid settings")
+		return 1
+	}
+
+	conf, err := configs.Configure(ctx, settings)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to configure the service")
+		return 1
+	}
+
+	if err = conf.Database.Migrate(conf.DSN, ""); err != nil {
+		logrus.WithError(err).Error("Unable to migrate the database")
+		return 1
+	}
+
+	api = httptest.NewServer(routes.Get(conf, health.New()))
+	defer api.Close()
+
+	return m.Run()
+}
+
+// setup skips t unless the API is served.
+func setup(t *testing.T) {
+	t.Helper()
+
+	if skipped != "" {
+		t.Skip(skipped)
+	}
+}
+
+// fakePairProvider serves the valid pairs and their rates, or fails every request while down.
+type fakePairProvider struct {
+	*httptest.Server
+
+	down int32
+}
+
+// fakeRates are the pairs offered by fakePairProvider, with their rates.
+//
+//nolint:gochecknoglobals
+var fakeRates = map[string]float64{"btc_eth": 31.5, "eth_ltc": 4.25}
+
+func newFakePairProvider() *fakePairProvider {
+	p := &fakePairProvider{}
+
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&p.down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var body interface{}
+
+		switch r.URL.Path {
+		case "/validpairs":
+			var pairs []string
+			for pair := range fakeRates {
+				pairs = append(pairs, pair)
+			}
+
+			body = pairs
+		case "/marketinfo":
+			var markets []map[string]interface{}
+			for pair, rate := range fakeRates {
+				markets = append(markets, map[string]interface{}{"pair": pair, "rate": rate})
+			}
+
+			body = markets
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+
+	return p
+}
+
+// setDown makes the provider fail every request until called again with false.
+func (p *fakePairProvider) setDown(down bool) {
+	value := int32(0)
+	if down {
+		value = 1
+	}
+
+	atomic.StoreInt32(&p.down, value)
+}
+
+// response is a response of the API with its JSON body decoded.
+type response struct {
+	status int
+	header http.Header
+	body   map[string]interface{}
+	list   []interface{}
+}
+
+// code returns the code of the problem of the response, empty if it succeeded.
+func (r response) code() string {
+	code, _ := r.body["code"].(string)
+	return code
+}
+
+// str returns the string field key of the body, empty if missing.
+func (r response) str(key string) string {
+	s, _ := r.body[key].(string)
+	return s
+}
+
+// call sends a request to the API with the JSON body, if any, authenticated by token, if any.
+func call(t *testing.T, method, path, token, body string) response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, api.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest(%s %s) error = %v", method, path, err)
+	}
+
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := api.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", method, path, err)
+	}
+
+	defer res.Body.Close()
+
+	r := response{status: res.StatusCode, header: res.Header}
+
+	var decoded interface{}
+	if err = json.NewDecoder(res.Body).Decode(&decoded); err != nil {
+		return r
+	}
+
+	switch v := decoded.(type) {
+	case map[string]interface{}:
+		r.body = v
+	case []interface{}:
+		r.list = v
+	}
+
+	return r
+}
+
+// credentials returns the JSON body of a sign up or login.
+func credentials(email, password string) string {
+	data, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	return string(data)
+}
+
+//nolint:gochecknoglobals
+var emailCounter int64
+
+// newEmail returns an email no other test signs up with.
+func newEmail() string {
+	return fmt.Sprintf("%d-%d@example.com", time.Now().UnixNano(), atomic.AddInt64(&emailCounter, 1))
+}
+
+// signUp signs up a new user and logs them in, returning their id, email and token.
+func signUp(t *testing.T, password string) (id int64, email, token string) {
+	t.Helper()
+
+	email = newEmail()
+
+	res := call(t, http.MethodPost, "/v1/signup", "", credentials(email, password))
+	if res.status != http.StatusOK {
+		t.Fatalf("POST /v1/signup = %d %v; want 200", res.status, res.body)
+	}
+
+	id = int64(res.body["id"].(float64))
+
+	res = call(t, http.MethodPost, "/v1/login", "", credentials(email, password))
+	if res.status != http.StatusOK || res.str("token") == "" {
+		t.Fatalf("POST /v1/login = %d %v; want 200 with a token", res.status, res.body)
+	}
+
+	return id, email, res.str("token")
+}
@@ -0,0 +1,279 @@
+package integration
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq" // The Postgres driver.
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// postgresImage is the image of the container started when no local Postgres is installed, the one of
+	// docker-compose.yml.
+	postgresImage = "postgres:11-alpine"
+
+	// postgresStartTimeout is the time a started server is given to accept connections.
+	postgresStartTimeout = 30 * time.Second
+)
+
+// errNoPostgres is returned by startPostgres when no Postgres server can be started.
+var errNoPostgres = errors.New("no Postgres server: set TEST_DATABASE_URL, install postgres or start Docker")
+
+// postgresRequired tells whether the tests have to fail rather than be skipped without Postgres, as they are in CI or
+// INTEGRATION=1 is set.
+func postgresRequired() bool {
+	ci, _ := strconv.ParseBool(os.Getenv("CI"))
+	return ci || os.Getenv("INTEGRATION") == "1"
+}
+
+// postgresServer is a Postgres server the tests run on.
+type postgresServer struct {
+	host     string
+	port     int
+	user     string
+	password string
+	name     string
+
+	stop func()
+}
+
+// startPostgres returns, in order of preference, a throwaway database of the server of TEST_DATABASE_URL, a server run
+// from the local postgres binaries in a temporary directory or a Docker container of postgresImage. All are deleted by
+// stop.
+func startPostgres(ctx context.Context) (*postgresServer, error) {
+	if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+		return throwawayPostgres(ctx, dsn)
+	}
+
+	server, err := startLocalPostgres(ctx)
+	if !errors.Is(err, errNoPostgres) {
+		return server, err
+	}
+
+	return startPostgresContainer(ctx)
+}
+
+// throwawayPostgres creates a database of a random name on the server of the postgres:// URL dsn, dropped by stop, so
+// that the database dsn names is never written to.
+func throwawayPostgres(ctx context.Context, dsn string) (*postgresServer, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%w; invalid TEST_DATABASE_URL", err)
+	}
+
+	server := &postgresServer{host: u.Hostname(), port: 5432, user: u.User.Username()}
+	server.password, _ = u.User.Password()
+
+	if p := u.Port(); p != "" {
+		if server.port, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("%w; invalid port in TEST_DATABASE_URL", err)
+		}
+	}
+
+	if server.name, err = throwawayName(); err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%w; invalid TEST_DATABASE_URL", err)
+	}
+
+	if _, err = conn.ExecContext(ctx, "CREATE DATABASE "+server.name); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%w; unable to create a database on the server of TEST_DATABASE_URL", err)
+	}
+
+	server.stop = func() {
+		defer conn.Close()
+
+		// A database cannot be dropped while connected to, by the pools of the service left open.
+		_, err := conn.Exec("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1",
+			server.name)
+		if err == nil {
+			_, err = conn.Exec("DROP DATABASE " + server.name)
+		}
+
+		if err != nil {
+			logrus.WithError(err).WithField("database", server.name).Warn("Unable to drop the test database")
+		}
+	}
+
+	return server, nil
+}
+
+// throwawayName returns a random name of a database created for the tests.
+func throwawayName() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return "alpha_flow_test_" + hex.EncodeToString(b), nil
+}
+
+// startLocalPostgres initialises a cluster in a temporary directory and runs it, listening on a socket of that
+// directory only so that it never conflicts with another server.
+func startLocalPostgres(ctx context.Context) (*postgresServer, error) {
+	initdb, postgres := lookPostgres()
+	if initdb == "" || postgres == "" || os.Geteuid() == 0 {
+		// Postgres refuses to run as root.
+		return nil, errNoPostgres
+	}
+
+	dir, err := ioutil.TempDir("", "alpha-flow-postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	data := filepath.Join(dir, "data")
+
+	out, err := exec.CommandContext(ctx, initdb, "--pgdata", data, "--username", "postgres", "--auth", "trust",
+		"--no-sync").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("%w; initdb: %s", err, out)
+	}
+
+	cmd := exec.Command(postgres, "-D", data, "-k", dir, "-c", "listen_addresses=", "-F")
+	if err = cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	server := &postgresServer{host: dir, port: 5432, user: "postgres", name: "postgres", stop: func() {
+		// SIGINT is the fast shutdown of Postgres.
+		_ = cmd.Process.Signal(os.Interrupt)
+		_ = cmd.Wait()
+		os.RemoveAll(dir)
+	}}
+
+	if err = server.wait(ctx); err != nil {
+		server.stop()
+		return nil, err
+	}
+
+	return server, nil
+}
+
+// lookPostgres returns the paths of the initdb and postgres binaries, from PATH or the usual install directories,
+// empty if not found.
+func lookPostgres() (initdb, postgres string) {
+	if path, err := exec.LookPath("initdb"); err == nil {
+		initdb = path
+	} else {
+		dirs, _ := filepath.Glob("/usr/lib/postgresql/*/bin/initdb")
+		more, _ := filepath.Glob("/usr/local/opt/postgresql*/bin/initdb")
+
+		if dirs = append(dirs, more...); len(dirs) > 0 {
+			initdb = dirs[len(dirs)-1]
+		}
+	}
+
+	if initdb == "" {
+		return "", ""
+	}
+
+	postgres = filepath.Join(filepath.Dir(initdb), "postgres")
+	if _, err := os.Stat(postgres); err != nil {
+		return initdb, ""
+	}
+
+	return initdb, postgres
+}
+
+// startPostgresContainer runs a container of postgresImage, publishing its port on a free port of the loopback
+// interface.
+func startPostgresContainer(ctx context.Context) (*postgresServer, error) {
+	docker, err := exec.LookPath("docker")
+	if err != nil || exec.CommandContext(ctx, docker, "info").Run() != nil {
+		return nil, errNoPostgres
+	}
+
+	const password = "postgres"
+
+	out, err := exec.CommandContext(ctx, docker, "run", "--detach", "--rm", "--env", "POSTGRES_PASSWORD="+password,
+		"--publish", "127.0.0.1::5432", postgresImage).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w; unable to start a container of %s", err, postgresImage)
+	}
+
+	id := strings.TrimSpace(string(out))
+	stop := func() { _ = exec.Command(docker, "rm", "--force", id).Run() }
+
+	out, err = exec.CommandContext(ctx, docker, "port", id, "5432/tcp").Output()
+	if err != nil {
+		stop()
+		return nil, fmt.Errorf("%w; unable to find the port of the Postgres container", err)
+	}
+
+	// Every published address is listed on its own line.
+	host, port, err := net.SplitHostPort(strings.Fields(string(out))[0])
+	if err != nil {
+		stop()
+		return nil, err
+	}
+
+	server := &postgresServer{host: host, user: "postgres", password: password, name: "postgres", stop: stop}
+
+	if server.port, err = strconv.Atoi(port); err != nil {
+		stop()
+		return nil, err
+	}
+
+	if err = server.wait(ctx); err != nil {
+		stop()
+		return nil, err
+	}
+
+	return server, nil
+}
+
+// dsn returns the connection string of the server.
+func (s *postgresServer) dsn() string {
+	dsn := fmt.Sprintf("host=%s port=%d user=%s dbname=%s sslmode=disable", s.host, s.port, s.user, s.name)
+	if s.password != "" {
+		dsn += " password=" + s.password
+	}
+
+	return dsn
+}
+
+// wait waits for the server to accept connections, at most postgresStartTimeout.
+func (s *postgresServer) wait(ctx context.Context) error {
+	conn, err := sql.Open("postgres", s.dsn())
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, postgresStartTimeout)
+	defer cancel()
+
+	for {
+		if err = conn.PingContext(ctx); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w; Postgres did not start", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	password     = "open1234"
+	weakPassword = "open123"
+	badEmail     = "x@x"
+)
+
+// TestUserJourney runs the scenarios of test-suite.sh in the same order, each depending on the previous ones.
+func TestUserJourney(t *testing.T) {
+	setup(t)
+
+	email := newEmail()
+	upperCasedEmail := strings.Replace(email, "example.com", "EXAMPLE.com", 1)
+
+	// POST /signup
+	res := call(t, http.MethodPost, "/v1/signup", "", credentials(email, password))
+
+	id, ok := res.body["id"].(float64)
+	if res.status != http.StatusOK || !ok || id <= 0 {
+		t.Fatalf("sign up = %d %v; want a new user id as a number", res.status, res.body)
+	}
+
+	userID := int64(id)
+
+	res = call(t, http.MethodPost, "/v1/signup", "", credentials(newEmail(), weakPassword))
+	if res.status != http.StatusUnprocessableEntity || res.code() != "invalid_password" {
+		t.Errorf("sign up with a weak password = %d %q; want 422 invalid_password", res.status, res.code())
+	}
+
+	res = call(t, http.MethodPost, "/v1/signup", "", credentials(badEmail, password))
+	if res.status != http.StatusUnprocessableEntity || res.code() != "invalid_email" {
+		t.Errorf("sign up with an invalid email = %d %q; want 422 invalid_email", res.status, res.code())
+	}
+
+	res = call(t, http.MethodPost, "/v1/signup", "", credentials(email, password))
+	if res.status != http.StatusConflict || res.code() != "account_exists" {
+		t.Errorf("sign up of a signed up user = %d %q; want 409 account_exists", res.status, res.code())
+	}
+
+	// POST /login
+	res = call(t, http.MethodPost, "/v1/login", "", credentials(email, password))
+	if res.status != http.StatusOK || res.str("token") == "" {
+		t.Errorf("login = %d %v; want a token", res.status, res.body)
+	}
+
+	res = call(t, http.MethodPost, "/v1/login", "", credentials("wrong"+email, password))
+	if res.status != http.StatusUnauthorized || res.str("token") != "" {
+		t.Errorf("login with invalid credentials = %d %v; want 401 without a token", res.status, res.body)
+	}
+
+	res = call(t, http.MethodPost, "/v1/login", "", credentials(upperCasedEmail, password))
+	if res.status != http.StatusOK || res.str("token") == "" {
+		t.Fatalf("login with the upper cased email = %d %v; want a token", res.status, res.body)
+	}
+
+	token := res.str("token")
+
+	// GET /secret
+	res = call(t, http.MethodGet, "/v1/secret", token, "")
+	if res.status != http.StatusOK || !strings.HasPrefix(res.str("secret"), "All your base are") {
+		t.Errorf("secret = %d %v; want the secret string", res.status, res.body)
+	}
+
+	if got, _ := res.body["user_id"].(float64); int64(got) != userID {
+		t.Errorf("secret user_id = %v; want the token owner %d", res.body["user_id"], userID)
+	}
+
+	// PATCH /users/{id}
+	path := "/v1/users/" + strconv.FormatInt(userID, 10)
+
+	res = call(t, http.MethodPatch, path, token, `{"email": "updated-`+email+`"}`)
+	if res.status != http.StatusOK || res.str("email") != "updated-"+email {
+		t.Errorf("update of the email = %d %v; want the updated email", res.status, res.body)
+	}
+
+	if !strings.HasPrefix(res.str("updated_at"), "20") {
+		t.Errorf("update of the email updated_at = %q; want a valid date", res.str("updated_at"))
+	}
+
+	res = call(t, http.MethodPatch, path, token, `{"email": "`+badEmail+`"}`)
+	if res.status != http.StatusUnprocessableEntity || res.code() != "invalid_email" {
+		t.Errorf("update with an invalid email = %d %q; want 422 invalid_email", res.status, res.code())
+	}
+
+	res = call(t, http.MethodPatch, path, token, `{"password": "1234open"}`)
+	if res.status != http.StatusOK || !strings.HasPrefix(res.str("updated_at"), "20") {
+		t.Errorf("update of the password = %d %v; want a valid date in updated_at", res.status, res.body)
+	}
+
+	if _, ok := res.body["password"]; ok {
+		t.Error("update of the password returned the password")
+	}
+
+	res = call(t, http.MethodPatch, path, token, `{"password": "`+weakPassword+`"}`)
+	if res.status != http.StatusUnprocessableEntity || res.code() != "invalid_password" {
+		t.Errorf("update with an invalid password = %d %q; want 422 invalid_password", res.status, res.code())
+	}
+
+	res = call(t, http.MethodGet, "/v1/secret", "xxx", "")
+	if res.status != http.StatusForbidden || res.code() != "invalid_token" {
+		t.Errorf("secret with an invalid token = %d %q; want 403 invalid_token", res.status, res.code())
+	}
+
+	// The changed credentials replace the old ones.
+	res = call(t, http.MethodPost, "/v1/login", "", credentials("updated-"+email, "1234open"))
+	if res.status != http.StatusOK || res.str("token") == "" {
+		t.Errorf("login with the updated credentials = %d %v; want a token", res.status, res.body)
+	}
+
+	for _, old := range []string{credentials(email, "1234open"), credentials("updated-"+email, password)} {
+		if res = call(t, http.MethodPost, "/v1/login", "", old); res.code() != "invalid_credentials" {
+			t.Errorf("login with %s = %d %q; want invalid_credentials", old, res.status, res.code())
+		}
+	}
+}
+
+func TestUserErrors(t *testing.T) {
+	setup(t)
+
+	id, _, token := signUp(t, password)
+	otherID, _, _ := signUp(t, password)
+
+	path := "/v1/users/" + strconv.FormatInt(id, 10)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"sign up without password", http.MethodPost, "/v1/signup", "", `{"email": "a@example.com"}`,
+			http.StatusUnprocessableEntity, "invalid_password"},
+		{"sign up without credentials", http.MethodPost, "/v1/signup", "", `{}`,
+			http.StatusUnprocessableEntity, "validation_failed"},
+		{"sign up with malformed body", http.MethodPost, "/v1/signup", "", `{"email": `,
+			http.StatusBadRequest, "malformed_request"},
+		{"login with wrong password", http.MethodPost, "/v1/login", "", credentials(newEmail(), "wrong"),
+			http.StatusUnauthorized, "invalid_credentials"},
+		{"login with malformed body", http.MethodPost, "/v1/login", "", `[]`,
+			http.StatusBadRequest, "malformed_request"},
+		{"secret without token", http.MethodGet, "/v1/secret", "", "",
+			http.StatusUnauthorized, "missing_token"},
+		{"update without token", http.MethodPatch, path, "", `{"email": "b@example.com"}`,
+			http.StatusUnauthorized, "missing_token"},
+		{"update with invalid token", http.MethodPatch, path, "xxx", `{"email": "b@example.com"}`,
+			http.StatusForbidden, "invalid_token"},
+		{"update of another user", http.MethodPatch, "/v1/users/" + strconv.FormatInt(otherID, 10), token,
+			`{"email": "b@example.com"}`, http.StatusForbidden, "access_denied"},
+		{"update of invalid user id", http.MethodPatch, "/v1/users/me", token, `{"email": "b@example.com"}`,
+			http.StatusBadRequest, "invalid_user_id"},
+		{"update with malformed body", http.MethodPatch, path, token, `{"email": 1}`,
+			http.StatusBadRequest, "malformed_request"},
+		{"subscription to invalid pair", http.MethodPost, "/v1/subscriptions", token, `{"pair": "xxx_yyy"}`,
+			http.StatusUnprocessableEntity, "invalid_pair"},
+		{"subscription without pair", http.MethodPost, "/v1/subscriptions", token, `{}`,
+			http.StatusUnprocessableEntity, "invalid_pair"},
+		{"subscription without token", http.MethodPost, "/v1/subscriptions", "", `{"pair": "btc_eth"}`,
+			http.StatusUnauthorized, "missing_token"},
+		{"valid pairs with invalid token", http.MethodGet, "/v1/subscriptions/validpairs", "xxx", "",
+			http.StatusForbidden, "invalid_token"},
+		{"audit events of non admin", http.MethodGet, "/v1/admin/audit-events", token, "",
+			http.StatusForbidden, "access_denied"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			res := call(t, tt.method, tt.path, tt.token, tt.body)
+			if res.status != tt.wantStatus || res.code() != tt.wantCode {
+				t.Errorf("%s %s = %d %q; want %d %q", tt.method, tt.path, res.status, res.code(), tt.wantStatus,
+					tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	setup(t)
+
+	_, _, token := signUp(t, password)
+
+	res := call(t, http.MethodGet, "/v1/subscriptions/validpairs", token, "")
+	if res.status != http.StatusOK || len(res.list) != len(fakeRates) {
+		t.Fatalf("valid pairs = %d %v; want the %d pairs of the provider", res.status, res.list, len(fakeRates))
+	}
+
+	res = call(t, http.MethodPost, "/v1/subscriptions", token, `{"pair": "btc_eth"}`)
+	if res.status != http.StatusOK {
+		t.Fatalf("subscription = %d %v; want 200", res.status, res.body)
+	}
+
+	res = graphQL(t, token, `{ me { subscriptions { pair latestRate { rate } } } }`)
+
+	me, _ := res.body["data"].(map[string]interface{})["me"].(map[string]interface{})
+	subscriptions, _ := me["subscriptions"].([]interface{})
+
+	if len(subscriptions) != 1 {
+		t.Fatalf("subscriptions = %v; want the btc_eth subscription", res.body)
+	}
+
+	subscription := subscriptions[0].(map[string]interface{})
+	rate, _ := subscription["latestRate"].(map[string]interface{})
+
+	if subscription["pair"] != "btc_eth" || rate["rate"] != fakeRates["btc_eth"] {
+		t.Errorf("subscription = %v; want btc_eth at %v", subscription, fakeRates["btc_eth"])
+	}
+
+	// The rate fetched for the subscription was recorded.
+	res = graphQL(t, token, `{ rateHistory(pair: "btc_eth") { rate } }`)
+
+	history, _ := res.body["data"].(map[string]interface{})["rateHistory"].([]interface{})
+	if len(history) == 0 {
+		t.Errorf("rate history = %v; want the recorded rate", res.body)
+	}
+}
+
+func TestPairProviderDown(t *testing.T) {
+	setup(t)
+
+	_, _, token := signUp(t, password)
+
+	provider.setDown(true)
+	defer provider.setDown(false)
+
+	res := call(t, http.MethodGet, "/v1/subscriptions/validpairs", token, "")
+	if res.status != http.StatusBadGateway || res.code() != "upstream_unavailable" {
+		t.Errorf("valid pairs = %d %q; want 502 upstream_unavailable", res.status, res.code())
+	}
+
+	res = call(t, http.MethodPost, "/v1/subscriptions", token, `{"pair": "btc_eth"}`)
+	if res.status != http.StatusBadGateway || res.code() != "upstream_unavailable" {
+		t.Errorf("subscription = %d %q; want 502 upstream_unavailable", res.status, res.code())
+	}
+}
+
+func TestLegacyRoutes(t *testing.T) {
+	setup(t)
+
+	res := call(t, http.MethodPost, "/signup", "", credentials(newEmail(), password))
+	if res.status != http.StatusOK || res.header.Get("Deprecation") != "true" {
+		t.Errorf("legacy sign up = %d, Deprecation %q; want 200, true", res.status, res.header.Get("Deprecation"))
+	}
+
+	if link := res.header.Get("Link"); !strings.Contains(link, "</v1/signup>") {
+		t.Errorf("legacy sign up Link = %q; want the /v1/signup successor", link)
+	}
+}
+
+// graphQL sends query to the GraphQL endpoint.
+func graphQL(t *testing.T, token, query string) response {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]string{"query": query})
+
+	res := call(t, http.MethodPost, "/graphql", token, string(body))
+	if res.status != http.StatusOK || res.body["errors"] != nil {
+		t.Fatalf("POST /graphql %s = %d %v; want 200 without errors", query, res.status, res.body)
+	}
+
+	return res
+}
@@ -0,0 +1,156 @@
+package logging
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// discard drops every entry below panic, for the logs left out by a Sampler.
+//
+//nolint:gochecknoglobals
+var discard = &logrus.Logger{
+	Out:       ioutil.Discard,
+	Formatter: new(logrus.JSONFormatter),
+	Hooks:     make(logrus.LevelHooks),
+	Level:     logrus.PanicLevel,
+}
+
+// New returns a logger writing JSON entries at level or above, with secrets redacted.
+func New(level logrus.Level) *logrus.Logger {
+	logger := logrus.New()
+	logger.Level = level
+	logger.SetFormatter(Formatter())
+
+	return logger
+}
+
+// Formatter formats the entries as JSON after redacting the values of sensitive fields, along with the bearer tokens,
+// passwords and credentials of connection strings found in messages, errors and string values.
+func Formatter() logrus.Formatter {
+	return redactor{next: &logrus.JSONFormatter{}}
+}
+
+// scope is the logger of a request along with the fields collected while handling it.
+type scope struct {
+	logger  *logrus.Logger
+	sampler *Sampler
+
+	mu     sync.RWMutex
+	fields logrus.Fields
+}
+
+type scopeKey struct{}
+
+// NewContext returns a copy of ctx carrying logger, sampler and fields, to be logged with by everything handling the
+// request of ctx. Without a logger, the standard logger is used.
+func NewContext(ctx context.Context, logger *logrus.Logger, sampler *Sampler, fields logrus.Fields) context.Context {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+
+	s := &scope{logger: logger, sampler: sampler, fields: make(logrus.Fields, len(fields))}
+
+	for k, v := range fields {
+		s.fields[k] = v
+	}
+
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// AddFields adds fields to the logger of ctx for the rest of the request, e.g. the user id once the request is
+// authenticated. It does nothing if ctx carries no logger.
+func AddFields(ctx context.Context, fields logrus.Fields) {
+	s, ok := ctx.Value(scopeKey{}).(*scope)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range fields {
+		s.fields[k] = v
+	}
+}
+
+// FromContext returns the logger of ctx with the fields of its request and the route pattern it matched, if any.
+// Without a logger in ctx, the standard logger is used.
+func FromContext(ctx context.Context) *logrus.Entry {
+	s, ok := ctx.Value(scopeKey{}).(*scope)
+	if !ok {
+		return logrus.NewEntry(logrus.StandardLogger())
+	}
+
+	s.mu.RLock()
+	entry := s.logger.WithFields(s.fields)
+	s.mu.RUnlock()
+
+	if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+		entry = entry.WithField("route", rctx.RoutePattern())
+	}
+
+	return entry
+}
+
+// Sampled returns the logger of ctx if the sampler of ctx keeps the next log keyed by key, and a logger discarding
+// everything but panics otherwise. Use it for logs written on every request; failures should always be logged.
+func Sampled(ctx context.Context, key string) *logrus.Entry {
+	if s, ok := ctx.Value(scopeKey{}).(*scope); ok && !s.sampler.Allow(key) {
+		return logrus.NewEntry(discard)
+	}
+
+	return FromContext(ctx)
+}
+
+// Middleware puts logger in the context of every request, with the request id set by middleware.RequestID, the
+// method and the path, and writes an access log once the request is handled. The access logs of requests not
+// failing with a 5xx are sampled by route. Without a logger, the standard logger is used.
+func Middleware(logger *logrus.Logger, sampler *Sampler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			ctx := NewContext(r.Context(), logger, sampler, logrus.Fields{
+				"request_id": middleware.GetReqID(r.Context()),
+				"method":     r.Method,
+				"path":       r.URL.Path,
+			})
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			entry := FromContext(ctx)
+			if status < http.StatusInternalServerError {
+				entry = Sampled(ctx, "http "+r.Method+" "+routePattern(ctx))
+			}
+
+			entry.WithFields(logrus.Fields{
+				"status":      status,
+				"bytes":       ww.BytesWritten(),
+				"duration":    time.Since(start).String(),
+				"remote_addr": r.RemoteAddr,
+			}).Info("Handled request")
+		})
+	}
+}
+
+func routePattern(ctx context.Context) string {
+	if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+
+	return "unmatched"
+}
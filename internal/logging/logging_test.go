@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestLogger returns a logger at debug level writing to the returned buffer.
+func newTestLogger() (*logrus.Logger, *bytes.Buffer) {
+	var out bytes.Buffer
+
+	logger := New(logrus.DebugLevel)
+	logger.Out = &out
+
+	return logger, &out
+}
+
+// entries decodes the JSON entries of out.
+func entries(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var decoded []map[string]interface{}
+
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("entry %q is not JSON: %v", line, err)
+		}
+
+		decoded = append(decoded, entry)
+	}
+
+	return decoded
+}
+
+func TestContextFields(t *testing.T) {
+	logger, out := newTestLogger()
+
+	fields := logrus.Fields{"request_id": "abc"}
+	ctx := NewContext(context.Background(), logger, nil, fields)
+
+	// The fields given are copied.
+	fields["request_id"] = "changed"
+
+	AddFields(ctx, logrus.Fields{"user_id": 1})
+	FromContext(ctx).Info("first")
+
+	AddFields(ctx, logrus.Fields{"user_id": 2, "password": "s3cret"})
+	FromContext(ctx).Info("second")
+
+	got := entries(t, out)
+	if len(got) != 2 {
+		t.Fatalf("logged %d entries; want 2", len(got))
+	}
+
+	if got[0]["request_id"] != "abc" || got[0]["user_id"] != float64(1) {
+		t.Errorf("first entry = %v; want request_id abc and user_id 1", got[0])
+	}
+
+	if got[1]["user_id"] != float64(2) || got[1]["password"] != Redacted {
+		t.Errorf("second entry = %v; want user_id 2 and the password redacted", got[1])
+	}
+}
+
+func TestContextWithoutLogger(t *testing.T) {
+	// Without a logger, the fields are dropped and the standard logger is used.
+	AddFields(context.Background(), logrus.Fields{"user_id": 1})
+
+	if entry := FromContext(context.Background()); entry.Logger != logrus.StandardLogger() || len(entry.Data) != 0 {
+		t.Errorf("FromContext() = %+v; want the standard logger without fields", entry)
+	}
+}
+
+func TestSampled(t *testing.T) {
+	logger, out := newTestLogger()
+	ctx := NewContext(context.Background(), logger, NewSampler(1, 0), nil)
+
+	Sampled(ctx, "key").Info("kept")
+	Sampled(ctx, "key").Info("dropped")
+	FromContext(ctx).Info("failure")
+
+	got := entries(t, out)
+	if len(got) != 2 || got[0]["msg"] != "kept" || got[1]["msg"] != "failure" {
+		t.Errorf("logged %v; want the first sampled entry and the unsampled one", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	logger, out := newTestLogger()
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID, Middleware(logger, nil))
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		AddFields(r.Context(), logrus.Fields{"user_id": 1})
+		FromContext(r.Context()).Info("Handling")
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1?token=abc", nil))
+
+	got := entries(t, out)
+	if len(got) != 2 {
+		t.Fatalf("logged %d entries; want 2", len(got))
+	}
+
+	// The fields of the request are on every entry, those added while handling it included.
+	for _, entry := range got {
+		if entry["request_id"] == nil || entry["request_id"] == "" || entry["method"] != http.MethodGet ||
+			entry["path"] != "/users/1" || entry["route"] != "/users/{id}" || entry["user_id"] != float64(1) {
+			t.Errorf("entry = %v; want the fields of the request", entry)
+		}
+	}
+
+	if access := got[1]; access["msg"] != "Handled request" || access["status"] != float64(http.StatusTeapot) {
+		t.Errorf("access log = %v; want the status of the request", access)
+	}
+}
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redacted replaces every secret written to the logs.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are the parts of a field name whose value is always redacted.
+//
+//nolint:gochecknoglobals
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "authorization", "cookie", "dsn"}
+
+// sensitivePatterns find secrets embedded in messages, errors and string values, such as the password of a
+// connection string or the bearer token of a header.
+//
+//nolint:gochecknoglobals
+var sensitivePatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{re: regexp.MustCompile(`(?i)\b(bearer|basic)\s+[^\s"',]+`), repl: "$1 " + Redacted},
+	// The value may be quoted, as in the connection strings of Postgres or in JSON.
+	{
+		re: regexp.MustCompile(`(?i)\b(password|passwd|pass|secret|token|access_token)("?\s*[=:]\s*)` +
+			`("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|[^\s"'&,;]+)`),
+		repl: "$1$2" + Redacted,
+	},
+	{re: regexp.MustCompile(`(?i)(://[^:/@\s]+):[^@/\s]+@`), repl: "$1:" + Redacted + "@"},
+}
+
+// redactor redacts the entries before handing them to the next formatter. The data of the entry is copied, as it is
+// shared with the logger the entry was derived from.
+type redactor struct {
+	next logrus.Formatter
+}
+
+func (f redactor) Format(e *logrus.Entry) ([]byte, error) {
+	redacted := *e
+	redacted.Message = redact(e.Message)
+	redacted.Data = make(logrus.Fields, len(e.Data))
+
+	for k, v := range e.Data {
+		redacted.Data[k] = redactField(k, v)
+	}
+
+	return f.next.Format(&redacted)
+}
+
+func redactField(key string, value interface{}) interface{} {
+	lower := strings.ToLower(key)
+
+	for _, s := range sensitiveKeys {
+		if strings.Contains(lower, s) {
+			return Redacted
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return redact(v)
+	case error:
+		return redact(v.Error())
+	default:
+		return value
+	}
+}
+
+func redact(s string) string {
+	for _, p := range sensitivePatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+
+	return s
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "bearer token", in: "Authorization: Bearer abc.def-ghi", want: "Authorization: Bearer [REDACTED]"},
+		{name: "basic credentials", in: `header "basic dXNlcjpwYXNz"`, want: `header "basic [REDACTED]"`},
+		{name: "password", in: "host=db password=s3cret sslmode=disable",
+			want: "host=db password=[REDACTED] sslmode=disable"},
+		{name: "quoted password", in: `host=db password='s3\'cret' user='alice'`,
+			want: "host=db password=[REDACTED] user='alice'"},
+		{name: "json secret", in: `{"secret": "a\"bc", "id": 1}`, want: `{"secret": [REDACTED], "id": 1}`},
+		{name: "colon separated", in: "token: abc123, user: alice", want: "token: [REDACTED], user: alice"},
+		{name: "query parameters", in: "/reset?access_token=abc&pass=def&page=2",
+			want: "/reset?access_token=[REDACTED]&pass=[REDACTED]&page=2"},
+		{name: "upper case", in: "PASSWORD=s3cret", want: "PASSWORD=[REDACTED]"},
+		{name: "dsn credentials", in: "dial postgres://alice:s3cret@db:5432/app failed",
+			want: "dial postgres://alice:[REDACTED]@db:5432/app failed"},
+		{name: "url without credentials", in: "GET https://example.com:8080/pairs",
+			want: "GET https://example.com:8080/pairs"},
+		{name: "nothing sensitive", in: "user 1 logged in", want: "user 1 logged in"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redact(tt.in); got != tt.want {
+				t.Errorf("redact(%q) = %q; want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactField(t *testing.T) {
+	tests := []struct {
+		key   string
+		value interface{}
+		want  interface{}
+	}{
+		{key: "password", value: "s3cret", want: Redacted},
+		{key: "new_password_hash", value: "$argon2id$", want: Redacted},
+		{key: "Authorization", value: "Bearer abc", want: Redacted},
+		{key: "client_secret", value: 42, want: Redacted},
+		{key: "access_token", value: "abc", want: Redacted},
+		{key: "Set-Cookie", value: "session=abc", want: Redacted},
+		{key: "database_dsn", value: "postgres://db/app", want: Redacted},
+		{key: "error", value: errors.New("dial postgres://alice:s3cret@db/app"),
+			want: "dial postgres://alice:[REDACTED]@db/app"},
+		{key: "header", value: "Bearer abc", want: "Bearer [REDACTED]"},
+		{key: "user_id", value: 42, want: 42},
+		{key: "email", value: "alice@example.com", want: "alice@example.com"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.key, func(t *testing.T) {
+			if got := redactField(tt.key, tt.value); got != tt.want {
+				t.Errorf("redactField(%q, %v) = %v; want %v", tt.key, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{"password": "s3cret", "user_id": 1})
+	entry.Message = "connecting with password=s3cret"
+
+	out, err := Formatter().Format(entry)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	var got map[string]interface{}
+	if err = json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("Format() = %s, not JSON: %v", out, err)
+	}
+
+	if strings.Contains(string(out), "s3cret") || got["password"] != Redacted || got["user_id"] != float64(1) ||
+		got["msg"] != "connecting with password="+Redacted {
+		t.Errorf("Format() = %s; want the password redacted from the message and fields", out)
+	}
+
+	// The entry is shared with the logger it was derived from, so it is left as is.
+	if entry.Data["password"] != "s3cret" || entry.Message != "connecting with password=s3cret" {
+		t.Errorf("Format() changed the entry to %+v, %q", entry.Data, entry.Message)
+	}
+}
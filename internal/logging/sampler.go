@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler thins out high-volume logs. Of the logs sharing a key within a second, the first Initial are kept and then
+// one in every Thereafter.
+type Sampler struct {
+	Initial    int
+	Thereafter int
+
+	now func() time.Time
+
+	mu     sync.Mutex
+	second int64
+	counts map[string]int
+}
+
+func NewSampler(initial, thereafter int) *Sampler {
+	return &Sampler{Initial: initial, Thereafter: thereafter, now: time.Now, counts: make(map[string]int)}
+}
+
+// SetRates changes the number of logs kept, for the logs of the current second onwards.
+func (s *Sampler) SetRates(initial, thereafter int) {
+	s.mu.Lock()
+	s.Initial, s.Thereafter = initial, thereafter
+	s.mu.Unlock()
+}
+
+// Allow tells whether the next log keyed by key is kept. A nil Sampler keeps every log.
+func (s *Sampler) Allow(key string) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := s.now().Unix(); now != s.second {
+		s.second = now
+		s.counts = make(map[string]int, len(s.counts))
+	}
+
+	s.counts[key]++
+	n := s.counts[key]
+
+	if n <= s.Initial {
+		return true
+	}
+
+	return s.Thereafter > 0 && (n-s.Initial)%s.Thereafter == 0
+}
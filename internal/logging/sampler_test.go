@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSamplerAllow(t *testing.T) {
+	tests := []struct {
+		name                string
+		initial, thereafter int
+		want                string
+	}{
+		{name: "first then every third", initial: 2, thereafter: 3, want: "yynnynnyn"},
+		{name: "first only", initial: 2, want: "yynnnnnnn"},
+		{name: "every other", thereafter: 2, want: "nynynynyn"},
+		{name: "every log", initial: 100, want: "yyyyyyyyy"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSampler(tt.initial, tt.thereafter)
+
+			now := time.Unix(1600000000, 0)
+			s.now = func() time.Time { return now }
+
+			var got []byte
+
+			for range tt.want {
+				if s.Allow("key") {
+					got = append(got, 'y')
+				} else {
+					got = append(got, 'n')
+				}
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("Allow() = %s; want %s", got, tt.want)
+			}
+
+			// Every key and every second is sampled on its own.
+			if !s.Allow("other") && tt.initial > 0 {
+				t.Error("Allow() of another key = false; want its first log kept")
+			}
+
+			now = now.Add(time.Second)
+
+			if got := s.Allow("key"); got != (tt.want[0] == 'y') {
+				t.Errorf("Allow() in the next second = %v; want %v", got, tt.want[0] == 'y')
+			}
+		})
+	}
+}
+
+func TestSamplerNil(t *testing.T) {
+	var s *Sampler
+	if !s.Allow("key") {
+		t.Error("Allow() of a nil Sampler = false; want every log kept")
+	}
+}
+
+func TestSamplerSetRates(t *testing.T) {
+	s := NewSampler(1, 0)
+
+	now := time.Unix(1600000000, 0)
+	s.now = func() time.Time { return now }
+
+	if !s.Allow("key") || s.Allow("key") {
+		t.Fatal("Allow() did not keep only the first log")
+	}
+
+	s.SetRates(3, 0)
+
+	if !s.Allow("key") || s.Allow("key") {
+		t.Error("Allow() after SetRates(3, 0) = false, true; want the third log of the second kept, not the fourth")
+	}
+}
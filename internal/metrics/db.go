@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dbStatsCollector exposes the sql.DB.Stats of a connection pool.
+type dbStatsCollector struct {
+	db *sql.DB
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxIdleTimeClosed *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+// RegisterDB adds the statistics of the connection pool db, labelled with the name of the pool, to Registry.
+func RegisterDB(db *sql.DB, pool string) error {
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db", metric), help, nil,
+			prometheus.Labels{"pool": pool})
+	}
+
+	return Registry.Register(&dbStatsCollector{
+		db:                db,
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections to the database."),
+		open:              desc("open_connections", "Number of established connections, in use and idle."),
+		inUse:             desc("in_use_connections", "Number of connections currently in use."),
+		idle:              desc("idle_connections", "Number of idle connections."),
+		waitCount:         desc("wait_count_total", "Number of connections waited for."),
+		waitDuration:      desc("wait_duration_seconds_total", "Time blocked waiting for a new connection."),
+		maxIdleClosed:     desc("max_idle_closed_total", "Number of connections closed due to the idle limit."),
+		maxIdleTimeClosed: desc("max_idle_time_closed_total", "Number of connections closed due to the idle time."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "Number of connections closed due to their lifetime."),
+	})
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxIdleTimeClosed
+	ch <- c.maxLifetimeClosed
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue,
+		float64(stats.MaxIdleTimeClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue,
+		float64(stats.MaxLifetimeClosed))
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "alpha_flow"
+
+// Registry holds every metric of the service along with the Go runtime and process metrics.
+//
+//nolint:gochecknoglobals
+var Registry = prometheus.NewRegistry()
+
+//nolint:gochecknoglobals
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests handled, by method, route pattern and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests, by method, route pattern and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "upstream",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of calls to upstream services, by upstream and path.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"upstream", "path"})
+
+	upstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "upstream",
+		Name:      "errors_total",
+		Help:      "Number of calls to upstream services failing with a transport error or a 5xx status.",
+	}, []string{"upstream", "path"})
+
+	// SignUps counts the accounts created.
+	SignUps = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signups_total",
+		Help:      "Number of accounts created.",
+	})
+
+	// Logins counts the login attempts by result, either success or failure.
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Number of login attempts, by result.",
+	}, []string{"result"})
+
+	// SubscriptionsCreated counts the subscriptions created.
+	SubscriptionsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "subscriptions_created_total",
+		Help:      "Number of subscriptions created.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, upstreamDuration, upstreamErrors,
+		SignUps, Logins, SubscriptionsCreated,
+	)
+}
+
+// Handler serves the metrics of Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts and times every request by the chi route pattern it matched, so paths with parameters share
+// their series. Requests matching no route are reported with the route "unmatched".
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{"method": r.Method, "route": route, "status": strconv.Itoa(status)}
+
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Transport times the calls made through next to the named upstream and counts the failed ones.
+func Transport(upstream string, next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+
+		res, err := next.RoundTrip(r)
+
+		labels := prometheus.Labels{"upstream": upstream, "path": r.URL.Path}
+
+		upstreamDuration.With(labels).Observe(time.Since(start).Seconds())
+
+		if err != nil || res.StatusCode >= http.StatusInternalServerError {
+			upstreamErrors.With(labels).Inc()
+		}
+
+		return res, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
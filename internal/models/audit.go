@@ -0,0 +1,233 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// auditChainLock is the advisory lock serialising the appends of chained audit events.
+const auditChainLock = 7310528114
+
+// AuditEvent is a security relevant event. Changes is a JSON document of the fields changed by the event. Chained
+// events carry the hash of the previous chained event and their own hash, see ComputeHash.
+type AuditEvent struct {
+	ID         int64
+	Type       string
+	ActorID    sql.NullInt64
+	SubjectID  sql.NullInt64
+	IP         string
+	UserAgent  string
+	Changes    []byte
+	OccurredAt time.Time
+	PrevHash   sql.NullString
+	Hash       sql.NullString
+}
+
+// ComputeHash returns the hex SHA-256 of prevHash followed by the JSON of the recorded fields of e. The id is left
+// out as it is assigned once the event is stored, and the changes are normalised so that the hash of a stored event
+// can be computed again.
+func (e *AuditEvent) ComputeHash(prevHash string) (string, error) {
+	var changes interface{}
+
+	if len(e.Changes) > 0 {
+		if err := json.Unmarshal(e.Changes, &changes); err != nil {
+			return "", err
+		}
+	}
+
+	nullable := func(n sql.NullInt64) interface{} {
+		if !n.Valid {
+			return nil
+		}
+
+		return n.Int64
+	}
+
+	data, err := json.Marshal([]interface{}{e.Type, nullable(e.ActorID), nullable(e.SubjectID), e.IP, e.UserAgent,
+		changes, e.OccurredAt.UTC().Format(time.RFC3339Nano)})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(append([]byte(prevHash), data...))
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditFilter selects audit events. Zero values match every event.
+type AuditFilter struct {
+	Types     []string
+	ActorID   int64
+	SubjectID int64
+	Since     time.Time
+	Until     time.Time
+
+	// BeforeID only selects events older than the event with this id.
+	BeforeID int64
+	Limit    int
+}
+
+type AuditModel interface {
+	// Append stores event, which is never changed afterwards. If chain is set the event is hash chained to the last
+	// chained event. Chained appends are serialised so that concurrent events form a single chain.
+	Append(ctx context.Context, event *AuditEvent, chain bool) error
+
+	// Query returns the events matching filter, newest first.
+	Query(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+
+	// Chained returns up to limit chained events stored after the event with id afterID, oldest first.
+	Chained(ctx context.Context, afterID int64, limit int) ([]AuditEvent, error)
+}
+
+// audits stores the audit events in Postgres or SQLite, whose dialect is given.
+type audits struct {
+	conn    *sql.DB
+	db      querier
+	dialect dialect
+	system  string
+}
+
+func (a audits) Append(ctx context.Context, event *AuditEvent, chain bool) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	// Postgres keeps microseconds, the hash has to be computed on the time as stored. SQLite compares the times as
+	// text, which only sorts them in a single time zone.
+	event.OccurredAt = event.OccurredAt.UTC().Truncate(time.Microsecond)
+
+	if !chain {
+		return insertAuditEvent(ctx, a.db, event)
+	}
+
+	tx, err := a.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	q := traceQueries(tx, a.system)
+
+	// The single connection of the SQLite pool serialises the appends already.
+	if a.dialect == dialectPostgres {
+		if _, err = q.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditChainLock); err != nil {
+			return err
+		}
+	}
+
+	query := "SELECT hash from audit_events where hash IS NOT NULL ORDER BY id DESC LIMIT 1"
+
+	err = q.QueryRowContext(ctx, query).Scan(&event.PrevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	hash, err := event.ComputeHash(event.PrevHash.String)
+	if err != nil {
+		return err
+	}
+
+	event.Hash = sql.NullString{String: hash, Valid: true}
+
+	if err = insertAuditEvent(ctx, q, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertAuditEvent(ctx context.Context, q querier, event *AuditEvent) error {
+	query := `INSERT INTO audit_events(type, actor_id, subject_id, ip, user_agent, changes, occurred_at, prev_hash,
+		hash) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+
+	changes := sql.NullString{String: string(event.Changes), Valid: len(event.Changes) > 0}
+
+	return q.QueryRowContext(ctx, query, event.Type, event.ActorID, event.SubjectID, event.IP, event.UserAgent,
+		changes, event.OccurredAt, event.PrevHash, event.Hash).Scan(&event.ID)
+}
+
+func (a audits) Query(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	query, args := auditQuery(a.dialect, filter).SQL()
+
+	return a.query(ctx, query, args...)
+}
+
+// auditQuery selects the events matching filter, newest first, in dialect d.
+func auditQuery(d dialect, filter AuditFilter) *selectQuery {
+	q := newSelect(d, auditEventColumns)
+
+	if len(filter.Types) > 0 {
+		q.Where(inString("type", filter.Types))
+	}
+
+	if filter.ActorID != 0 {
+		q.Where(eq("actor_id", filter.ActorID))
+	}
+
+	if filter.SubjectID != 0 {
+		q.Where(eq("subject_id", filter.SubjectID))
+	}
+
+	if !filter.Since.IsZero() {
+		q.Where(gte("occurred_at", filter.Since.UTC()))
+	}
+
+	if !filter.Until.IsZero() {
+		q.Where(lt("occurred_at", filter.Until.UTC()))
+	}
+
+	if filter.BeforeID != 0 {
+		q.Where(lt("id", filter.BeforeID))
+	}
+
+	return q.OrderBy("id", true).Limit(filter.Limit)
+}
+
+func (a audits) Chained(ctx context.Context, afterID int64, limit int) ([]AuditEvent, error) {
+	query := auditEventColumns + " where hash IS NOT NULL and id > $1 ORDER BY id LIMIT $2"
+
+	return a.query(ctx, query, afterID, limit)
+}
+
+const auditEventColumns = `SELECT id, type, actor_id, subject_id, ip, user_agent, changes, occurred_at, prev_hash,
+	hash from audit_events`
+
+func (a audits) query(ctx context.Context, query string, args ...interface{}) ([]AuditEvent, error) {
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+
+	for rows.Next() {
+		var e AuditEvent
+
+		err = rows.Scan(&e.ID, &e.Type, &e.ActorID, &e.SubjectID, &e.IP, &e.UserAgent, &e.Changes, &e.OccurredAt,
+			&e.PrevHash, &e.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		e.OccurredAt = e.OccurredAt.UTC()
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func NewAudit(db *sql.DB) AuditModel {
+	return &audits{conn: db, db: traceQueries(db, dbSystemPostgres), dialect: dialectPostgres, system: dbSystemPostgres}
+}
+
+// NewSQLiteAudit returns the audit events stored in SQLite, in a database opened by db.OpenSQLite.
+func NewSQLiteAudit(db *sql.DB) AuditModel {
+	return &audits{conn: db, db: traceQueries(db, dbSystemSQLite), dialect: dialectSQLite, system: dbSystemSQLite}
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// auditBackends are the implementations of AuditModel the conformance suite runs against. The audit events of
+// Postgres cannot be deleted, so the suite ignores those stored before it runs.
+//
+//nolint:gochecknoglobals
+var auditBackends = []struct {
+	name string
+	open func(t *testing.T) AuditModel
+}{
+	{"memory", func(t *testing.T) AuditModel { return NewMemoryAudit() }},
+	{"sqlite", func(t *testing.T) AuditModel { return NewSQLiteAudit(openTestSQLite(t, "audit.db")) }},
+	{"postgres", func(t *testing.T) AuditModel { return NewAudit(openTestPostgres(t, "")) }},
+}
+
+func TestAuditModelConformance(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, ctx context.Context, audit AuditModel)
+	}{
+		{"Query", testAuditQuery},
+		{"Chain", testAuditChain},
+	}
+
+	for _, backend := range auditBackends {
+		backend := backend
+
+		t.Run(backend.name, func(t *testing.T) {
+			for _, tt := range tests {
+				tt := tt
+
+				t.Run(tt.name, func(t *testing.T) {
+					tt.run(t, context.Background(), backend.open(t))
+				})
+			}
+		})
+	}
+}
+
+// lastAuditID returns the id of the newest event of audit, 0 if none.
+func lastAuditID(t *testing.T, ctx context.Context, audit AuditModel) int64 {
+	t.Helper()
+
+	events, err := audit.Query(ctx, AuditFilter{Limit: 1})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+
+	if len(events) == 0 {
+		return 0
+	}
+
+	return events[0].ID
+}
+
+func mustAppend(t *testing.T, ctx context.Context, audit AuditModel, event *AuditEvent, chain bool) {
+	t.Helper()
+
+	if err := audit.Append(ctx, event, chain); err != nil {
+		t.Fatalf("Append(%s) error = %v", event.Type, err)
+	}
+}
+
+func testAuditQuery(t *testing.T, ctx context.Context, audit AuditModel) {
+	before := lastAuditID(t, ctx, audit)
+
+	// The times of another zone are compared as the same times in UTC.
+	at := time.Date(2021, 6, 30, 12, 0, 0, 123456789, time.FixedZone("CEST", 2*60*60))
+	events := []*AuditEvent{
+		{
+			Type: "sign_up", ActorID: sql.NullInt64{Int64: 1, Valid: true},
+			SubjectID: sql.NullInt64{Int64: 1, Valid: true}, IP: "192.0.2.1", UserAgent: "curl/7.68.0",
+			Changes: []byte(`{"email": {"after": "alice@example.com"}}`), OccurredAt: at,
+		},
+		{Type: "login_failed", SubjectID: sql.NullInt64{Int64: 1, Valid: true}, OccurredAt: at.Add(time.Minute)},
+		{
+			Type: "login_succeeded", ActorID: sql.NullInt64{Int64: 2, Valid: true},
+			SubjectID: sql.NullInt64{Int64: 2, Valid: true}, OccurredAt: at.Add(2 * time.Minute),
+		},
+	}
+
+	ids := make([]int64, len(events))
+
+	for i, e := range events {
+		mustAppend(t, ctx, audit, e, false)
+
+		if e.ID <= before || i > 0 && e.ID <= ids[i-1] {
+			t.Errorf("Append(%s) id = %d; want an id after %d and the previous events", e.Type, e.ID, before)
+		}
+
+		ids[i] = e.ID
+	}
+
+	stored, err := audit.Query(ctx, AuditFilter{BeforeID: ids[1]})
+	if err != nil || len(stored) == 0 || stored[0].ID != ids[0] {
+		t.Fatalf("Query() = %+v, %v; want event %d first", stored, err, ids[0])
+	}
+
+	var changes, wantChanges interface{}
+	_ = json.Unmarshal(stored[0].Changes, &changes)
+	_ = json.Unmarshal(events[0].Changes, &wantChanges)
+
+	got, want := stored[0], events[0]
+	if got.Type != want.Type || got.ActorID != want.ActorID || got.SubjectID != want.SubjectID || got.IP != want.IP ||
+		got.UserAgent != want.UserAgent || !reflect.DeepEqual(changes, wantChanges) || got.PrevHash.Valid ||
+		got.Hash.Valid {
+		t.Errorf("Query() = %+v; want %+v", got, want)
+	}
+
+	// The time is kept to the microsecond.
+	storedAt := at.Truncate(time.Microsecond)
+	if !got.OccurredAt.Equal(storedAt) || got.OccurredAt.Location() != time.UTC {
+		t.Errorf("Query() occurred at %v; want %v in UTC", got.OccurredAt, storedAt)
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []int64
+	}{
+		{name: "every event", want: []int64{ids[2], ids[1], ids[0]}},
+		{
+			name: "types", filter: AuditFilter{Types: []string{"sign_up", "login_succeeded"}},
+			want: []int64{ids[2], ids[0]},
+		},
+		{name: "actor", filter: AuditFilter{ActorID: 2}, want: []int64{ids[2]}},
+		{name: "subject", filter: AuditFilter{SubjectID: 1}, want: []int64{ids[1], ids[0]}},
+		{name: "since", filter: AuditFilter{Since: storedAt.Add(time.Minute)}, want: []int64{ids[2], ids[1]}},
+		{name: "until", filter: AuditFilter{Until: storedAt.Add(time.Minute)}, want: []int64{ids[0]}},
+		{name: "before id", filter: AuditFilter{BeforeID: ids[2]}, want: []int64{ids[1], ids[0]}},
+		{name: "limit", filter: AuditFilter{Limit: 2}, want: []int64{ids[2], ids[1]}},
+		{name: "every filter", filter: AuditFilter{Types: []string{"login_failed"}, SubjectID: 1, Since: storedAt,
+			Until: storedAt.Add(2 * time.Minute), BeforeID: ids[2], Limit: 10}, want: []int64{ids[1]}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := audit.Query(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+
+			var got []int64
+
+			for _, e := range events {
+				if e.ID > before {
+					got = append(got, e.ID)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%+v) = %v; want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func testAuditChain(t *testing.T, ctx context.Context, audit AuditModel) {
+	before := lastAuditID(t, ctx, audit)
+
+	first := &AuditEvent{Type: "login_succeeded", ActorID: sql.NullInt64{Int64: 1, Valid: true}}
+	unchained := &AuditEvent{Type: "token_issued", ActorID: sql.NullInt64{Int64: 1, Valid: true}}
+	second := &AuditEvent{Type: "login_failed", Changes: []byte(`{"email": {"after": "alice@example.com"}}`)}
+
+	mustAppend(t, ctx, audit, first, true)
+	mustAppend(t, ctx, audit, unchained, false)
+	mustAppend(t, ctx, audit, second, true)
+
+	if !first.Hash.Valid || unchained.PrevHash.Valid || unchained.Hash.Valid || second.PrevHash != first.Hash {
+		t.Errorf("Append() hashes = %v, %v, %v; want the second chained event linked to the first", first.Hash,
+			unchained.Hash, second.PrevHash)
+	}
+
+	chained, err := audit.Chained(ctx, before, 10)
+	if err != nil {
+		t.Fatalf("Chained() error = %v", err)
+	}
+
+	if len(chained) != 2 || chained[0].ID != first.ID || chained[1].ID != second.ID {
+		t.Fatalf("Chained() = %+v; want events %d and %d", chained, first.ID, second.ID)
+	}
+
+	// The hashes are computed again on the events as stored, as the chain is verified.
+	for _, e := range chained {
+		e := e
+
+		if hash, err := e.ComputeHash(e.PrevHash.String); err != nil || hash != e.Hash.String {
+			t.Errorf("ComputeHash() of stored event %d = %s, %v; want %s", e.ID, hash, err, e.Hash.String)
+		}
+	}
+
+	if chained, err = audit.Chained(ctx, before, 1); err != nil || len(chained) != 1 || chained[0].ID != first.ID {
+		t.Errorf("Chained() limited to 1 = %+v, %v; want event %d", chained, err, first.ID)
+	}
+
+	if chained, err = audit.Chained(ctx, first.ID, 10); err != nil || len(chained) != 1 || chained[0].ID != second.ID {
+		t.Errorf("Chained() after %d = %+v, %v; want event %d", first.ID, chained, err, second.ID)
+	}
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func testAuditEvent() AuditEvent {
+	return AuditEvent{
+		ID:         42,
+		Type:       "login_failed",
+		SubjectID:  sql.NullInt64{Int64: 7, Valid: true},
+		IP:         "192.0.2.1",
+		UserAgent:  "curl/7.68.0",
+		Changes:    []byte(`{"email": {"after": "alice@example.com"}}`),
+		OccurredAt: time.Date(2021, 6, 30, 12, 0, 0, 123456000, time.UTC),
+	}
+}
+
+func TestAuditEventComputeHash(t *testing.T) {
+	// The hash of stored events is computed again to verify the chain, so its input must never change: want is the
+	// SHA-256 of prevHash followed by ["login_failed",null,7,"192.0.2.1","curl/7.68.0",
+	// {"email":{"after":"alice@example.com"}},"2021-06-30T12:00:00.123456Z"].
+	const (
+		prevHash = "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"
+		want     = "5fc080043856c7376c62c911a32bf6e5b12ddb8ce54adb139cfeb08ce84567b6"
+	)
+
+	event := testAuditEvent()
+
+	got, err := event.ComputeHash(prevHash)
+	if err != nil {
+		t.Fatalf("ComputeHash() error = %v", err)
+	}
+
+	if got != want {
+		t.Errorf("ComputeHash() = %s; want %s", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(e *AuditEvent)
+		prevHash string
+		wantSame bool
+	}{
+		{name: "id", modify: func(e *AuditEvent) { e.ID = 43 }, wantSame: true},
+		{name: "stored hashes", modify: func(e *AuditEvent) {
+			e.PrevHash = sql.NullString{String: "x", Valid: true}
+			e.Hash = sql.NullString{String: "y", Valid: true}
+		}, wantSame: true},
+		{
+			name:     "changes formatting",
+			modify:   func(e *AuditEvent) { e.Changes = []byte(`{"email":{"after":"alice@example.com"}}`) },
+			wantSame: true,
+		},
+		{
+			name: "time zone",
+			modify: func(e *AuditEvent) {
+				e.OccurredAt = e.OccurredAt.In(time.FixedZone("CEST", 2*60*60))
+			},
+			wantSame: true,
+		},
+		{name: "previous hash", modify: func(*AuditEvent) {}, prevHash: "0"},
+		{name: "type", modify: func(e *AuditEvent) { e.Type = "login_succeeded" }},
+		{name: "actor", modify: func(e *AuditEvent) { e.ActorID = sql.NullInt64{Int64: 7, Valid: true} }},
+		{name: "zero subject", modify: func(e *AuditEvent) { e.SubjectID = sql.NullInt64{Valid: true} }},
+		{name: "ip", modify: func(e *AuditEvent) { e.IP = "192.0.2.2" }},
+		{name: "user agent", modify: func(e *AuditEvent) { e.UserAgent = "" }},
+		{name: "changes", modify: func(e *AuditEvent) { e.Changes = nil }},
+		{name: "time", modify: func(e *AuditEvent) { e.OccurredAt = e.OccurredAt.Add(time.Microsecond) }},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			e := testAuditEvent()
+			tt.modify(&e)
+
+			if tt.prevHash == "" {
+				tt.prevHash = prevHash
+			}
+
+			hash, err := e.ComputeHash(tt.prevHash)
+			if err != nil {
+				t.Fatalf("ComputeHash() error = %v", err)
+			}
+
+			if same := hash == got; same != tt.wantSame {
+				t.Errorf("ComputeHash() = %s, unchanged %v; want unchanged %v", hash, same, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestAuditEventComputeHashMalformedChanges(t *testing.T) {
+	event := testAuditEvent()
+	event.Changes = []byte(`{"email":`)
+
+	if _, err := event.ComputeHash(""); err == nil {
+		t.Error("ComputeHash() of malformed changes succeeded; want an error")
+	}
+}
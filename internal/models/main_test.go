@@ -0,0 +1,80 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/maknahar/alpha-flow/internal/db"
+)
+
+// testPostgres is the throwaway database of the Postgres suites, created and migrated on the server of
+// TEST_DATABASE_URL by the first of them and dropped by TestMain.
+//
+//nolint:gochecknoglobals
+var testPostgres struct {
+	once sync.Once
+	url  string
+	err  error
+	drop func() error
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+
+	if testPostgres.drop != nil {
+		if err := testPostgres.drop(); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to drop the test database: %v\n", err)
+		}
+	}
+
+	os.Exit(code)
+}
+
+// createTestPostgres creates a migrated database of a random name on the server of the postgres:// URL dsn, so that
+// the database dsn names is never written to, and returns its URL and the function dropping it.
+func createTestPostgres(dsn string) (string, func() error, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w; invalid TEST_DATABASE_URL", err)
+	}
+
+	b := make([]byte, 8)
+	if _, err = rand.Read(b); err != nil {
+		return "", nil, err
+	}
+
+	name := "alpha_flow_test_" + hex.EncodeToString(b)
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err = conn.Exec("CREATE DATABASE " + name); err != nil {
+		conn.Close()
+		return "", nil, fmt.Errorf("%w; unable to create a database on the server of TEST_DATABASE_URL", err)
+	}
+
+	drop := func() error {
+		defer conn.Close()
+
+		_, err := conn.Exec("DROP DATABASE " + name)
+
+		return err
+	}
+
+	u.Path = "/" + name
+
+	if err = (&db.Postgres{}).Migrate(db.StaticDSN(u.String()), ""); err != nil {
+		_ = drop()
+		return "", nil, err
+	}
+
+	return u.String(), drop, nil
+}
@@ -0,0 +1,200 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/maknahar/alpha-flow/internal/models (interfaces: UserModel)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/maknahar/alpha-flow/internal/models"
+	reflect "reflect"
+)
+
+// MockUserModel is a mock of UserModel interface
+type MockUserModel struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserModelMockRecorder
+}
+
+// MockUserModelMockRecorder is the mock recorder for MockUserModel
+type MockUserModelMockRecorder struct {
+	mock *MockUserModel
+}
+
+// NewMockUserModel creates a new mock instance
+func NewMockUserModel(ctrl *gomock.Controller) *MockUserModel {
+	mock := &MockUserModel{ctrl: ctrl}
+	mock.recorder = &MockUserModelMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockUserModel) EXPECT() *MockUserModelMockRecorder {
+	return m.recorder
+}
+
+// ByEmail mocks base method
+func (m *MockUserModel) ByEmail(arg0 context.Context, arg1 string) (*models.UserDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ByEmail", arg0, arg1)
+	ret0, _ := ret[0].(*models.UserDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ByEmail indicates an expected call of ByEmail
+func (mr *MockUserModelMockRecorder) ByEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ByEmail", reflect.TypeOf((*MockUserModel)(nil).ByEmail), arg0, arg1)
+}
+
+// ByIDs mocks base method
+func (m *MockUserModel) ByIDs(arg0 context.Context, arg1 []int64) ([]*models.UserDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ByIDs", arg0, arg1)
+	ret0, _ := ret[0].([]*models.UserDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ByIDs indicates an expected call of ByIDs
+func (mr *MockUserModelMockRecorder) ByIDs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ByIDs", reflect.TypeOf((*MockUserModel)(nil).ByIDs), arg0, arg1)
+}
+
+// ChangeCredentials mocks base method
+func (m *MockUserModel) ChangeCredentials(arg0 context.Context, arg1, arg2, arg3 string, arg4 int64) (*models.UserDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeCredentials", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*models.UserDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangeCredentials indicates an expected call of ChangeCredentials
+func (mr *MockUserModelMockRecorder) ChangeCredentials(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeCredentials", reflect.TypeOf((*MockUserModel)(nil).ChangeCredentials), arg0, arg1, arg2, arg3, arg4)
+}
+
+// Create mocks base method
+func (m *MockUserModel) Create(arg0 context.Context, arg1, arg2 string) (*models.UserDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.UserDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create
+func (mr *MockUserModelMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserModel)(nil).Create), arg0, arg1, arg2)
+}
+
+// CreateSubscription mocks base method
+func (m *MockUserModel) CreateSubscription(arg0 context.Context, arg1 int64, arg2 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSubscription", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSubscription indicates an expected call of CreateSubscription
+func (mr *MockUserModelMockRecorder) CreateSubscription(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSubscription", reflect.TypeOf((*MockUserModel)(nil).CreateSubscription), arg0, arg1, arg2)
+}
+
+// GetDetails mocks base method
+func (m *MockUserModel) GetDetails(arg0 context.Context, arg1 string) (*models.UserDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDetails", arg0, arg1)
+	ret0, _ := ret[0].(*models.UserDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDetails indicates an expected call of GetDetails
+func (mr *MockUserModelMockRecorder) GetDetails(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDetails", reflect.TypeOf((*MockUserModel)(nil).GetDetails), arg0, arg1)
+}
+
+// IssueToken mocks base method
+func (m *MockUserModel) IssueToken(arg0 context.Context, arg1 int64) (*models.UserDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssueToken", arg0, arg1)
+	ret0, _ := ret[0].(*models.UserDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IssueToken indicates an expected call of IssueToken
+func (mr *MockUserModelMockRecorder) IssueToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueToken", reflect.TypeOf((*MockUserModel)(nil).IssueToken), arg0, arg1)
+}
+
+// PasswordHash mocks base method
+func (m *MockUserModel) PasswordHash(arg0 context.Context, arg1 string) (int64, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PasswordHash", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// PasswordHash indicates an expected call of PasswordHash
+func (mr *MockUserModelMockRecorder) PasswordHash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PasswordHash", reflect.TypeOf((*MockUserModel)(nil).PasswordHash), arg0, arg1)
+}
+
+// PasswordHistory mocks base method
+func (m *MockUserModel) PasswordHistory(arg0 context.Context, arg1 int64, arg2 int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PasswordHistory", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PasswordHistory indicates an expected call of PasswordHistory
+func (mr *MockUserModelMockRecorder) PasswordHistory(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PasswordHistory", reflect.TypeOf((*MockUserModel)(nil).PasswordHistory), arg0, arg1, arg2)
+}
+
+// SetPasswordHash mocks base method
+func (m *MockUserModel) SetPasswordHash(arg0 context.Context, arg1 int64, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPasswordHash", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPasswordHash indicates an expected call of SetPasswordHash
+func (mr *MockUserModelMockRecorder) SetPasswordHash(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPasswordHash", reflect.TypeOf((*MockUserModel)(nil).SetPasswordHash), arg0, arg1, arg2)
+}
+
+// Subscriptions mocks base method
+func (m *MockUserModel) Subscriptions(arg0 context.Context, arg1 []int64) ([]models.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscriptions", arg0, arg1)
+	ret0, _ := ret[0].([]models.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscriptions indicates an expected call of Subscriptions
+func (mr *MockUserModelMockRecorder) Subscriptions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscriptions", reflect.TypeOf((*MockUserModel)(nil).Subscriptions), arg0, arg1)
+}
@@ -0,0 +1,253 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// dialect is the SQL dialect a statement is built for. Both number their placeholders $1, $2, etc.
+type dialect int
+
+const (
+	dialectPostgres dialect = iota
+	dialectSQLite
+)
+
+// column is the name of a column, written into the statement as is. Callers must only pass constants, never input:
+// every value is passed as an argument.
+type column string
+
+// statement collects the arguments of a statement and numbers their placeholders.
+type statement struct {
+	dialect dialect
+	args    []interface{}
+}
+
+// arg adds v to the arguments and returns its placeholder.
+func (s *statement) arg(v interface{}) string {
+	s.args = append(s.args, v)
+
+	return "$" + strconv.Itoa(len(s.args))
+}
+
+// cond is a condition of a WHERE clause, rendered along with its arguments.
+type cond func(s *statement) string
+
+func eq(c column, v interface{}) cond {
+	return func(s *statement) string { return string(c) + " = " + s.arg(v) }
+}
+
+func lt(c column, v interface{}) cond {
+	return func(s *statement) string { return string(c) + " < " + s.arg(v) }
+}
+
+func gte(c column, v interface{}) cond {
+	return func(s *statement) string { return string(c) + " >= " + s.arg(v) }
+}
+
+// inInt64 matches the rows whose column c is one of values. Postgres gets them as a single array argument, SQLite as
+// one argument each.
+func inInt64(c column, values []int64) cond {
+	return func(s *statement) string {
+		if s.dialect == dialectPostgres {
+			return string(c) + " = ANY(" + s.arg(pq.Array(values)) + ")"
+		}
+
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			placeholders[i] = s.arg(v)
+		}
+
+		return inList(c, placeholders)
+	}
+}
+
+// inString matches the rows whose column c is one of values, passed as inInt64 passes them.
+func inString(c column, values []string) cond {
+	return func(s *statement) string {
+		if s.dialect == dialectPostgres {
+			return string(c) + " = ANY(" + s.arg(pq.Array(values)) + ")"
+		}
+
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			placeholders[i] = s.arg(v)
+		}
+
+		return inList(c, placeholders)
+	}
+}
+
+// inList renders the condition of column c being one of the values of placeholders.
+func inList(c column, placeholders []string) string {
+	return string(c) + " IN (" + strings.Join(placeholders, ", ") + ")"
+}
+
+// where renders conds joined by AND as a WHERE clause, nothing if there are none.
+func (s *statement) where(conds []cond) string {
+	if len(conds) == 0 {
+		return ""
+	}
+
+	rendered := make([]string, len(conds))
+	for i, c := range conds {
+		rendered[i] = c(s)
+	}
+
+	return " where " + strings.Join(rendered, " and ")
+}
+
+// errEmptyUpdate is returned for an UPDATE without any column set, which is not a valid statement.
+var errEmptyUpdate = errors.New("update without any column set")
+
+// updateQuery builds an UPDATE of the columns given to Set on the rows matching the conditions given to Where.
+type updateQuery struct {
+	dialect dialect
+	table   string
+	columns []column
+	values  []interface{}
+	conds   []cond
+}
+
+func newUpdate(d dialect, table string) *updateQuery {
+	return &updateQuery{dialect: d, table: table}
+}
+
+// Set sets column c to v.
+func (q *updateQuery) Set(c column, v interface{}) *updateQuery {
+	q.columns = append(q.columns, c)
+	q.values = append(q.values, v)
+
+	return q
+}
+
+// Where adds a condition the updated rows have to match.
+func (q *updateQuery) Where(c cond) *updateQuery {
+	q.conds = append(q.conds, c)
+	return q
+}
+
+// SQL returns the statement and its arguments, errEmptyUpdate if no column is set.
+func (q *updateQuery) SQL() (string, []interface{}, error) {
+	if len(q.columns) == 0 {
+		return "", nil, errEmptyUpdate
+	}
+
+	s := &statement{dialect: q.dialect}
+
+	set := make([]string, len(q.columns))
+	for i, c := range q.columns {
+		set[i] = string(c) + " = " + s.arg(q.values[i])
+	}
+
+	query := "UPDATE " + q.table + " set " + strings.Join(set, ", ") + s.where(q.conds)
+
+	return query, s.args, nil
+}
+
+// selectQuery builds a SELECT of fixed columns, e.g. "SELECT id, email from users", filtered by the conditions given to
+// Where.
+type selectQuery struct {
+	dialect dialect
+	base    string
+	conds   []cond
+	orderBy []string
+	limit   int
+}
+
+func newSelect(d dialect, base string) *selectQuery {
+	return &selectQuery{dialect: d, base: base}
+}
+
+// Where adds a condition the selected rows have to match.
+func (q *selectQuery) Where(c cond) *selectQuery {
+	q.conds = append(q.conds, c)
+	return q
+}
+
+// OrderBy sorts the rows by c, after the columns it was already called with.
+func (q *selectQuery) OrderBy(c column, desc bool) *selectQuery {
+	order := string(c)
+	if desc {
+		order += " DESC"
+	}
+
+	q.orderBy = append(q.orderBy, order)
+
+	return q
+}
+
+// Limit selects at most n rows, every row if 0.
+func (q *selectQuery) Limit(n int) *selectQuery {
+	q.limit = n
+	return q
+}
+
+// SQL returns the statement and its arguments.
+func (q *selectQuery) SQL() (string, []interface{}) {
+	s := &statement{dialect: q.dialect}
+
+	query := q.base + s.where(q.conds)
+
+	if len(q.orderBy) > 0 {
+		query += " ORDER BY " + strings.Join(q.orderBy, ", ")
+	}
+
+	if q.limit > 0 {
+		query += " LIMIT " + s.arg(q.limit)
+	}
+
+	return query, s.args
+}
+
+// insertQuery builds an INSERT of the rows given to Values.
+type insertQuery struct {
+	dialect dialect
+	table   string
+	columns []column
+	rows    [][]interface{}
+}
+
+func newInsert(d dialect, table string, columns ...column) *insertQuery {
+	return &insertQuery{dialect: d, table: table, columns: columns}
+}
+
+// Values adds a row, with a value for each column in order.
+func (q *insertQuery) Values(values ...interface{}) *insertQuery {
+	if len(values) != len(q.columns) {
+		panic(fmt.Sprintf("insert into %s: %d values for %d columns", q.table, len(values), len(q.columns)))
+	}
+
+	q.rows = append(q.rows, values)
+
+	return q
+}
+
+// SQL returns the statement and its arguments.
+func (q *insertQuery) SQL() (string, []interface{}) {
+	s := &statement{dialect: q.dialect}
+
+	columns := make([]string, len(q.columns))
+	for i, c := range q.columns {
+		columns[i] = string(c)
+	}
+
+	rows := make([]string, len(q.rows))
+
+	for i, row := range q.rows {
+		placeholders := make([]string, len(row))
+		for j, v := range row {
+			placeholders[j] = s.arg(v)
+		}
+
+		rows[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	query := "INSERT INTO " + q.table + "(" + strings.Join(columns, ", ") + ") VALUES " + strings.Join(rows, ", ")
+
+	return query, s.args
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//nolint:gochecknoglobals
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+// builder is any of the query builders.
+type builder interface {
+	SQL() (string, []interface{})
+}
+
+// validUpdate is an update with a column set, whose statement is built without an error.
+type validUpdate struct {
+	*updateQuery
+}
+
+func (q validUpdate) SQL() (string, []interface{}) {
+	query, args, err := q.updateQuery.SQL()
+	if err != nil {
+		panic(err)
+	}
+
+	return query, args
+}
+
+func TestQueryBuilders(t *testing.T) {
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query builder
+	}{
+		{"update_email", validUpdate{newUpdate(dialectPostgres, "users").Set("email", "a@example.com").
+			Where(eq("id", int64(1)))}},
+		{"update_email_and_password", validUpdate{newUpdate(dialectPostgres, "users").Set("email", "a@example.com").
+			Set("password", "hash").Where(eq("id", int64(1)))}},
+		{"update_sqlite", validUpdate{newUpdate(dialectSQLite, "users").Set("updated_at", now).
+			Set("email", "a@example.com").Set("password", "hash").Where(eq("id", int64(1)))}},
+		{"select_without_conditions", newSelect(dialectPostgres, userColumns)},
+		{"select_in_postgres", newSelect(dialectPostgres, userColumns).Where(inInt64("id", []int64{1, 2, 3}))},
+		{"select_in_sqlite", newSelect(dialectSQLite, userColumns).Where(inInt64("id", []int64{1, 2, 3}))},
+		{"select_in_sqlite_strings", newSelect(dialectSQLite, "SELECT user_id, pair, created_at from subscriptions").
+			Where(inString("pair", []string{"BTC-USD", "ETH-USD"})).OrderBy("created_at", false).
+			OrderBy("pair", false)},
+		{"audit_without_filter", auditQuery(dialectPostgres, AuditFilter{})},
+		{"audit_every_filter", auditQuery(dialectPostgres, AuditFilter{
+			Types:     []string{"login", "login_failed"},
+			ActorID:   1,
+			SubjectID: 2,
+			Since:     now,
+			Until:     now.Add(time.Hour),
+			BeforeID:  100,
+			Limit:     50,
+		})},
+		{"audit_sqlite", auditQuery(dialectSQLite, AuditFilter{Types: []string{"login", "login_failed"}, Limit: 50})},
+		{"insert_rows", newInsert(dialectPostgres, "rates", "pair", "rate", "observed_at").
+			Values("BTC-USD", 30000.5, now).Values("ETH-USD", 700.25, now)},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := tt.query.SQL()
+
+			var got strings.Builder
+			got.WriteString(query + "\n")
+
+			for i, arg := range args {
+				fmt.Fprintf(&got, "$%d %T %v\n", i+1, arg, arg)
+			}
+
+			golden := filepath.Join("testdata", "query", tt.name+".golden")
+
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := ioutil.WriteFile(golden, []byte(got.String()), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading %s, run go test -update to create it: %v", golden, err)
+			}
+
+			if got.String() != string(want) {
+				t.Errorf("%s:\n%s\nwant:\n%s", tt.name, got.String(), want)
+			}
+		})
+	}
+}
+
+func TestUpdateQueryWithoutColumns(t *testing.T) {
+	query, args, err := newUpdate(dialectPostgres, "users").Where(eq("id", int64(1))).SQL()
+	if !errors.Is(err, errEmptyUpdate) || query != "" || args != nil {
+		t.Errorf("SQL() = %q, %v, %v; want errEmptyUpdate", query, args, err)
+	}
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Rate is the exchange rate of a pair observed at a point in time.
+type Rate struct {
+	Pair       string
+	Rate       float64
+	ObservedAt time.Time
+}
+
+type RateModel interface {
+	// Record stores the observed rates in a single statement.
+	Record(ctx context.Context, rates []Rate) error
+
+	// History returns up to limit most recent rates of every given pair in a single query, newest first.
+	History(ctx context.Context, pairs []string, limit int) ([]Rate, error)
+}
+
+// rates stores the rates in Postgres or SQLite, whose dialect is given.
+type rates struct {
+	db      querier
+	dialect dialect
+}
+
+func (r rates) Record(ctx context.Context, observed []Rate) error {
+	if len(observed) == 0 {
+		return nil
+	}
+
+	// SQLite compares the times as text, which only sorts them in a single time zone.
+	insert := newInsert(r.dialect, "rates", "pair", "rate", "observed_at")
+	for _, rate := range observed {
+		insert.Values(rate.Pair, rate.Rate, rate.ObservedAt.UTC())
+	}
+
+	query, args := insert.SQL()
+
+	_, err := r.db.ExecContext(ctx, query, args...)
+
+	return err
+}
+
+func (r rates) History(ctx context.Context, pairs []string, limit int) ([]Rate, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	s := &statement{dialect: r.dialect}
+	query := `SELECT pair, rate, observed_at from
+		(SELECT pair, rate, observed_at, row_number() OVER (PARTITION BY pair ORDER BY observed_at DESC) AS n
+			from rates` + s.where([]cond{inString("pair", pairs)}) + `) AS r
+		where n <= ` + s.arg(limit) + ` ORDER BY pair, observed_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, s.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []Rate
+
+	for rows.Next() {
+		var rate Rate
+
+		if err = rows.Scan(&rate.Pair, &rate.Rate, &rate.ObservedAt); err != nil {
+			return nil, err
+		}
+
+		rate.ObservedAt = rate.ObservedAt.UTC()
+		history = append(history, rate)
+	}
+
+	return history, rows.Err()
+}
+
+func NewRate(db *sql.DB) RateModel {
+	return &rates{db: traceQueries(db, dbSystemPostgres), dialect: dialectPostgres}
+}
+
+// NewSQLiteRate returns the rates stored in SQLite, in a database opened by db.OpenSQLite.
+func NewSQLiteRate(db *sql.DB) RateModel {
+	return &rates{db: traceQueries(db, dbSystemSQLite), dialect: dialectSQLite}
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// rateBackends are the implementations of RateModel the conformance suite runs against. Each returns no rates.
+//
+//nolint:gochecknoglobals
+var rateBackends = []struct {
+	name string
+	open func(t *testing.T) RateModel
+}{
+	{"memory", func(t *testing.T) RateModel { return NewMemoryRate() }},
+	{"sqlite", func(t *testing.T) RateModel { return NewSQLiteRate(openTestSQLite(t, "rates.db")) }},
+	{"postgres", func(t *testing.T) RateModel { return NewRate(openTestPostgres(t, "rates")) }},
+}
+
+func TestRateModelConformance(t *testing.T) {
+	for _, backend := range rateBackends {
+		backend := backend
+
+		t.Run(backend.name, func(t *testing.T) {
+			testRates(t, context.Background(), backend.open(t))
+		})
+	}
+}
+
+func testRates(t *testing.T, ctx context.Context, rates RateModel) {
+	if err := rates.Record(ctx, nil); err != nil {
+		t.Fatalf("Record() of no rates error = %v", err)
+	}
+
+	// The times of another zone are sorted as the same times in UTC.
+	at := time.Date(2021, 6, 30, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	err := rates.Record(ctx, []Rate{
+		{Pair: "BTC-USD", Rate: 30000.5, ObservedAt: at.Add(time.Minute)},
+		{Pair: "ETH-USD", Rate: 700.25, ObservedAt: at},
+		{Pair: "BTC-USD", Rate: 30100, ObservedAt: at.Add(2 * time.Minute).UTC()},
+		{Pair: "BTC-USD", Rate: 29900, ObservedAt: at},
+	})
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		pairs []string
+		limit int
+		want  []Rate
+	}{
+		{name: "no pairs", limit: 10},
+		{name: "unknown pair", pairs: []string{"XRP-USD"}, limit: 10},
+		{
+			name: "newest first by pair", pairs: []string{"ETH-USD", "XRP-USD", "BTC-USD"}, limit: 10,
+			want: []Rate{
+				{Pair: "BTC-USD", Rate: 30100, ObservedAt: at.Add(2 * time.Minute)},
+				{Pair: "BTC-USD", Rate: 30000.5, ObservedAt: at.Add(time.Minute)},
+				{Pair: "BTC-USD", Rate: 29900, ObservedAt: at},
+				{Pair: "ETH-USD", Rate: 700.25, ObservedAt: at},
+			},
+		},
+		{
+			name: "limit of every pair", pairs: []string{"BTC-USD", "ETH-USD"}, limit: 2,
+			want: []Rate{
+				{Pair: "BTC-USD", Rate: 30100, ObservedAt: at.Add(2 * time.Minute)},
+				{Pair: "BTC-USD", Rate: 30000.5, ObservedAt: at.Add(time.Minute)},
+				{Pair: "ETH-USD", Rate: 700.25, ObservedAt: at},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rates.History(ctx, tt.pairs, tt.limit)
+			if err != nil {
+				t.Fatalf("History() error = %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("History() = %+v; want %+v", got, tt.want)
+			}
+
+			for i, rate := range got {
+				want := tt.want[i]
+				if rate.Pair != want.Pair || rate.Rate != want.Rate || !rate.ObservedAt.Equal(want.ObservedAt) {
+					t.Errorf("History()[%d] = %+v; want %+v", i, rate, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// memoryRates keeps the rates in memory, for unit tests and local runs. Nothing outlives the process.
+type memoryRates struct {
+	mu     sync.RWMutex
+	byPair map[string][]Rate
+}
+
+func (r *memoryRates) Record(_ context.Context, observed []Rate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, rate := range observed {
+		rate.ObservedAt = rate.ObservedAt.UTC()
+		r.byPair[rate.Pair] = append(r.byPair[rate.Pair], rate)
+	}
+
+	return nil
+}
+
+func (r *memoryRates) History(_ context.Context, pairs []string, limit int) ([]Rate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool, len(pairs))
+
+	var sorted []string
+
+	for _, pair := range pairs {
+		if !seen[pair] {
+			seen[pair] = true
+			sorted = append(sorted, pair)
+		}
+	}
+
+	sort.Strings(sorted)
+
+	var history []Rate
+
+	for _, pair := range sorted {
+		rates := append([]Rate(nil), r.byPair[pair]...)
+		sort.SliceStable(rates, func(i, j int) bool { return rates[i].ObservedAt.After(rates[j].ObservedAt) })
+
+		if len(rates) > limit {
+			rates = rates[:limit]
+		}
+
+		history = append(history, rates...)
+	}
+
+	return history, nil
+}
+
+// NewMemoryRate returns rates kept in memory, starting with none.
+func NewMemoryRate() RateModel {
+	return &memoryRates{byPair: make(map[string][]Rate)}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// stickyPruneSize is the number of users written to above which the expired ones are forgotten on the next write.
+const stickyPruneSize = 1024
+
+// Replicas picks the read replica a read is sent to. It is implemented by *db.Replicas.
+type Replicas interface {
+	// Reader returns the pool of a healthy replica, nil if none is.
+	Reader() *sql.DB
+}
+
+// Replication routes the reads of the models to read replicas, but for the users written to within the sticky window,
+// whose reads go to the primary so that they see their own writes despite the replication lag. The window is kept by
+// every instance of the service for its own writes only: the writes of the others are read once replicated, the
+// replicas being health checked to lag behind by a bounded time. A nil *Replication reads everything from the primary.
+type Replication struct {
+	replicas Replicas
+	window   time.Duration
+
+	mu     sync.Mutex
+	writes map[int64]time.Time
+}
+
+// NewReplication returns the routing of reads to replicas, sticking to the primary for stickyWindow after a write.
+func NewReplication(replicas Replicas, stickyWindow time.Duration) *Replication {
+	return &Replication{replicas: replicas, window: stickyWindow, writes: make(map[int64]time.Time)}
+}
+
+// wrote records a write to the users with given ids.
+func (r *Replication) wrote(ids ...int64) {
+	if r == nil || r.window <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.writes) >= stickyPruneSize {
+		for id, until := range r.writes {
+			if now.After(until) {
+				delete(r.writes, id)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		r.writes[id] = now.Add(r.window)
+	}
+}
+
+// sticky tells whether any of the users with given ids was written to within the sticky window.
+func (r *Replication) sticky(ids ...int64) bool {
+	if r == nil || r.window <= 0 {
+		return false
+	}
+
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		if until, ok := r.writes[id]; ok && now.Before(until) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replica returns a healthy replica to read from, nil if the reads go to the primary.
+func (r *Replication) replica() querier {
+	if r == nil {
+		return nil
+	}
+
+	conn := r.replicas.Reader()
+	if conn == nil {
+		return nil
+	}
+
+	return traceQueries(conn, dbSystemPostgres)
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maknahar/alpha-flow/internal/db"
+)
+
+// fakeReplicas always picks conn, nil standing for no healthy replica.
+type fakeReplicas struct {
+	conn *sql.DB
+}
+
+func (f *fakeReplicas) Reader() *sql.DB {
+	return f.conn
+}
+
+func openTestSQLite(t *testing.T, name string) *sql.DB {
+	t.Helper()
+
+	conn, err := db.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatalf("OpenSQLite() error = %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// TestReplicationRouting runs the statements of the Postgres users on two SQLite databases standing for the primary
+// and a lagging replica, told apart by the secret of the users.
+func TestReplicationRouting(t *testing.T) {
+	ctx := context.Background()
+	primary, replica := openTestSQLite(t, "primary.db"), openTestSQLite(t, "replica.db")
+
+	for _, conn := range []*sql.DB{primary, replica} {
+		if _, err := NewSQLiteUser(conn).Create(ctx, "alice@example.com", "hash"); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	if _, err := replica.Exec("UPDATE users set secret='replica'"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob is not replicated yet.
+	bob, err := NewSQLiteUser(primary).Create(ctx, "bob@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	replicas := &fakeReplicas{conn: replica}
+	replication := NewReplication(replicas, time.Minute)
+	model := &users{db: traceQueries(primary, dbSystemSQLite), replication: replication}
+
+	alice, err := model.ByEmail(ctx, "alice@example.com")
+	if err != nil || alice.Secret.String != "replica" {
+		t.Errorf("ByEmail() = %+v, %v; want alice read from the replica", alice, err)
+	}
+
+	if got, err := model.ByEmail(ctx, "bob@example.com"); err != nil || got.ID != bob.ID {
+		t.Errorf("ByEmail() of a user missing from the replica = %+v, %v; want bob read from the primary", got,
+			err)
+	}
+
+	if _, err = model.ByEmail(ctx, "carol@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ByEmail() of an unknown user error = %v; want sql.ErrNoRows", err)
+	}
+
+	// The replica still has the token of alice that a login replaced on the primary.
+	if _, err = replica.Exec("UPDATE users set token='replaced'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := model.GetDetails(ctx, "replaced"); err != nil || got.Secret.String != "replica" {
+		t.Errorf("GetDetails() = %+v, %v; want alice read from the replica", got, err)
+	}
+
+	replication.wrote(alice.ID)
+
+	// Once the login is known to this instance, the token is looked up again on the primary, which refuses it.
+	if _, err = model.GetDetails(ctx, "replaced"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetDetails() of a replaced token within the sticky window error = %v; want sql.ErrNoRows", err)
+	}
+
+	if got, _ := model.ByEmail(ctx, "alice@example.com"); got.Secret.String == "replica" {
+		t.Error("ByEmail() within the sticky window read from the replica; want the primary")
+	}
+
+	if got, err := model.load(ctx, alice.ID); err != nil || got.Secret.String == "replica" {
+		t.Errorf("load() within the sticky window = %+v, %v; want alice read from the primary", got, err)
+	}
+
+	if _, err = model.load(ctx, bob.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("load() of a user not written to error = %v; want sql.ErrNoRows from the replica", err)
+	}
+
+	tx := &users{db: traceQueries(primary, dbSystemSQLite), replication: replication, inTx: true}
+
+	if _, err = tx.load(ctx, bob.ID); err != nil {
+		t.Errorf("load() in a transaction error = %v; want bob read from the primary", err)
+	}
+
+	replicas.conn = nil
+
+	if _, err = model.load(ctx, bob.ID); err != nil {
+		t.Errorf("load() without a healthy replica error = %v; want bob read from the primary", err)
+	}
+}
+
+func TestReplicationStickyWindow(t *testing.T) {
+	replication := NewReplication(&fakeReplicas{}, 20*time.Millisecond)
+
+	replication.wrote(1)
+
+	if !replication.sticky(2, 1) || replication.sticky(2) {
+		t.Error("sticky() right after a write of user 1 = false; want true for user 1 only")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if replication.sticky(1) {
+		t.Error("sticky() after the window = true; want false")
+	}
+
+	var none *Replication
+
+	none.wrote(1)
+
+	if none.sticky(1) || none.replica() != nil {
+		t.Error("nil Replication is sticky or has a replica; want every read sent to the primary")
+	}
+}
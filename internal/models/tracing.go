@@ -0,0 +1,102 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/maknahar/alpha-flow/internal/logging"
+	"github.com/maknahar/alpha-flow/internal/tracing"
+)
+
+// querier runs SQL statements. It is implemented by *sql.DB and tracedQuerier.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// tracedQuerier records a client span and a sampled debug log for every statement run through q, a warning for those
+// failing. The statement is recorded without its arguments.
+type tracedQuerier struct {
+	q      querier
+	system string
+}
+
+// The database systems recorded on the spans.
+const (
+	dbSystemPostgres = "postgresql"
+	dbSystemSQLite   = "sqlite"
+)
+
+func traceQueries(q querier, system string) querier {
+	return tracedQuerier{q: q, system: system}
+}
+
+func (t tracedQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result,
+	err error) {
+	ctx, span := startQuery(ctx, t.system, query)
+	defer endQuery(ctx, span, query, time.Now(), &err)
+
+	return t.q.ExecContext(ctx, query, args...)
+}
+
+func (t tracedQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows,
+	err error) {
+	ctx, span := startQuery(ctx, t.system, query)
+	defer endQuery(ctx, span, query, time.Now(), &err)
+
+	return t.q.QueryContext(ctx, query, args...)
+}
+
+func (t tracedQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuery(ctx, t.system, query)
+	start := time.Now()
+
+	row := t.q.QueryRowContext(ctx, query, args...)
+
+	err := row.Err()
+	endQuery(ctx, span, query, start, &err)
+
+	return row
+}
+
+// startQuery starts a span named after the operation of query, e.g. "SQL SELECT", run on the database system.
+func startQuery(ctx context.Context, system, query string) (context.Context, trace.Span) {
+	operation := "QUERY"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+
+	return tracing.Start(ctx, "SQL "+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemKey.String(system),
+		semconv.DBOperationKey.String(operation),
+		semconv.DBStatementKey.String(query),
+	))
+}
+
+// endQuery ends the span of query with the error *err points to, if any, and logs query. Failed queries are always
+// logged, as warnings; the others are sampled at debug level.
+func endQuery(ctx context.Context, span trace.Span, query string, start time.Time, err *error) {
+	tracing.End(span, *err)
+
+	if *err != nil {
+		logging.FromContext(ctx).WithError(*err).WithField("statement", query).
+			WithField("duration", time.Since(start).String()).Warn("Query failed")
+
+		return
+	}
+
+	entry := logging.FromContext(ctx)
+	if !entry.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+
+	logging.Sampled(ctx, "sql "+query).WithField("statement", query).
+		WithField("duration", time.Since(start).String()).Debug("Ran query")
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/maknahar/alpha-flow/internal/logging"
+)
+
+func TestTracedQuerierLogs(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   logrus.Level
+		query   string
+		wantLog string
+	}{
+		{name: "failure", level: logrus.InfoLevel, query: "SELECT * from missing", wantLog: `"level":"warning"`},
+		{name: "success", level: logrus.InfoLevel, query: "SELECT 1"},
+		{name: "success at debug level", level: logrus.DebugLevel, query: "SELECT 1", wantLog: `"level":"debug"`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			logger := logging.New(tt.level)
+			logger.Out = &out
+
+			ctx := logging.NewContext(context.Background(), logger, nil, nil)
+			q := traceQueries(openTestSQLite(t, "tracing.db"), dbSystemSQLite)
+
+			rows, err := q.QueryContext(ctx, tt.query)
+			if err == nil {
+				rows.Close()
+			}
+
+			if got := out.String(); tt.wantLog == "" && got != "" || !strings.Contains(got, tt.wantLog) ||
+				tt.wantLog != "" && !strings.Contains(got, tt.query) {
+				t.Errorf("QueryContext(%q) logged %q; want %s", tt.query, got, tt.wantLog)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// defaultTxMaxAttempts is how many times a unit of work is run at most when TxOptions do not tell.
+	defaultTxMaxAttempts = 3
+
+	// txRetryBackoff is the wait before the first retry of a unit of work, doubled for every other one.
+	txRetryBackoff = 10 * time.Millisecond
+)
+
+// Codes of the errors a transaction may succeed after if run again.
+const (
+	pqSerializationFailure = "40001"
+	pqDeadlockDetected     = "40P01"
+	sqliteBusy             = 5
+	sqliteLocked           = 6
+)
+
+// TxOptions configure a unit of work.
+type TxOptions struct {
+	// Isolation is the isolation level of the transaction, the default of the database if zero. SQLite transactions
+	// are always serializable.
+	Isolation sql.IsolationLevel
+
+	// ReadOnly rejects the writes of the transaction.
+	ReadOnly bool
+
+	// MaxAttempts is how many times the unit of work is run at most on serialization failures. Default: 3
+	MaxAttempts int
+}
+
+func (o TxOptions) maxAttempts() int {
+	if o.MaxAttempts <= 0 {
+		return defaultTxMaxAttempts
+	}
+
+	return o.MaxAttempts
+}
+
+// Tx are the models of a unit of work, running their statements in its transaction.
+type Tx struct {
+	Users UserModel
+}
+
+// UnitOfWork runs several model operations atomically.
+type UnitOfWork interface {
+	// Do runs fn in a transaction, committed if fn returns nil and rolled back otherwise, and returns the error of fn
+	// as is. On serialization failures and deadlocks fn is run again in a new transaction, up to opts.MaxAttempts
+	// times, so fn must not have effects outside of tx. Only the models of tx may be used by fn. Cancelling ctx
+	// rolls back the transaction and stops the retries.
+	Do(ctx context.Context, opts TxOptions, fn func(ctx context.Context, tx Tx) error) error
+}
+
+// sqlUnitOfWork runs units of work in transactions of conn.
+type sqlUnitOfWork struct {
+	conn   *sql.DB
+	system string
+	models func(q querier) Tx
+}
+
+func (w sqlUnitOfWork) Do(ctx context.Context, opts TxOptions, fn func(ctx context.Context, tx Tx) error) error {
+	return retryTx(ctx, opts.maxAttempts(), func() error {
+		return w.attempt(ctx, opts, fn)
+	})
+}
+
+func (w sqlUnitOfWork) attempt(ctx context.Context, opts TxOptions, fn func(ctx context.Context, tx Tx) error) error {
+	tx, err := w.conn.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	if err = fn(ctx, w.models(traceQueries(tx, w.system))); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// retryTx runs attempt until it succeeds, fails with an error other than a serialization failure, has been run
+// maxAttempts times or ctx is done. The retries are spread with an exponential backoff and jitter.
+func retryTx(ctx context.Context, maxAttempts int, attempt func() error) error {
+	for i := 0; ; i++ {
+		err := attempt()
+		if err == nil || i+1 >= maxAttempts || !isSerializationFailure(err) {
+			return err
+		}
+
+		backoff := txRetryBackoff << i
+		backoff += time.Duration(rand.Int63n(int64(backoff))) //nolint:gosec
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// isSerializationFailure tells whether err is a serialization failure or a deadlock, which running the transaction
+// again may succeed after.
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == pqSerializationFailure || pqErr.Code == pqDeadlockDetected
+	}
+
+	// The SQLite errors carry their result code, extended codes keeping the primary one in their lowest byte.
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code() & 0xff
+		return code == sqliteBusy || code == sqliteLocked
+	}
+
+	return false
+}
+
+// NewUnitOfWork returns the units of work of the models stored in Postgres. Their writes are recorded by replication,
+// if not nil, so that the reads following them are sent to the primary.
+func NewUnitOfWork(db *sql.DB, replication *Replication) UnitOfWork {
+	return sqlUnitOfWork{conn: db, system: dbSystemPostgres, models: func(q querier) Tx {
+		return Tx{Users: &users{db: q, replication: replication, inTx: true}}
+	}}
+}
+
+// NewSQLiteUnitOfWork returns the units of work of the models stored in SQLite. As the pool of db.OpenSQLite holds a
+// single connection, units of work run one at a time.
+func NewSQLiteUnitOfWork(db *sql.DB) UnitOfWork {
+	return sqlUnitOfWork{conn: db, system: dbSystemSQLite, models: func(q querier) Tx {
+		return Tx{Users: &sqliteUsers{db: q}}
+	}}
+}
+
+// memoryUnitOfWork runs units of work on a copy of the users, which replaces them once fn succeeds. Units of work
+// and the other operations on the users run one at a time.
+type memoryUnitOfWork struct {
+	users *memoryUsers
+}
+
+func (w memoryUnitOfWork) Do(ctx context.Context, _ TxOptions, fn func(ctx context.Context, tx Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.users.mu.Lock()
+	defer w.users.mu.Unlock()
+
+	tx := w.users.clone()
+
+	if err := fn(ctx, Tx{Users: tx}); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.users.nextID, w.users.users, w.users.byEmail, w.users.subscriptions = tx.nextID, tx.users, tx.byEmail,
+		tx.subscriptions
+
+	return nil
+}
+
+// NewMemoryUnitOfWork returns the units of work of users, which have to be returned by NewMemoryUser.
+func NewMemoryUnitOfWork(users UserModel) (UnitOfWork, error) {
+	memory, ok := users.(*memoryUsers)
+	if !ok {
+		return nil, fmt.Errorf("memory units of work of %T; want the users of NewMemoryUser", users)
+	}
+
+	return memoryUnitOfWork{users: memory}, nil
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// sqliteError is an error carrying a SQLite result code, as the errors of the SQLite driver do.
+type sqliteError int
+
+func (e sqliteError) Error() string { return fmt.Sprintf("sqlite error %d", int(e)) }
+
+func (e sqliteError) Code() int { return int(e) }
+
+func TestIsSerializationFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"serialization failure", &pq.Error{Code: pqSerializationFailure}, true},
+		{"deadlock", fmt.Errorf("wrapped: %w", &pq.Error{Code: pqDeadlockDetected}), true},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"sqlite busy", sqliteError(sqliteBusy), true},
+		{"sqlite locked shared cache", sqliteError(sqliteLocked | 1<<8), true},
+		{"sqlite constraint", sqliteError(19), false},
+		{"other", errors.New("other"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSerializationFailure(tt.err); got != tt.want {
+				t.Errorf("isSerializationFailure(%v) = %t; want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryTx(t *testing.T) {
+	serialization := &pq.Error{Code: pqSerializationFailure}
+	other := errors.New("other")
+
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{"success", 3, []error{nil}, 1, nil},
+		{"retried until success", 3, []error{serialization, serialization, nil}, 3, nil},
+		{"attempts exhausted", 2, []error{serialization, serialization, nil}, 2, serialization},
+		{"other error not retried", 3, []error{other, nil}, 1, other},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+
+			err := retryTx(context.Background(), tt.maxAttempts, func() error {
+				attempts++
+				return tt.errs[attempts-1]
+			})
+
+			if !errors.Is(err, tt.wantErr) || attempts != tt.wantAttempts {
+				t.Errorf("retryTx() = %v after %d attempts; want %v after %d", err, attempts, tt.wantErr,
+					tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTxStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+
+	err := retryTx(ctx, 10, func() error {
+		attempts++
+		cancel()
+
+		return &pq.Error{Code: pqSerializationFailure}
+	})
+
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Errorf("retryTx() = %v after %d attempts; want context.Canceled after 1", err, attempts)
+	}
+}
+
+func TestNewMemoryUnitOfWork(t *testing.T) {
+	if uow, err := NewMemoryUnitOfWork(NewMemoryUser()); err != nil || uow == nil {
+		t.Errorf("NewMemoryUnitOfWork() = %v, %v; want the units of work of the users", uow, err)
+	}
+
+	if uow, err := NewMemoryUnitOfWork(NewSQLiteUser(nil)); err == nil {
+		t.Errorf("NewMemoryUnitOfWork() of SQLite users = %v; want an error", uow)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"crypto/md5" //nolint:gosec
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // UserDetails represents all user info. Secret is populated if accessToken is given.
@@ -21,33 +23,84 @@ type UserDetails struct {
 	UpdatedAt         sql.NullTime
 }
 
-//go:generate mockgen -destination=userStoreMock.go -package=models . UserStore
+// Subscription is a pair a user subscribed to.
+type Subscription struct {
+	UserID    int64
+	Pair      string
+	CreatedAt time.Time
+}
+
+//go:generate go run github.com/golang/mock/mockgen -destination=mocks/users.go -package=mocks . UserModel
 type UserModel interface {
-	Create(ctx context.Context, email, Password string) (*UserDetails, error)
+	// Create stores a new user with an already hashed password.
+	Create(ctx context.Context, email, passwordHash string) (*UserDetails, error)
+
+	// PasswordHash returns the id and the stored password hash of the user with given email.
+	PasswordHash(ctx context.Context, email string) (int64, string, error)
 
-	Login(ctx context.Context, email, Password string) (*UserDetails, error)
+	// SetPasswordHash replaces the stored password hash of the user, e.g. when upgrading the hashing algorithm.
+	SetPasswordHash(ctx context.Context, id int64, passwordHash string) error
+
+	// PasswordHistory returns up to n most recent password hashes of the user including the current one.
+	PasswordHistory(ctx context.Context, id int64, n int) ([]string, error)
+
+	// IssueToken generates a new access token for the user.
+	IssueToken(ctx context.Context, id int64) (*UserDetails, error)
 
 	ByEmail(ctx context.Context, email string) (*UserDetails, error)
 
+	// ByIDs returns the users with given ids in a single query. Unknown ids are skipped.
+	ByIDs(ctx context.Context, ids []int64) ([]*UserDetails, error)
+
 	GetDetails(ctx context.Context, accessToken string) (*UserDetails, error)
 
-	CreateSubscription(ctx context.Context, userID int64, pair string) error
+	// CreateSubscription subscribes the user to pair, reporting whether the subscription was created. Subscribing
+	// again to the same pair is a no-op.
+	CreateSubscription(ctx context.Context, userID int64, pair string) (created bool, err error)
 
-	ChangeCredentials(ctx context.Context, emailID, password, accessToken string, id int64) (*UserDetails, error)
+	// Subscriptions returns the subscriptions of all given users in a single query, oldest first.
+	Subscriptions(ctx context.Context, userIDs []int64) ([]Subscription, error)
+
+	ChangeCredentials(ctx context.Context, emailID, passwordHash, accessToken string, id int64) (*UserDetails, error)
 }
 
+// userColumns selects the details of users.
+const userColumns = "SELECT id, email, secret, token, token_creation_time, created_at, updated_at from users"
+
 type users struct {
 	UserDetails
-	db *sql.DB
+	db querier
+
+	// replication routes the reads outside of transactions to the read replicas, if any.
+	replication *Replication
+	inTx        bool
 }
 
-func (u users) load(ctx context.Context, id int64) (*UserDetails, error) {
-	user := UserDetails{ID: id}
+// replica returns a replica to read from, nil if the reads go to u.db as they are in a transaction or no replica is
+// healthy.
+func (u users) replica() querier {
+	if u.inTx {
+		return nil
+	}
+
+	return u.replication.replica()
+}
+
+// reader returns where the reads of the users with given ids go: a replica unless one of them was written to within
+// the sticky window, u.db otherwise.
+func (u users) reader(ids ...int64) querier {
+	if replica := u.replica(); replica != nil && !u.replication.sticky(ids...) {
+		return replica
+	}
 
-	query := "SELECT email, secret, token, token_creation_time, created_at, updated_at from users where id=$1"
+	return u.db
+}
+
+func scanUser(row *sql.Row) (*UserDetails, error) {
+	var user UserDetails
 
-	err := u.db.QueryRowContext(ctx, query, id).Scan(&user.Email, &user.Secret, &user.Token, &user.TokenCreationTime,
-		&user.CreatedAt, &user.UpdatedAt)
+	err := row.Scan(&user.ID, &user.Email, &user.Secret, &user.Token, &user.TokenCreationTime, &user.CreatedAt,
+		&user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -55,72 +108,165 @@ func (u users) load(ctx context.Context, id int64) (*UserDetails, error) {
 	return &user, nil
 }
 
+func (u users) load(ctx context.Context, id int64) (*UserDetails, error) {
+	return scanUser(u.reader(id).QueryRowContext(ctx, userColumns+" where id=$1", id))
+}
+
+// loadWhere loads the user matching the condition where on its single argument arg. A user missing from the replica,
+// e.g. as it was just created, or written to within the sticky window is read again from the primary.
+func (u users) loadWhere(ctx context.Context, where string, arg interface{}) (*UserDetails, error) {
+	query := userColumns + " where " + where
+
+	if replica := u.replica(); replica != nil {
+		user, err := scanUser(replica.QueryRowContext(ctx, query, arg))
+		if (err == nil && !u.replication.sticky(user.ID)) || (err != nil && !errors.Is(err, sql.ErrNoRows)) {
+			return user, err
+		}
+	}
+
+	return scanUser(u.db.QueryRowContext(ctx, query, arg))
+}
+
 func (u users) ByEmail(ctx context.Context, email string) (*UserDetails, error) {
-	var id int64
+	return u.loadWhere(ctx, "email=$1", email)
+}
 
-	query := "SELECT id from users where email=$1"
+func (u users) ByIDs(ctx context.Context, ids []int64) ([]*UserDetails, error) {
+	query := userColumns + " where id = ANY($1)"
 
-	err := u.db.QueryRowContext(ctx, query, email).Scan(&id)
+	rows, err := u.reader(ids...).QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return u.load(ctx, id)
+	var details []*UserDetails
+
+	for rows.Next() {
+		var user UserDetails
+
+		err = rows.Scan(&user.ID, &user.Email, &user.Secret, &user.Token, &user.TokenCreationTime, &user.CreatedAt,
+			&user.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		details = append(details, &user)
+	}
+
+	return details, rows.Err()
 }
 
-func (u users) Create(ctx context.Context, email, password string) (*UserDetails, error) {
+func (u users) Create(ctx context.Context, email, passwordHash string) (*UserDetails, error) {
 	var id int64
 
-	query := `INSERT INTO users(email, password) VALUES ($1, crypt($2, gen_salt('bf'))) ON CONFLICT("email") DO UPDATE SET email=EXCLUDED.email RETURNING id`
+	query := `INSERT INTO users(email, password) VALUES ($1, $2) ON CONFLICT("email") DO UPDATE SET email=EXCLUDED.email RETURNING id`
 
-	err := u.db.QueryRowContext(ctx, query, email, password).Scan(&id)
+	err := u.db.QueryRowContext(ctx, query, email, passwordHash).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
 
+	u.replication.wrote(id)
+
 	return u.load(ctx, id)
 }
 
-func (u users) Login(ctx context.Context, email, password string) (*UserDetails, error) {
-	var id int64
+func (u users) PasswordHash(ctx context.Context, email string) (int64, string, error) {
+	var (
+		id           int64
+		passwordHash string
+	)
 
-	query := "SELECT id from users where email=$1 and password=crypt($2, password)"
+	query := "SELECT id, password from users where email=$1"
 
-	err := u.db.QueryRowContext(ctx, query, email, password).Scan(&id)
+	// As in loadWhere, a user missing from the replica or written to within the sticky window is read again.
+	if replica := u.replica(); replica != nil {
+		err := replica.QueryRowContext(ctx, query, email).Scan(&id, &passwordHash)
+		if (err == nil && !u.replication.sticky(id)) || (err != nil && !errors.Is(err, sql.ErrNoRows)) {
+			return id, passwordHash, err
+		}
+	}
+
+	err := u.db.QueryRowContext(ctx, query, email).Scan(&id, &passwordHash)
 	if err != nil {
-		return nil, err
+		return 0, "", err
 	}
 
-	accessToken := fmt.Sprintf("%x", md5.Sum([]byte(uuid.New().String()))) //nolint:gosec
-	query = "UPDATE users set token=$1, token_creation_time=$2 where id=$3"
+	return id, passwordHash, nil
+}
+
+func (u users) SetPasswordHash(ctx context.Context, id int64, passwordHash string) error {
+	u.replication.wrote(id)
+
+	query := "UPDATE users set password=$1 where id=$2"
 
-	res, err := u.db.ExecContext(ctx, query, accessToken, time.Now().UTC(), id)
+	res, err := u.db.ExecContext(ctx, query, passwordHash, id)
 	if err != nil {
-		fmt.Println(query, accessToken, time.Now().UTC(), id)
-		return nil, err
+		return err
 	}
 
 	if n, err := res.RowsAffected(); n != 1 || err != nil {
-		return nil, fmt.Errorf("error in generating login credentilas. %w: %d", err, n)
+		return fmt.Errorf("error in updating password hash. %w: %d", err, n)
 	}
 
-	return u.load(ctx, id)
+	return nil
 }
 
-func (u users) GetDetails(ctx context.Context, accessToken string) (*UserDetails, error) {
-	var id int64
+func (u users) PasswordHistory(ctx context.Context, id int64, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
 
-	query := "SELECT id from users where token=$1"
+	query := `SELECT password from users where id=$1
+		UNION ALL
+		(SELECT password from password_history where user_id=$1 ORDER BY id DESC LIMIT $2)`
 
-	err := u.db.QueryRowContext(ctx, query, accessToken).Scan(&id)
+	rows, err := u.reader(id).QueryContext(ctx, query, id, n-1)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	var hashes []string
+
+	for rows.Next() {
+		var hash string
+
+		if err = rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+func (u users) IssueToken(ctx context.Context, id int64) (*UserDetails, error) {
+	u.replication.wrote(id)
+
+	query := "UPDATE users set token=$1, token_creation_time=$2 where id=$3"
+
+	res, err := u.db.ExecContext(ctx, query, newAccessToken(), time.Now().UTC(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); n != 1 || err != nil {
+		return nil, fmt.Errorf("error in generating login credentilas. %w: %d", err, n)
+	}
 
 	return u.load(ctx, id)
 }
 
-func (u users) ChangeCredentials(ctx context.Context, email, password, accessToken string, id int64) (*UserDetails, error) {
+// GetDetails reads the user of accessToken from a replica, as loadWhere does. A token replaced by a login on another
+// instance is still found on replicas until they replay the login, which they are at most the maximum lag behind.
+func (u users) GetDetails(ctx context.Context, accessToken string) (*UserDetails, error) {
+	return u.loadWhere(ctx, "token=$1", accessToken)
+}
+
+func (u users) ChangeCredentials(ctx context.Context, email, passwordHash, accessToken string, id int64) (*UserDetails, error) {
 	query := "SELECT id from users where token=$1 and id=$2"
 
 	err := u.db.QueryRowContext(ctx, query, accessToken, id).Scan(&id)
@@ -128,25 +274,37 @@ func (u users) ChangeCredentials(ctx context.Context, email, password, accessTok
 		return nil, err
 	}
 
-	if email == "" && password == "" {
+	if email == "" && passwordHash == "" {
 		return u.load(ctx, id)
 	}
 
-	var qa queryArgs
+	u.replication.wrote(id)
+
+	if passwordHash != "" {
+		query = "INSERT INTO password_history(user_id, password) SELECT id, password from users where id=$1"
 
-	query = "UPDATE users set "
+		_, err = u.db.ExecContext(ctx, query, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	update := newUpdate(dialectPostgres, "users")
 
 	if email != "" {
-		query += " email=" + qa.Append(email)
+		update.Set("email", email)
 	}
 
-	if password != "" {
-		query += " password= crypt( " + qa.Append(password) + " ,gen_salt('bf'))"
+	if passwordHash != "" {
+		update.Set("password", passwordHash)
 	}
 
-	query += " where id = " + qa.Append(id)
+	query, args, err := update.Where(eq("id", id)).SQL()
+	if err != nil {
+		return nil, err
+	}
 
-	res, err := u.db.ExecContext(ctx, query, qa...)
+	res, err := u.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -158,17 +316,51 @@ func (u users) ChangeCredentials(ctx context.Context, email, password, accessTok
 	return u.load(ctx, id)
 }
 
-func (u users) CreateSubscription(ctx context.Context, userID int64, pair string) error {
+func (u users) CreateSubscription(ctx context.Context, userID int64, pair string) (bool, error) {
+	u.replication.wrote(userID)
+
 	query := `INSERT INTO subscriptions(user_id, pair) VALUES ($1, $2) ON CONFLICT(user_id, pair) DO NOTHING`
 
-	_, err := u.db.ExecContext(ctx, query, userID, pair)
+	res, err := u.db.ExecContext(ctx, query, userID, pair)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+func (u users) Subscriptions(ctx context.Context, userIDs []int64) ([]Subscription, error) {
+	query := "SELECT user_id, pair, created_at from subscriptions where user_id = ANY($1) ORDER BY created_at, pair"
+
+	rows, err := u.reader(userIDs...).QueryContext(ctx, query, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []Subscription
+
+	for rows.Next() {
+		var s Subscription
+
+		if err = rows.Scan(&s.UserID, &s.Pair, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, s)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// newAccessToken generates a random access token.
+func newAccessToken() string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(uuid.New().String()))) //nolint:gosec
 }
 
-func NewUser(db *sql.DB) UserModel {
-	return &users{db: db}
+// NewUser returns the users stored in Postgres, read from the replicas of replication if not nil.
+func NewUser(db *sql.DB, replication *Replication) UserModel {
+	return &users{db: traceQueries(db, dbSystemPostgres), replication: replication}
 }
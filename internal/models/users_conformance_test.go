@@ -0,0 +1,422 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// userBackends are the implementations of UserModel and UnitOfWork the conformance suite runs against. Each returns
+// an empty store. Postgres is only run when TEST_DATABASE_URL is set.
+//
+//nolint:gochecknoglobals
+var userBackends = []struct {
+	name string
+	open func(t *testing.T) (UserModel, UnitOfWork)
+}{
+	{"memory", func(t *testing.T) (UserModel, UnitOfWork) {
+		users := NewMemoryUser()
+
+		uow, err := NewMemoryUnitOfWork(users)
+		if err != nil {
+			t.Fatalf("NewMemoryUnitOfWork() error = %v", err)
+		}
+
+		return users, uow
+	}},
+	{"sqlite", func(t *testing.T) (UserModel, UnitOfWork) {
+		conn := openTestSQLite(t, "users.db")
+
+		return NewSQLiteUser(conn), NewSQLiteUnitOfWork(conn)
+	}},
+	{"postgres", func(t *testing.T) (UserModel, UnitOfWork) {
+		conn := openTestPostgres(t, "users, subscriptions, password_history")
+
+		return NewUser(conn, nil), NewUnitOfWork(conn, nil)
+	}},
+}
+
+// openTestPostgres returns the throwaway database of testPostgres with tables emptied, if any, closed at the end of t.
+// t is skipped if TEST_DATABASE_URL is not set, or fails in CI or with INTEGRATION=1, where the Postgres suites must
+// run.
+func openTestPostgres(t *testing.T, tables string) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		if ci, _ := strconv.ParseBool(os.Getenv("CI")); ci || os.Getenv("INTEGRATION") == "1" {
+			t.Fatal("TEST_DATABASE_URL not set, required in CI or with INTEGRATION=1")
+		}
+
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	testPostgres.once.Do(func() {
+		testPostgres.url, testPostgres.drop, testPostgres.err = createTestPostgres(dsn)
+	})
+
+	if testPostgres.err != nil {
+		t.Fatalf("createTestPostgres() error = %v", testPostgres.err)
+	}
+
+	conn, err := sql.Open("postgres", testPostgres.url)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	if tables == "" {
+		return conn
+	}
+
+	if _, err = conn.Exec("TRUNCATE " + tables + " RESTART IDENTITY CASCADE"); err != nil {
+		t.Fatalf("TRUNCATE error = %v", err)
+	}
+
+	return conn
+}
+
+func TestUserModelConformance(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, ctx context.Context, users UserModel, uow UnitOfWork)
+	}{
+		{"CreateIsCaseInsensitiveAndIdempotent", testCreate},
+		{"PasswordHash", testPasswordHash},
+		{"IssueToken", testIssueToken},
+		{"ChangeCredentials", testChangeCredentials},
+		{"ByIDs", testByIDs},
+		{"Subscriptions", testSubscriptions},
+		{"UnitOfWork", testUnitOfWork},
+	}
+
+	for _, backend := range userBackends {
+		backend := backend
+
+		t.Run(backend.name, func(t *testing.T) {
+			for _, tt := range tests {
+				tt := tt
+
+				t.Run(tt.name, func(t *testing.T) {
+					users, uow := backend.open(t)
+					tt.run(t, context.Background(), users, uow)
+				})
+			}
+		})
+	}
+}
+
+func mustCreate(t *testing.T, ctx context.Context, users UserModel, email, passwordHash string) *UserDetails {
+	t.Helper()
+
+	user, err := users.Create(ctx, email, passwordHash)
+	if err != nil {
+		t.Fatalf("Create(%q) error = %v", email, err)
+	}
+
+	return user
+}
+
+func testCreate(t *testing.T, ctx context.Context, users UserModel, _ UnitOfWork) {
+	created := mustCreate(t, ctx, users, "Alice@example.com", "hash")
+
+	if created.ID == 0 || created.Email != "Alice@example.com" || created.CreatedAt == nil {
+		t.Errorf("Create() = %+v; want an id, the email and a creation time", created)
+	}
+
+	if !created.Secret.Valid || created.Token.Valid {
+		t.Errorf("Create() secret = %v, token = %v; want the default secret and no token", created.Secret,
+			created.Token)
+	}
+
+	found, err := users.ByEmail(ctx, "alice@EXAMPLE.com")
+	if err != nil || found.ID != created.ID {
+		t.Errorf("ByEmail() = %+v, %v; want user %d", found, err, created.ID)
+	}
+
+	again := mustCreate(t, ctx, users, "ALICE@example.com", "other")
+	if again.ID != created.ID {
+		t.Errorf("Create() of an existing email = user %d; want user %d", again.ID, created.ID)
+	}
+
+	if _, hash, err := users.PasswordHash(ctx, "alice@example.com"); err != nil || hash != "hash" {
+		t.Errorf("PasswordHash() after Create() of an existing email = %q, %v; want the first hash", hash, err)
+	}
+
+	if _, err = users.ByEmail(ctx, "bob@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ByEmail() of an unknown email error = %v; want sql.ErrNoRows", err)
+	}
+}
+
+func testPasswordHash(t *testing.T, ctx context.Context, users UserModel, _ UnitOfWork) {
+	created := mustCreate(t, ctx, users, "alice@example.com", "hash")
+
+	id, hash, err := users.PasswordHash(ctx, "alice@example.com")
+	if err != nil || id != created.ID || hash != "hash" {
+		t.Errorf("PasswordHash() = %d, %q, %v; want %d, hash", id, hash, err, created.ID)
+	}
+
+	if _, _, err = users.PasswordHash(ctx, "bob@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("PasswordHash() of an unknown email error = %v; want sql.ErrNoRows", err)
+	}
+
+	if err = users.SetPasswordHash(ctx, created.ID, "upgraded"); err != nil {
+		t.Fatalf("SetPasswordHash() error = %v", err)
+	}
+
+	if _, hash, _ = users.PasswordHash(ctx, "alice@example.com"); hash != "upgraded" {
+		t.Errorf("PasswordHash() after SetPasswordHash() = %q; want upgraded", hash)
+	}
+
+	if err = users.SetPasswordHash(ctx, created.ID+1, "upgraded"); err == nil {
+		t.Error("SetPasswordHash() of an unknown user succeeded; want an error")
+	}
+}
+
+func testIssueToken(t *testing.T, ctx context.Context, users UserModel, _ UnitOfWork) {
+	created := mustCreate(t, ctx, users, "alice@example.com", "hash")
+
+	issued, err := users.IssueToken(ctx, created.ID)
+	if err != nil || !issued.Token.Valid || issued.Token.String == "" {
+		t.Fatalf("IssueToken() = %+v, %v; want a token", issued, err)
+	}
+
+	if !issued.UpdatedAt.Valid {
+		t.Error("IssueToken() did not set the update time")
+	}
+
+	found, err := users.GetDetails(ctx, issued.Token.String)
+	if err != nil || found.ID != created.ID {
+		t.Errorf("GetDetails() = %+v, %v; want user %d", found, err, created.ID)
+	}
+
+	reissued, err := users.IssueToken(ctx, created.ID)
+	if err != nil || reissued.Token == issued.Token {
+		t.Errorf("IssueToken() again = %+v, %v; want a new token", reissued, err)
+	}
+
+	if _, err = users.GetDetails(ctx, issued.Token.String); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetDetails() of a replaced token error = %v; want sql.ErrNoRows", err)
+	}
+
+	if _, err = users.IssueToken(ctx, created.ID+1); err == nil {
+		t.Error("IssueToken() of an unknown user succeeded; want an error")
+	}
+}
+
+func testChangeCredentials(t *testing.T, ctx context.Context, users UserModel, _ UnitOfWork) {
+	created := mustCreate(t, ctx, users, "alice@example.com", "first")
+	mustCreate(t, ctx, users, "bob@example.com", "hash")
+
+	issued, err := users.IssueToken(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+
+	token := issued.Token.String
+
+	if _, err = users.ChangeCredentials(ctx, "", "second", "wrong", created.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ChangeCredentials() with a wrong token error = %v; want sql.ErrNoRows", err)
+	}
+
+	unchanged, err := users.ChangeCredentials(ctx, "", "", token, created.ID)
+	if err != nil || unchanged.Email != "alice@example.com" {
+		t.Errorf("ChangeCredentials() of nothing = %+v, %v; want the user unchanged", unchanged, err)
+	}
+
+	for _, hash := range []string{"second", "third"} {
+		if _, err = users.ChangeCredentials(ctx, "", hash, token, created.ID); err != nil {
+			t.Fatalf("ChangeCredentials() of the password error = %v", err)
+		}
+	}
+
+	history, err := users.PasswordHistory(ctx, created.ID, 2)
+	if err != nil || !reflect.DeepEqual(history, []string{"third", "second"}) {
+		t.Errorf("PasswordHistory(2) = %q, %v; want [third second]", history, err)
+	}
+
+	history, _ = users.PasswordHistory(ctx, created.ID, 5)
+	if !reflect.DeepEqual(history, []string{"third", "second", "first"}) {
+		t.Errorf("PasswordHistory(5) = %q; want [third second first]", history)
+	}
+
+	if history, _ = users.PasswordHistory(ctx, created.ID, 0); len(history) != 0 {
+		t.Errorf("PasswordHistory(0) = %q; want none", history)
+	}
+
+	changed, err := users.ChangeCredentials(ctx, "alice@example.org", "", token, created.ID)
+	if err != nil || changed.Email != "alice@example.org" {
+		t.Fatalf("ChangeCredentials() of the email = %+v, %v; want alice@example.org", changed, err)
+	}
+
+	if found, err := users.ByEmail(ctx, "alice@example.org"); err != nil || found.ID != created.ID {
+		t.Errorf("ByEmail() of the new email = %+v, %v; want user %d", found, err, created.ID)
+	}
+
+	if _, err = users.ByEmail(ctx, "alice@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ByEmail() of the old email error = %v; want sql.ErrNoRows", err)
+	}
+
+	if _, err = users.ChangeCredentials(ctx, "BOB@example.com", "", token, created.ID); err == nil {
+		t.Error("ChangeCredentials() to the email of another user succeeded; want an error")
+	}
+
+	both, err := users.ChangeCredentials(ctx, "alice@example.net", "fourth", token, created.ID)
+	if err != nil || both.Email != "alice@example.net" {
+		t.Fatalf("ChangeCredentials() of the email and password = %+v, %v; want alice@example.net", both, err)
+	}
+
+	if _, hash, err := users.PasswordHash(ctx, "alice@example.net"); err != nil || hash != "fourth" {
+		t.Errorf("PasswordHash() after changing both = %q, %v; want fourth", hash, err)
+	}
+}
+
+func testByIDs(t *testing.T, ctx context.Context, users UserModel, _ UnitOfWork) {
+	alice := mustCreate(t, ctx, users, "alice@example.com", "hash")
+	bob := mustCreate(t, ctx, users, "bob@example.com", "hash")
+
+	found, err := users.ByIDs(ctx, []int64{bob.ID, alice.ID, bob.ID + alice.ID + 1})
+	if err != nil {
+		t.Fatalf("ByIDs() error = %v", err)
+	}
+
+	var emails []string
+	for _, u := range found {
+		emails = append(emails, u.Email)
+	}
+
+	sort.Strings(emails)
+
+	if !reflect.DeepEqual(emails, []string{"alice@example.com", "bob@example.com"}) {
+		t.Errorf("ByIDs() emails = %q; want alice and bob, the unknown id skipped", emails)
+	}
+
+	if found, err = users.ByIDs(ctx, nil); err != nil || len(found) != 0 {
+		t.Errorf("ByIDs(nil) = %v, %v; want none", found, err)
+	}
+}
+
+func testSubscriptions(t *testing.T, ctx context.Context, users UserModel, _ UnitOfWork) {
+	alice := mustCreate(t, ctx, users, "alice@example.com", "hash")
+	bob := mustCreate(t, ctx, users, "bob@example.com", "hash")
+
+	for _, s := range []struct {
+		Subscription
+		wantCreated bool
+	}{
+		{Subscription: Subscription{UserID: alice.ID, Pair: "ETH_BTC"}, wantCreated: true},
+		{Subscription: Subscription{UserID: bob.ID, Pair: "LTC_BTC"}, wantCreated: true},
+		{Subscription: Subscription{UserID: alice.ID, Pair: "BTC_USD"}, wantCreated: true},
+		{Subscription: Subscription{UserID: alice.ID, Pair: "ETH_BTC"}, wantCreated: false},
+	} {
+		created, err := users.CreateSubscription(ctx, s.UserID, s.Pair)
+		if err != nil {
+			t.Fatalf("CreateSubscription(%d, %s) error = %v", s.UserID, s.Pair, err)
+		}
+
+		if created != s.wantCreated {
+			t.Errorf("CreateSubscription(%d, %s) created = %v; want %v", s.UserID, s.Pair, created, s.wantCreated)
+		}
+	}
+
+	if _, err := users.CreateSubscription(ctx, alice.ID+bob.ID+1, "ETH_BTC"); err == nil {
+		t.Error("CreateSubscription() of an unknown user succeeded; want an error")
+	}
+
+	got, err := users.Subscriptions(ctx, []int64{alice.ID, bob.ID})
+	if err != nil {
+		t.Fatalf("Subscriptions() error = %v", err)
+	}
+
+	var pairs []string
+
+	for _, s := range got {
+		if s.CreatedAt.IsZero() {
+			t.Errorf("subscription %s has no creation time", s.Pair)
+		}
+
+		pairs = append(pairs, s.Pair)
+	}
+
+	if want := []string{"ETH_BTC", "LTC_BTC", "BTC_USD"}; !reflect.DeepEqual(pairs, want) {
+		t.Errorf("Subscriptions() pairs = %q; want %q, oldest first", pairs, want)
+	}
+
+	if got, _ = users.Subscriptions(ctx, []int64{bob.ID}); len(got) != 1 || got[0].UserID != bob.ID {
+		t.Errorf("Subscriptions() of bob = %+v; want LTC_BTC only", got)
+	}
+
+	if got, err = users.Subscriptions(ctx, nil); err != nil || len(got) != 0 {
+		t.Errorf("Subscriptions(nil) = %+v, %v; want none", got, err)
+	}
+}
+
+func testUnitOfWork(t *testing.T, ctx context.Context, users UserModel, uow UnitOfWork) {
+	opts := TxOptions{Isolation: sql.LevelSerializable}
+
+	var created *UserDetails
+
+	err := uow.Do(ctx, opts, func(ctx context.Context, tx Tx) error {
+		var err error
+
+		created, err = tx.Users.Create(ctx, "alice@example.com", "hash")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Users.IssueToken(ctx, created.ID)
+
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if found, err := users.ByEmail(ctx, "alice@example.com"); err != nil || !found.Token.Valid {
+		t.Errorf("ByEmail() after a committed unit of work = %+v, %v; want the user with a token", found, err)
+	}
+
+	failure := errors.New("failure")
+
+	err = uow.Do(ctx, opts, func(ctx context.Context, tx Tx) error {
+		if _, err := tx.Users.Create(ctx, "bob@example.com", "hash"); err != nil {
+			return err
+		}
+
+		if err := tx.Users.SetPasswordHash(ctx, created.ID, "changed"); err != nil {
+			return err
+		}
+
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("Do() error = %v; want the error of fn", err)
+	}
+
+	if _, err = users.ByEmail(ctx, "bob@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ByEmail() of a user created by a rolled back unit of work error = %v; want sql.ErrNoRows", err)
+	}
+
+	if _, hash, _ := users.PasswordHash(ctx, "alice@example.com"); hash != "hash" {
+		t.Errorf("PasswordHash() after a rolled back unit of work = %q; want hash", hash)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	ran := false
+
+	err = uow.Do(cancelled, opts, func(ctx context.Context, tx Tx) error {
+		ran = true
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || ran {
+		t.Errorf("Do() with a cancelled context = %v, ran %t; want context.Canceled without running fn", err, ran)
+	}
+}
@@ -0,0 +1,314 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSecret is the secret of new users, as set by the database default of the SQL backends.
+const defaultSecret = "All your base are belong to us"
+
+// memoryUser is a user of memoryUsers along with what is not part of its details.
+type memoryUser struct {
+	details  UserDetails
+	password string
+
+	// history are the previous password hashes, oldest first.
+	history []string
+}
+
+// memoryUsers keeps the users in memory, for unit tests and local runs. Nothing outlives the process. It behaves as
+// the SQL backends do, sql.ErrNoRows included.
+type memoryUsers struct {
+	mu            sync.RWMutex
+	nextID        int64
+	users         map[int64]*memoryUser
+	byEmail       map[string]int64
+	subscriptions []Subscription
+}
+
+// emailKey is how emails are compared, case insensitively as citext does.
+func emailKey(email string) string {
+	return strings.ToLower(email)
+}
+
+// details returns a copy of the details of the user with given id, which has to exist.
+func (u *memoryUsers) details(id int64) *UserDetails {
+	details := u.users[id].details
+	createdAt := *details.CreatedAt
+	details.CreatedAt = &createdAt
+
+	return &details
+}
+
+// clone returns a copy of the users, which the caller has locked, that can be changed without changing them.
+func (u *memoryUsers) clone() *memoryUsers {
+	c := &memoryUsers{
+		nextID:        u.nextID,
+		users:         make(map[int64]*memoryUser, len(u.users)),
+		byEmail:       make(map[string]int64, len(u.byEmail)),
+		subscriptions: append([]Subscription(nil), u.subscriptions...),
+	}
+
+	for id, user := range u.users {
+		copied := *user
+		copied.history = append([]string(nil), user.history...)
+		c.users[id] = &copied
+	}
+
+	for email, id := range u.byEmail {
+		c.byEmail[email] = id
+	}
+
+	return c
+}
+
+func (u *memoryUsers) byID(id int64) (*memoryUser, error) {
+	user, ok := u.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return user, nil
+}
+
+func (u *memoryUsers) ByEmail(_ context.Context, email string) (*UserDetails, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	id, ok := u.byEmail[emailKey(email)]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return u.details(id), nil
+}
+
+func (u *memoryUsers) ByIDs(_ context.Context, ids []int64) ([]*UserDetails, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	var details []*UserDetails
+
+	seen := make(map[int64]bool, len(ids))
+
+	for _, id := range ids {
+		if _, ok := u.users[id]; ok && !seen[id] {
+			seen[id] = true
+
+			details = append(details, u.details(id))
+		}
+	}
+
+	return details, nil
+}
+
+func (u *memoryUsers) Create(_ context.Context, email, passwordHash string) (*UserDetails, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	// As the SQL backends do, creating an existing user only updates the case of its email.
+	if id, ok := u.byEmail[emailKey(email)]; ok {
+		user := u.users[id]
+		user.details.Email = email
+		user.details.UpdatedAt = sql.NullTime{Time: now, Valid: true}
+
+		return u.details(id), nil
+	}
+
+	u.nextID++
+
+	user := &memoryUser{
+		details: UserDetails{
+			ID:                u.nextID,
+			Email:             email,
+			Secret:            sql.NullString{String: defaultSecret, Valid: true},
+			TokenCreationTime: now,
+			CreatedAt:         &now,
+		},
+		password: passwordHash,
+	}
+
+	u.users[user.details.ID] = user
+	u.byEmail[emailKey(email)] = user.details.ID
+
+	return u.details(user.details.ID), nil
+}
+
+func (u *memoryUsers) PasswordHash(_ context.Context, email string) (int64, string, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	id, ok := u.byEmail[emailKey(email)]
+	if !ok {
+		return 0, "", sql.ErrNoRows
+	}
+
+	return id, u.users[id].password, nil
+}
+
+func (u *memoryUsers) SetPasswordHash(_ context.Context, id int64, passwordHash string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, ok := u.users[id]
+	if !ok {
+		return fmt.Errorf("error in updating password hash. unknown user %d", id)
+	}
+
+	user.password = passwordHash
+	user.details.UpdatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	return nil
+}
+
+func (u *memoryUsers) PasswordHistory(_ context.Context, id int64, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	user, ok := u.users[id]
+	if !ok {
+		return nil, nil
+	}
+
+	hashes := []string{user.password}
+
+	for i := len(user.history) - 1; i >= 0 && len(hashes) < n; i-- {
+		hashes = append(hashes, user.history[i])
+	}
+
+	return hashes, nil
+}
+
+func (u *memoryUsers) IssueToken(_ context.Context, id int64) (*UserDetails, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, ok := u.users[id]
+	if !ok {
+		return nil, fmt.Errorf("error in generating login credentials. unknown user %d", id)
+	}
+
+	now := time.Now().UTC()
+	user.details.Token = sql.NullString{String: newAccessToken(), Valid: true}
+	user.details.TokenCreationTime = now
+	user.details.UpdatedAt = sql.NullTime{Time: now, Valid: true}
+
+	return u.details(id), nil
+}
+
+func (u *memoryUsers) GetDetails(_ context.Context, accessToken string) (*UserDetails, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	for id, user := range u.users {
+		if user.details.Token.Valid && user.details.Token.String == accessToken {
+			return u.details(id), nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (u *memoryUsers) ChangeCredentials(_ context.Context, email, passwordHash, accessToken string,
+	id int64) (*UserDetails, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, err := u.byID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.details.Token.Valid || user.details.Token.String != accessToken {
+		return nil, sql.ErrNoRows
+	}
+
+	if email == "" && passwordHash == "" {
+		return u.details(id), nil
+	}
+
+	if email != "" {
+		key := emailKey(email)
+		if other, ok := u.byEmail[key]; ok && other != id {
+			return nil, fmt.Errorf("email %s is already taken", email)
+		}
+
+		delete(u.byEmail, emailKey(user.details.Email))
+		u.byEmail[key] = id
+		user.details.Email = email
+	}
+
+	if passwordHash != "" {
+		user.history = append(user.history, user.password)
+		user.password = passwordHash
+	}
+
+	user.details.UpdatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	return u.details(id), nil
+}
+
+func (u *memoryUsers) CreateSubscription(_ context.Context, userID int64, pair string) (bool, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, err := u.byID(userID); err != nil {
+		return false, fmt.Errorf("unknown user %d", userID)
+	}
+
+	for _, s := range u.subscriptions {
+		if s.UserID == userID && s.Pair == pair {
+			return false, nil
+		}
+	}
+
+	u.subscriptions = append(u.subscriptions, Subscription{UserID: userID, Pair: pair, CreatedAt: time.Now().UTC()})
+
+	return true, nil
+}
+
+func (u *memoryUsers) Subscriptions(_ context.Context, userIDs []int64) ([]Subscription, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	wanted := make(map[int64]bool, len(userIDs))
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+
+	var subscriptions []Subscription
+
+	for _, s := range u.subscriptions {
+		if wanted[s.UserID] {
+			subscriptions = append(subscriptions, s)
+		}
+	}
+
+	sort.SliceStable(subscriptions, func(i, j int) bool {
+		a, b := subscriptions[i], subscriptions[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+
+		return a.Pair < b.Pair
+	})
+
+	return subscriptions, nil
+}
+
+// NewMemoryUser returns users kept in memory, starting with none.
+func NewMemoryUser() UserModel {
+	return &memoryUsers{users: make(map[int64]*memoryUser), byEmail: make(map[string]int64)}
+}
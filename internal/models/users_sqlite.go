@@ -0,0 +1,243 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sqliteUsers stores the users in SQLite, for single node deployments. Emails are compared case insensitively as
+// citext does, for ASCII letters only. The timestamps are set here rather than by triggers.
+type sqliteUsers struct {
+	db querier
+}
+
+func (u sqliteUsers) load(ctx context.Context, id int64) (*UserDetails, error) {
+	return users{db: u.db}.load(ctx, id)
+}
+
+func (u sqliteUsers) ByEmail(ctx context.Context, email string) (*UserDetails, error) {
+	return users{db: u.db}.ByEmail(ctx, email)
+}
+
+func (u sqliteUsers) ByIDs(ctx context.Context, ids []int64) ([]*UserDetails, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query, args := newSelect(dialectSQLite, userColumns).Where(inInt64("id", ids)).SQL()
+
+	rows, err := u.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var details []*UserDetails
+
+	for rows.Next() {
+		var user UserDetails
+
+		err = rows.Scan(&user.ID, &user.Email, &user.Secret, &user.Token, &user.TokenCreationTime, &user.CreatedAt,
+			&user.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		details = append(details, &user)
+	}
+
+	return details, rows.Err()
+}
+
+func (u sqliteUsers) Create(ctx context.Context, email, passwordHash string) (*UserDetails, error) {
+	var id int64
+
+	query := `INSERT INTO users(email, password, token_creation_time, created_at) VALUES ($1, $2, $3, $3)
+		ON CONFLICT(email) DO UPDATE SET email=excluded.email, updated_at=$3 RETURNING id`
+
+	err := u.db.QueryRowContext(ctx, query, email, passwordHash, time.Now().UTC()).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.load(ctx, id)
+}
+
+func (u sqliteUsers) PasswordHash(ctx context.Context, email string) (int64, string, error) {
+	return users{db: u.db}.PasswordHash(ctx, email)
+}
+
+func (u sqliteUsers) SetPasswordHash(ctx context.Context, id int64, passwordHash string) error {
+	query := "UPDATE users set password=$1, updated_at=$2 where id=$3"
+
+	res, err := u.db.ExecContext(ctx, query, passwordHash, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); n != 1 || err != nil {
+		return fmt.Errorf("error in updating password hash. %w: %d", err, n)
+	}
+
+	return nil
+}
+
+func (u sqliteUsers) PasswordHistory(ctx context.Context, id int64, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	// The order of the rows of a UNION ALL is not guaranteed by SQLite, so the current password is read first.
+	var current string
+
+	err := u.db.QueryRowContext(ctx, "SELECT password from users where id=$1", id).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT password from password_history where user_id=$1 ORDER BY id DESC LIMIT $2"
+
+	rows, err := u.db.QueryContext(ctx, query, id, n-1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := []string{current}
+
+	for rows.Next() {
+		var hash string
+
+		if err = rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+func (u sqliteUsers) IssueToken(ctx context.Context, id int64) (*UserDetails, error) {
+	now := time.Now().UTC()
+	query := "UPDATE users set token=$1, token_creation_time=$2, updated_at=$2 where id=$3"
+
+	res, err := u.db.ExecContext(ctx, query, newAccessToken(), now, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); n != 1 || err != nil {
+		return nil, fmt.Errorf("error in generating login credentilas. %w: %d", err, n)
+	}
+
+	return u.load(ctx, id)
+}
+
+func (u sqliteUsers) GetDetails(ctx context.Context, accessToken string) (*UserDetails, error) {
+	return users{db: u.db}.GetDetails(ctx, accessToken)
+}
+
+func (u sqliteUsers) ChangeCredentials(ctx context.Context, email, passwordHash, accessToken string, id int64) (*UserDetails, error) {
+	query := "SELECT id from users where token=$1 and id=$2"
+
+	err := u.db.QueryRowContext(ctx, query, accessToken, id).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	if email == "" && passwordHash == "" {
+		return u.load(ctx, id)
+	}
+
+	now := time.Now().UTC()
+
+	if passwordHash != "" {
+		query = `INSERT INTO password_history(user_id, password, created_at)
+			SELECT id, password, $2 from users where id=$1`
+
+		_, err = u.db.ExecContext(ctx, query, id, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	update := newUpdate(dialectSQLite, "users").Set("updated_at", now)
+
+	if email != "" {
+		update.Set("email", email)
+	}
+
+	if passwordHash != "" {
+		update.Set("password", passwordHash)
+	}
+
+	query, args, err := update.Where(eq("id", id)).SQL()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := u.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); n != 1 || err != nil {
+		return nil, fmt.Errorf("error in changing login credentilas. %w: %d", err, n)
+	}
+
+	return u.load(ctx, id)
+}
+
+func (u sqliteUsers) CreateSubscription(ctx context.Context, userID int64, pair string) (bool, error) {
+	query := `INSERT INTO subscriptions(user_id, pair, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT(user_id, pair) DO NOTHING`
+
+	res, err := u.db.ExecContext(ctx, query, userID, pair, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+func (u sqliteUsers) Subscriptions(ctx context.Context, userIDs []int64) ([]Subscription, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	query, args := newSelect(dialectSQLite, "SELECT user_id, pair, created_at from subscriptions").
+		Where(inInt64("user_id", userIDs)).OrderBy("created_at", false).OrderBy("pair", false).SQL()
+
+	rows, err := u.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []Subscription
+
+	for rows.Next() {
+		var s Subscription
+
+		if err = rows.Scan(&s.UserID, &s.Pair, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, s)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// NewSQLiteUser returns the users stored in SQLite, in a database opened by db.OpenSQLite.
+func NewSQLiteUser(db *sql.DB) UserModel {
+	return &sqliteUsers{db: traceQueries(db, dbSystemSQLite)}
+}
@@ -0,0 +1,131 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2ID = "argon2id"
+
+	DefaultArgon2Memory      = 64 * 1024
+	DefaultArgon2Iterations  = 3
+	DefaultArgon2Parallelism = 2
+
+	// MaxArgon2Memory bounds the memory in KiB of the hashes produced and verified: 4 GiB. The lower bound is 8 KiB a
+	// thread.
+	MaxArgon2Memory = 4 * 1024 * 1024
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Argon2Params are the tunable cost parameters of argon2id.
+type Argon2Params struct {
+	// Memory is the amount of memory used in KiB. Default: 65536 (64 MiB)
+	Memory uint32
+
+	// Iterations is the number of passes over the memory. Default: 3
+	Iterations uint32
+
+	// Parallelism is the number of threads used. Default: 2
+	Parallelism uint8
+}
+
+type argon2id struct {
+	params Argon2Params
+}
+
+// NewArgon2id returns a Hasher producing argon2id hashes. Zero params are replaced by defaults.
+func NewArgon2id(params Argon2Params) Hasher {
+	if params.Memory == 0 {
+		params.Memory = DefaultArgon2Memory
+	}
+
+	if params.Iterations == 0 {
+		params.Iterations = DefaultArgon2Iterations
+	}
+
+	if params.Parallelism == 0 {
+		params.Parallelism = DefaultArgon2Parallelism
+	}
+
+	return &argon2id{params: params}
+}
+
+func (a *argon2id) ID() []string {
+	return []string{argon2ID}
+}
+
+func (a *argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("%w; unable to generate salt", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.params.Iterations, a.params.Memory, a.params.Parallelism,
+		argon2KeyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2ID, argon2.Version, a.params.Memory,
+		a.params.Iterations, a.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (a *argon2id) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism,
+		uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (a *argon2id) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params != a.params || len(salt) != argon2SaltLength || len(key) != argon2KeyLength
+}
+
+// decodeArgon2 parses $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>, whose parameters have to be within the bounds of
+// argon2.
+func decodeArgon2(encoded string) (params Argon2Params, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != argon2ID {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	// The parameters of a corrupt or forged hash would make argon2 panic or allocate unbounded memory.
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations < 1 || params.Parallelism < 1 ||
+		params.Memory < 8*uint32(params.Parallelism) || params.Memory > MaxArgon2Memory {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,58 @@
+package passwords
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DefaultBcryptCost = 12
+)
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcrypt returns a Hasher producing bcrypt hashes in the modular crypt format ($2a$<cost>$<salt+hash>).
+// It also verifies hashes created by pgcrypto's crypt(password, gen_salt('bf')), which use the same format.
+// A cost outside bcrypt's valid range is replaced by DefaultBcryptCost.
+func NewBcrypt(cost int) Hasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = DefaultBcryptCost
+	}
+
+	return &bcryptHasher{cost: cost}
+}
+
+func (b *bcryptHasher) ID() []string {
+	return []string{"2a", "2b", "2y"}
+}
+
+func (b *bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func (b *bcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+
+		return false, ErrMalformedHash
+	}
+
+	return true, nil
+}
+
+func (b *bcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+
+	return err != nil || cost != b.cost
+}
@@ -0,0 +1,170 @@
+package passwords
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMalformedDataset = errors.New("breached password dataset: malformed line")
+)
+
+const (
+	rangePrefixLength = 5
+	sha1HexLength     = 40
+)
+
+// RangeSource provide the contract for a k-anonymity dataset of breached passwords. Passwords are identified by the
+// upper case hex SHA-1 of the password, and looked up by its first 5 characters only.
+type RangeSource interface {
+	// Range returns the remaining 35 characters of every breached hash starting with prefix, mapped to the number of
+	// times it was seen.
+	Range(prefix string) (map[string]int, error)
+}
+
+type rangeDir struct {
+	dir string
+}
+
+// NewRangeDir returns a RangeSource reading one file per prefix from dir, named <PREFIX> or <PREFIX>.txt, as produced
+// by downloading the Pwned Passwords range API. Each line is <SUFFIX>:<COUNT>. Files are read on every lookup.
+func NewRangeDir(dir string) RangeSource {
+	return &rangeDir{dir: dir}
+}
+
+func (d *rangeDir) Range(prefix string) (map[string]int, error) {
+	prefix = strings.ToUpper(prefix)
+
+	for _, name := range []string{prefix, prefix + ".txt"} {
+		f, err := os.Open(filepath.Join(d.dir, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		suffixes, err := parseRange(f)
+		f.Close()
+
+		return suffixes, err
+	}
+
+	return map[string]int{}, nil
+}
+
+type rangeSet map[string]map[string]int
+
+// LoadRanges reads a dataset of full hashes, one <SHA1>[:<COUNT>] per line, into memory and indexes it by prefix.
+func LoadRanges(r io.Reader) (RangeSource, error) {
+	set := make(rangeSet)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		hash, count, err := parseLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%w; line %d", err, line)
+		}
+
+		if hash == "" {
+			continue
+		}
+
+		if len(hash) != sha1HexLength {
+			return nil, fmt.Errorf("%w; line %d", ErrMalformedDataset, line)
+		}
+
+		prefix := hash[:rangePrefixLength]
+		if set[prefix] == nil {
+			set[prefix] = make(map[string]int)
+		}
+
+		set[prefix][hash[rangePrefixLength:]] += count
+	}
+
+	return set, scanner.Err()
+}
+
+// LoadRangesFile returns a RangeSource for path, which is either a directory of range files or a single file of full
+// hashes. See NewRangeDir and LoadRanges for the formats.
+func LoadRangesFile(path string) (RangeSource, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return NewRangeDir(path), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadRanges(f)
+}
+
+func (s rangeSet) Range(prefix string) (map[string]int, error) {
+	if suffixes, ok := s[strings.ToUpper(prefix)]; ok {
+		return suffixes, nil
+	}
+
+	return map[string]int{}, nil
+}
+
+func parseRange(r io.Reader) (map[string]int, error) {
+	suffixes := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		suffix, count, err := parseLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%w; line %d", err, line)
+		}
+
+		if suffix == "" {
+			continue
+		}
+
+		if len(suffix) != sha1HexLength-rangePrefixLength {
+			return nil, fmt.Errorf("%w; line %d", ErrMalformedDataset, line)
+		}
+
+		suffixes[suffix] += count
+	}
+
+	return suffixes, scanner.Err()
+}
+
+// parseLine parses <HEX>[:<COUNT>]. A missing count is counted as 1 and blank lines yield an empty hash.
+func parseLine(line string) (hash string, count int, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", 0, nil
+	}
+
+	parts := strings.SplitN(line, ":", 2)
+	count = 1
+
+	if len(parts) == 2 {
+		if count, err = strconv.Atoi(parts[1]); err != nil || count < 0 {
+			return "", 0, ErrMalformedDataset
+		}
+	}
+
+	hash = strings.ToUpper(parts[0])
+	if strings.Trim(hash, "0123456789ABCDEF") != "" {
+		return "", 0, ErrMalformedDataset
+	}
+
+	return hash, count, nil
+}
@@ -0,0 +1,155 @@
+package passwords
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	// passwordSHA1 is the SHA-1 of password.
+	passwordSHA1 = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+
+	// open1234SHA1 is the SHA-1 of open1234.
+	open1234SHA1 = "7CABDA84F9800B49A1F62ACEA2D3C271AB48E59D"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := ioutil.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadRanges(t *testing.T) {
+	source, err := LoadRanges(strings.NewReader(passwordSHA1 + ":3\n\n" + strings.ToLower(passwordSHA1) + "\n" +
+		open1234SHA1 + "\n"))
+	if err != nil {
+		t.Fatalf("LoadRanges() error = %v", err)
+	}
+
+	tests := []struct {
+		prefix string
+		want   map[string]int
+	}{
+		{prefix: "5BAA6", want: map[string]int{passwordSHA1[5:]: 4}},
+		{prefix: "5baa6", want: map[string]int{passwordSHA1[5:]: 4}},
+		{prefix: "7CABD", want: map[string]int{open1234SHA1[5:]: 1}},
+		{prefix: "00000", want: map[string]int{}},
+	}
+
+	for _, tt := range tests {
+		got, err := source.Range(tt.prefix)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Range(%q) = %v, %v; want %v", tt.prefix, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadRangesMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "short hash", data: passwordSHA1[:39]},
+		{name: "long hash", data: passwordSHA1 + "0"},
+		{name: "range line", data: passwordSHA1[5:] + ":3"},
+		{name: "not hex", data: "ZBAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"},
+		{name: "invalid count", data: passwordSHA1 + ":many"},
+		{name: "trailing garbage in count", data: passwordSHA1 + ":12abc"},
+		{name: "negative count", data: passwordSHA1 + ":-1"},
+		{name: "after valid lines", data: open1234SHA1 + "\n\n" + passwordSHA1 + ":"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadRanges(strings.NewReader(tt.data)); !errors.Is(err, ErrMalformedDataset) {
+				t.Errorf("LoadRanges(%q) error = %v; want ErrMalformedDataset", tt.data, err)
+			}
+		})
+	}
+}
+
+func TestRangeDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "5BAA6"), passwordSHA1[5:]+":3\r\n0000000000000000000000000000000000A:1\r\n")
+	writeFile(t, filepath.Join(dir, "7CABD.txt"), open1234SHA1[5:]+"\n")
+	writeFile(t, filepath.Join(dir, "00000"), passwordSHA1+":3\n")
+	writeFile(t, filepath.Join(dir, "11111"), passwordSHA1[5:]+":x\n")
+
+	source := NewRangeDir(dir)
+
+	tests := []struct {
+		name    string
+		prefix  string
+		want    map[string]int
+		wantErr bool
+	}{
+		{name: "range file", prefix: "5baa6",
+			want: map[string]int{passwordSHA1[5:]: 3, "0000000000000000000000000000000000A": 1}},
+		{name: "text range file", prefix: "7CABD", want: map[string]int{open1234SHA1[5:]: 1}},
+		{name: "missing range file", prefix: "22222", want: map[string]int{}},
+		{name: "full hash in range file", prefix: "00000", wantErr: true},
+		{name: "invalid count", prefix: "11111", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := source.Range(tt.prefix)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrMalformedDataset) {
+					t.Errorf("Range(%q) error = %v; want ErrMalformedDataset", tt.prefix, err)
+				}
+
+				return
+			}
+
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Range(%q) = %v, %v; want %v", tt.prefix, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRangesFile(t *testing.T) {
+	dir := t.TempDir()
+
+	ranges := filepath.Join(dir, "ranges")
+	if err := os.Mkdir(ranges, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(ranges, "5BAA6"), passwordSHA1[5:]+":3\n")
+	writeFile(t, filepath.Join(dir, "hashes.txt"), passwordSHA1+":3\n")
+	writeFile(t, filepath.Join(dir, "malformed.txt"), passwordSHA1[5:]+":3\n")
+
+	for _, path := range []string{ranges, filepath.Join(dir, "hashes.txt")} {
+		source, err := LoadRangesFile(path)
+		if err != nil {
+			t.Fatalf("LoadRangesFile(%q) error = %v", path, err)
+		}
+
+		want := map[string]int{passwordSHA1[5:]: 3}
+		if got, err := source.Range("5BAA6"); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("LoadRangesFile(%q).Range() = %v, %v; want %v", path, got, err, want)
+		}
+	}
+
+	if _, err := LoadRangesFile(filepath.Join(dir, "malformed.txt")); !errors.Is(err, ErrMalformedDataset) {
+		t.Errorf("LoadRangesFile(malformed) error = %v; want ErrMalformedDataset", err)
+	}
+
+	if _, err := LoadRangesFile(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadRangesFile(missing) error = %v; want os.ErrNotExist", err)
+	}
+}
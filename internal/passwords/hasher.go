@@ -0,0 +1,95 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("password hash: unknown algorithm")
+	ErrMalformedHash    = errors.New("password hash: malformed")
+)
+
+// Hasher provide the contract that needs to be adhered to by any password hashing algorithm used in this service.
+// Hashes are encoded as PHC strings: $<id>[$v=<version>][$<param>=<value>(,<param>=<value>)*][$<salt>[$<hash>]]
+type Hasher interface {
+	// ID returns the PHC identifiers of the hashes this hasher can verify. The first one is used for new hashes.
+	ID() []string
+
+	// Hash returns the encoded hash of password with a random salt.
+	Hash(password string) (string, error)
+
+	// Verify reports whether password matches the encoded hash.
+	Verify(password, encoded string) (bool, error)
+
+	// NeedsRehash reports whether encoded was not produced with the current algorithm and parameters.
+	NeedsRehash(encoded string) bool
+}
+
+// algorithm returns the PHC identifier of an encoded hash.
+func algorithm(encoded string) string {
+	parts := strings.SplitN(encoded, "$", 3)
+	if len(parts) < 3 || parts[0] != "" {
+		return ""
+	}
+
+	return parts[1]
+}
+
+type chain struct {
+	current Hasher
+	ids     []string
+	byID    map[string]Hasher
+}
+
+// NewHasher returns a Hasher that hashes with current and verifies hashes produced by current or any of legacy.
+// Every hash not produced by current with its present parameters is reported as needing a rehash.
+func NewHasher(current Hasher, legacy ...Hasher) Hasher {
+	c := &chain{current: current, byID: make(map[string]Hasher)}
+
+	for _, h := range append([]Hasher{current}, legacy...) {
+		for _, id := range h.ID() {
+			if _, ok := c.byID[id]; !ok {
+				c.byID[id] = h
+				c.ids = append(c.ids, id)
+			}
+		}
+	}
+
+	return c
+}
+
+func (c *chain) ID() []string {
+	return c.ids
+}
+
+func (c *chain) Hash(password string) (string, error) {
+	return c.current.Hash(password)
+}
+
+func (c *chain) Verify(password, encoded string) (bool, error) {
+	h, ok := c.byID[algorithm(encoded)]
+	if !ok {
+		return false, ErrUnknownAlgorithm
+	}
+
+	return h.Verify(password, encoded)
+}
+
+func (c *chain) NeedsRehash(encoded string) bool {
+	if !contains(c.current.ID(), algorithm(encoded)) {
+		return true
+	}
+
+	return c.current.NeedsRehash(encoded)
+}
+
+func contains(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
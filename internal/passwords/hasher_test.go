@@ -0,0 +1,239 @@
+package passwords
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cheapBcryptCost is the cheapest bcrypt cost, so that the tests stay fast.
+const cheapBcryptCost = 4
+
+// cheapArgon2 are the cheapest argon2id parameters.
+//
+//nolint:gochecknoglobals
+var cheapArgon2 = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func mustHash(t *testing.T, h Hasher, password string) string {
+	t.Helper()
+
+	hash, err := h.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	return hash
+}
+
+func TestAlgorithm(t *testing.T) {
+	tests := []struct {
+		encoded string
+		want    string
+	}{
+		{"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5", "argon2id"},
+		{"$2a$04$abcdefghijklmnopqrstuu", "2a"},
+		{"$argon2id", ""},
+		{"argon2id$v=19$m=64", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := algorithm(tt.encoded); got != tt.want {
+			t.Errorf("algorithm(%q) = %q; want %q", tt.encoded, got, tt.want)
+		}
+	}
+}
+
+func TestHashers(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher Hasher
+		prefix string
+	}{
+		{name: "argon2id", hasher: NewArgon2id(cheapArgon2), prefix: "$argon2id$v=19$m=64,t=1,p=1$"},
+		{name: "bcrypt", hasher: NewBcrypt(cheapBcryptCost), prefix: "$2a$04$"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			hash := mustHash(t, tt.hasher, "open1234")
+			if !strings.HasPrefix(hash, tt.prefix) {
+				t.Errorf("Hash() = %q; want prefix %q", hash, tt.prefix)
+			}
+
+			if other := mustHash(t, tt.hasher, "open1234"); other == hash {
+				t.Errorf("Hash() = %q twice; want a random salt", hash)
+			}
+
+			if ok, err := tt.hasher.Verify("open1234", hash); !ok || err != nil {
+				t.Errorf("Verify(password) = %v, %v; want true", ok, err)
+			}
+
+			if ok, err := tt.hasher.Verify("open12345", hash); ok || err != nil {
+				t.Errorf("Verify(other password) = %v, %v; want false", ok, err)
+			}
+
+			if tt.hasher.NeedsRehash(hash) {
+				t.Errorf("NeedsRehash(%q) = true; want false for a hash of the current parameters", hash)
+			}
+		})
+	}
+}
+
+func TestArgon2idDefaults(t *testing.T) {
+	got := NewArgon2id(Argon2Params{Iterations: 1}).(*argon2id).params
+	want := Argon2Params{Memory: DefaultArgon2Memory, Iterations: 1, Parallelism: DefaultArgon2Parallelism}
+
+	if got != want {
+		t.Errorf("NewArgon2id() params = %+v; want %+v", got, want)
+	}
+}
+
+func TestArgon2idMalformed(t *testing.T) {
+	hasher := NewArgon2id(cheapArgon2)
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "other algorithm", encoded: "$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5"},
+		{name: "missing key", encoded: "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA"},
+		{name: "other version", encoded: "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5"},
+		{name: "missing version", encoded: "$argon2id$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5$a2V5"},
+		{name: "invalid parameters", encoded: "$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5"},
+		{name: "no iterations", encoded: "$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5"},
+		{name: "no threads", encoded: "$argon2id$v=19$m=64,t=1,p=0$c2FsdHNhbHRzYWx0c2FsdA$a2V5"},
+		{name: "too many threads", encoded: "$argon2id$v=19$m=64,t=1,p=256$c2FsdHNhbHRzYWx0c2FsdA$a2V5"},
+		{name: "too little memory", encoded: "$argon2id$v=19$m=15,t=1,p=2$c2FsdHNhbHRzYWx0c2FsdA$a2V5"},
+		{name: "too much memory", encoded: "$argon2id$v=19$m=4194305,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5"},
+		{name: "invalid salt", encoded: "$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5"},
+		{name: "invalid key", encoded: "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$!!!"},
+		{name: "empty key", encoded: "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, err := hasher.Verify("open1234", tt.encoded); ok || !errors.Is(err, ErrMalformedHash) {
+				t.Errorf("Verify(%q) = %v, %v; want ErrMalformedHash", tt.encoded, ok, err)
+			}
+
+			if !hasher.NeedsRehash(tt.encoded) {
+				t.Errorf("NeedsRehash(%q) = false; want true", tt.encoded)
+			}
+		})
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	hasher := NewArgon2id(cheapArgon2)
+
+	tests := []struct {
+		name   string
+		params Argon2Params
+		want   bool
+	}{
+		{name: "same parameters", params: cheapArgon2},
+		{name: "more memory", params: Argon2Params{Memory: 128, Iterations: 1, Parallelism: 1}, want: true},
+		{name: "more iterations", params: Argon2Params{Memory: 64, Iterations: 2, Parallelism: 1}, want: true},
+		{name: "more threads", params: Argon2Params{Memory: 64, Iterations: 1, Parallelism: 2}, want: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			hash := mustHash(t, NewArgon2id(tt.params), "open1234")
+
+			if got := hasher.NeedsRehash(hash); got != tt.want {
+				t.Errorf("NeedsRehash(%q) = %v; want %v", hash, got, tt.want)
+			}
+
+			// A hash of other parameters still verifies, they are read from the hash.
+			if ok, err := hasher.Verify("open1234", hash); !ok || err != nil {
+				t.Errorf("Verify(%q) = %v, %v; want true", hash, ok, err)
+			}
+		})
+	}
+}
+
+func TestBcrypt(t *testing.T) {
+	hasher := NewBcrypt(cheapBcryptCost)
+
+	// A hash of open1234 in the $2a$ format of pgcrypto's crypt(password, gen_salt('bf', 4)).
+	const pgcrypto = "$2a$04$8hwUlcBOEHG..PmQQ1JU5e3EcBujklq6DLZcq4admXiq8Qj8fHJxO"
+
+	if ok, err := hasher.Verify("open1234", pgcrypto); !ok || err != nil {
+		t.Errorf("Verify(pgcrypto hash) = %v, %v; want true", ok, err)
+	}
+
+	if ok, err := hasher.Verify("open1234", "$2a$04$short"); ok || !errors.Is(err, ErrMalformedHash) {
+		t.Errorf("Verify(malformed) = %v, %v; want ErrMalformedHash", ok, err)
+	}
+
+	if !hasher.NeedsRehash(mustHash(t, NewBcrypt(cheapBcryptCost+1), "open1234")) {
+		t.Error("NeedsRehash(hash of another cost) = false; want true")
+	}
+
+	if !hasher.NeedsRehash("$2a$04$short") {
+		t.Error("NeedsRehash(malformed) = false; want true")
+	}
+
+	for _, cost := range []int{0, 3, 32} {
+		if got := NewBcrypt(cost).(*bcryptHasher).cost; got != DefaultBcryptCost {
+			t.Errorf("NewBcrypt(%d) cost = %d; want DefaultBcryptCost", cost, got)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	current := NewArgon2id(cheapArgon2)
+	legacy := NewBcrypt(cheapBcryptCost)
+	hasher := NewHasher(current, legacy, NewBcrypt(cheapBcryptCost+1))
+
+	if got, want := hasher.ID(), []string{"argon2id", "2a", "2b", "2y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ID() = %q; want %q", got, want)
+	}
+
+	hash := mustHash(t, hasher, "open1234")
+	if algorithm(hash) != argon2ID {
+		t.Errorf("Hash() = %q; want a hash of the current hasher", hash)
+	}
+
+	legacyHash := mustHash(t, legacy, "open1234")
+	outdatedHash := mustHash(t, NewArgon2id(Argon2Params{Memory: 128, Iterations: 1, Parallelism: 1}), "open1234")
+
+	tests := []struct {
+		name            string
+		encoded         string
+		wantOK          bool
+		wantErr         error
+		wantNeedsRehash bool
+	}{
+		{name: "current", encoded: hash, wantOK: true},
+		{name: "legacy", encoded: legacyHash, wantOK: true, wantNeedsRehash: true},
+		{name: "outdated parameters", encoded: outdatedHash, wantOK: true, wantNeedsRehash: true},
+		{name: "unknown algorithm", encoded: "$scrypt$ln=15,r=8,p=1$c2FsdA$a2V5", wantErr: ErrUnknownAlgorithm,
+			wantNeedsRehash: true},
+		{name: "not a PHC string", encoded: "open1234", wantErr: ErrUnknownAlgorithm, wantNeedsRehash: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := hasher.Verify("open1234", tt.encoded)
+			if ok != tt.wantOK || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify(%q) = %v, %v; want %v, %v", tt.encoded, ok, err, tt.wantOK, tt.wantErr)
+			}
+
+			if got := hasher.NeedsRehash(tt.encoded); got != tt.wantNeedsRehash {
+				t.Errorf("NeedsRehash(%q) = %v; want %v", tt.encoded, got, tt.wantNeedsRehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package passwords
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidPassword = errors.New("validation error: password")
+)
+
+// Rules reported in a Violation.
+const (
+	RuleMinLength        = "min_length"
+	RuleMaxLength        = "max_length"
+	RuleCharacterClasses = "character_classes"
+	RuleEntropy          = "entropy"
+	RuleReused           = "reused"
+	RuleBreached         = "breached"
+)
+
+// Violation describes a single password rule that was not satisfied.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password failed. It matches ErrInvalidPassword with errors.Is.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	rules := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		rules[i] = v.Rule
+	}
+
+	return ErrInvalidPassword.Error() + ": " + strings.Join(rules, ", ")
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrInvalidPassword
+}
+
+// Policy is a configurable set of rules a new password has to satisfy. A zero value of a rule disables it.
+type Policy struct {
+	// MinLength is the minimum number of characters.
+	MinLength int
+
+	// MaxLength is the maximum number of characters.
+	MaxLength int
+
+	// MinCharacterClasses is the number of classes (lower case, upper case, digit, symbol) that must be present.
+	MinCharacterClasses int
+
+	// MinEntropy is the minimum estimated entropy in bits, based on the length and the classes used.
+	MinEntropy float64
+
+	// HistorySize is the number of most recent passwords of a user that can not be reused.
+	HistorySize int
+
+	// Hasher verifies the password against the previous password hashes of a user.
+	Hasher Hasher
+
+	// Breached is a k-anonymity dataset of breached passwords. Nil disables the check.
+	Breached RangeSource
+}
+
+// Check validates password against every rule of the policy and reports all violations at once as a *PolicyError.
+// previousHashes are the most recent password hashes of the user, newest first.
+func (p *Policy) Check(password string, previousHashes ...string) error {
+	var violations []Violation
+
+	length := utf8.RuneCountInString(password)
+
+	if length < p.MinLength {
+		violations = append(violations, Violation{Rule: RuleMinLength,
+			Message: fmt.Sprintf("must be at least %d characters long", p.MinLength)})
+	}
+
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, Violation{Rule: RuleMaxLength,
+			Message: fmt.Sprintf("must be at most %d characters long", p.MaxLength)})
+	}
+
+	classes, poolSize := characterClasses(password)
+
+	if classes < p.MinCharacterClasses {
+		violations = append(violations, Violation{Rule: RuleCharacterClasses,
+			Message: fmt.Sprintf("must contain at least %d of lower case, upper case, digit and symbol",
+				p.MinCharacterClasses)})
+	}
+
+	if p.MinEntropy > 0 && entropy(length, poolSize) < p.MinEntropy {
+		violations = append(violations, Violation{Rule: RuleEntropy, Message: "is too easy to guess"})
+	}
+
+	reused, err := p.reused(password, previousHashes)
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		violations = append(violations, Violation{Rule: RuleReused,
+			Message: fmt.Sprintf("must not be one of the last %d passwords", p.HistorySize)})
+	}
+
+	breached, err := p.breached(password)
+	if err != nil {
+		return err
+	}
+
+	if breached {
+		violations = append(violations, Violation{Rule: RuleBreached,
+			Message: "has appeared in a data breach"})
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+func (p *Policy) reused(password string, previousHashes []string) (bool, error) {
+	if p.HistorySize <= 0 || p.Hasher == nil {
+		return false, nil
+	}
+
+	if len(previousHashes) > p.HistorySize {
+		previousHashes = previousHashes[:p.HistorySize]
+	}
+
+	for _, hash := range previousHashes {
+		ok, err := p.Hasher.Verify(password, hash)
+		if err != nil && !errors.Is(err, ErrUnknownAlgorithm) {
+			return false, err
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// breached looks the password up by the first 5 hex characters of its SHA-1, so only the prefix is ever
+// handed to the dataset.
+func (p *Policy) breached(password string) (bool, error) {
+	if p.Breached == nil {
+		return false, nil
+	}
+
+	sum := fmt.Sprintf("%X", sha1.Sum([]byte(password))) //nolint:gosec
+
+	suffixes, err := p.Breached.Range(sum[:rangePrefixLength])
+	if err != nil {
+		return false, fmt.Errorf("%w; unable to check breached passwords", err)
+	}
+
+	_, ok := suffixes[sum[rangePrefixLength:]]
+
+	return ok, nil
+}
+
+// entropy estimates the bits of entropy of a password of length characters drawn from a pool of poolSize.
+func entropy(length, poolSize int) float64 {
+	if length == 0 || poolSize == 0 {
+		return 0
+	}
+
+	return float64(length) * math.Log2(float64(poolSize))
+}
+
+// characterClasses returns the number of classes present in password and the size of the character pool they span.
+func characterClasses(password string) (classes, poolSize int) {
+	var lower, upper, digit, symbol, other bool
+
+	for _, r := range password {
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	for _, c := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol || other, 33}} {
+		if c.present {
+			classes++
+			poolSize += c.size
+		}
+	}
+
+	// Non ASCII characters count as symbols but widen the pool considerably.
+	if other {
+		poolSize += 100
+	}
+
+	return classes, poolSize
+}
@@ -0,0 +1,193 @@
+package passwords
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// fakeRanges is a RangeSource of the suffixes of each prefix, failing with err if set.
+type fakeRanges struct {
+	ranges map[string]map[string]int
+	err    error
+}
+
+func (f fakeRanges) Range(prefix string) (map[string]int, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return f.ranges[prefix], nil
+}
+
+// rules returns the rules violated according to err, nil if none.
+func rules(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Check() error = %v; want a *PolicyError", err)
+	}
+
+	var violated []string
+	for _, v := range policyErr.Violations {
+		violated = append(violated, v.Rule)
+	}
+
+	return violated
+}
+
+func TestPolicyCheck(t *testing.T) {
+	// password is in the dataset, by the SHA-1 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	breached := fakeRanges{ranges: map[string]map[string]int{
+		"5BAA6": {"1E4C9B93F3F0682250B6CF8331B7EE68FD8": 9545824},
+	}}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		want     []string
+	}{
+		{name: "no rules", password: ""},
+		{name: "long enough", policy: Policy{MinLength: 8}, password: "open1234"},
+		{name: "too short", policy: Policy{MinLength: 8}, password: "open123", want: []string{RuleMinLength}},
+		{name: "length in characters", policy: Policy{MinLength: 4, MaxLength: 4}, password: "äöüß"},
+		{name: "too long", policy: Policy{MaxLength: 8}, password: "open12345", want: []string{RuleMaxLength}},
+		{name: "enough classes", policy: Policy{MinCharacterClasses: 3}, password: "Open1234"},
+		{name: "symbols", policy: Policy{MinCharacterClasses: 3}, password: "open-1234"},
+		{
+			name: "too few classes", policy: Policy{MinCharacterClasses: 3}, password: "open1234",
+			want: []string{RuleCharacterClasses},
+		},
+		{name: "non ascii as symbol", policy: Policy{MinCharacterClasses: 2}, password: "pässwörd"},
+		{name: "enough entropy", policy: Policy{MinEntropy: 60}, password: "Correct-Horse-9"},
+		{name: "too little entropy", policy: Policy{MinEntropy: 60}, password: "aaaaaaaa", want: []string{RuleEntropy}},
+		{name: "not breached", policy: Policy{Breached: breached}, password: "open1234"},
+		{name: "breached", policy: Policy{Breached: breached}, password: "password", want: []string{RuleBreached}},
+		{
+			name:     "every violation at once",
+			policy:   Policy{MinLength: 12, MinCharacterClasses: 2, MinEntropy: 60, Breached: breached},
+			password: "password",
+			want:     []string{RuleMinLength, RuleCharacterClasses, RuleEntropy, RuleBreached},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.password)
+
+			if got := rules(t, err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check(%q) violations = %q; want %q", tt.password, got, tt.want)
+			}
+
+			if tt.want != nil && !errors.Is(err, ErrInvalidPassword) {
+				t.Errorf("Check(%q) error = %v; want ErrInvalidPassword", tt.password, err)
+			}
+		})
+	}
+}
+
+func TestPolicyHistory(t *testing.T) {
+	hasher := NewHasher(NewBcrypt(cheapBcryptCost))
+	history := []string{
+		mustHash(t, hasher, "newest12"), mustHash(t, hasher, "previous"), mustHash(t, hasher, "oldest12"),
+	}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		history  []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "newest", policy: Policy{HistorySize: 2, Hasher: hasher}, password: "newest12", history: history,
+			want: []string{RuleReused},
+		},
+		{
+			name: "within history", policy: Policy{HistorySize: 2, Hasher: hasher}, password: "previous",
+			history: history, want: []string{RuleReused},
+		},
+		{
+			name: "beyond history", policy: Policy{HistorySize: 2, Hasher: hasher}, password: "oldest12",
+			history: history,
+		},
+		{name: "new", policy: Policy{HistorySize: 2, Hasher: hasher}, password: "open1234", history: history},
+		{name: "history disabled", policy: Policy{Hasher: hasher}, password: "newest12", history: history},
+		{name: "without hasher", policy: Policy{HistorySize: 2}, password: "newest12", history: history},
+		{
+			name: "hash of a removed algorithm skipped", policy: Policy{HistorySize: 2, Hasher: hasher},
+			password: "open1234", history: []string{"$md5$c2FsdA$a2V5"},
+		},
+		{
+			name: "malformed hash", policy: Policy{HistorySize: 2, Hasher: hasher}, password: "open1234",
+			history: []string{"$2a$04$short"}, wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.password, tt.history...)
+
+			if tt.wantErr {
+				if err == nil || errors.Is(err, ErrInvalidPassword) {
+					t.Errorf("Check(%q) error = %v; want a failure to check", tt.password, err)
+				}
+
+				return
+			}
+
+			if got := rules(t, err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check(%q) violations = %q; want %q", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyBreachedUnavailable(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+
+	err := (&Policy{Breached: fakeRanges{err: errUnavailable}}).Check("open1234")
+	if !errors.Is(err, errUnavailable) || errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Check() error = %v; want the dataset error", err)
+	}
+}
+
+func TestPolicyError(t *testing.T) {
+	err := &PolicyError{Violations: []Violation{{Rule: RuleMinLength}, {Rule: RuleBreached}}}
+
+	if got, want := err.Error(), "validation error: password: min_length, breached"; got != want {
+		t.Errorf("Error() = %q; want %q", got, want)
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		want     float64
+	}{
+		{"", 0},
+		{"aaaaaaaa", 8 * math.Log2(26)},
+		{"aA1-", 4 * math.Log2(26+26+10+33)},
+		{"ä", math.Log2(33 + 100)},
+	}
+
+	for _, tt := range tests {
+		_, poolSize := characterClasses(tt.password)
+
+		if got := entropy(len([]rune(tt.password)), poolSize); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("entropy(%q) = %v; want %v", tt.password, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+type AdminHandler struct {
+	users services.UserServicer
+	audit services.AuditServicer
+}
+
+func NewAdminHandler(conf *configs.Conf) *AdminHandler {
+	return &AdminHandler{
+		users: services.NewUserService(services.UserDepsFrom(conf)),
+		audit: services.NewAuditService(conf),
+	}
+}
+
+func (a *AdminHandler) AuditEvents(w http.ResponseWriter, r *http.Request) {
+	WriteResponse(w, r, func() (interface{}, int, error) {
+		ctx := r.Context()
+
+		actor, err := authenticate(ctx, r, a.users)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		query, err := services.ParseAuditQuery(r.URL.Query())
+		if err != nil {
+			return nil, 0, err
+		}
+
+		page, err := a.audit.Events(ctx, actor.ID, query)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return page, http.StatusOK, nil
+	})
+}
+
+func (a *AdminHandler) VerifyAuditChain(w http.ResponseWriter, r *http.Request) {
+	WriteResponse(w, r, func() (interface{}, int, error) {
+		ctx := r.Context()
+
+		actor, err := authenticate(ctx, r, a.users)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		result, err := a.audit.VerifyChain(ctx, actor.ID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return result, http.StatusOK, nil
+	})
+}
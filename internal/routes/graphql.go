@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/graph"
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+type GraphQLHandler struct {
+	schema       *graph.Schema
+	maxBodyBytes int64
+}
+
+func NewGraphQLHandler(conf *configs.Conf) *GraphQLHandler {
+	return &GraphQLHandler{schema: graph.NewSchema(conf), maxBodyBytes: conf.MaxRequestBodyBytes}
+}
+
+// Execute runs a GraphQL request. The bearer token is optional, as signing up and logging in need none. Once the
+// request is decoded the response is always written with status 200, failures being reported in its errors.
+func (g *GraphQLHandler) Execute(w http.ResponseWriter, r *http.Request) {
+	WriteResponse(w, r, func() (interface{}, int, error) {
+		body := &graph.Request{}
+
+		err := services.DecodeJSON(r.Body, g.maxBodyBytes, body)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if err = services.Validate(body); err != nil {
+			return nil, 0, err
+		}
+
+		body.Token, _ = bearerToken(r)
+
+		return g.schema.Execute(r.Context(), body), http.StatusOK, nil
+	})
+}
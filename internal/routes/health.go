@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/maknahar/alpha-flow/internal/health"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Live reports that the process is up. It checks no dependency, so that a broken dependency does not get the
+// service restarted.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	WriteResponse(w, r, func() (interface{}, int, error) {
+		return &health.Report{Status: health.StatusOK}, http.StatusOK, nil
+	})
+}
+
+// Ready reports whether the service can take traffic along with the result of every dependency check. It answers
+// 503 while a critical check fails or once the service is shutting down.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	WriteResponse(w, r, func() (interface{}, int, error) {
+		report := h.checker.Check(r.Context())
+		if !report.Ready() {
+			return report, http.StatusServiceUnavailable, nil
+		}
+
+		return report, http.StatusOK, nil
+	})
+}
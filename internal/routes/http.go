@@ -2,26 +2,48 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+
+	"github.com/maknahar/alpha-flow/internal/logging"
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:alpha-flow:problem:"
 )
 
-func WriteResponse(w http.ResponseWriter, h func() (interface{}, int, error)) {
+// Problem is an RFC 7807 problem details body, extended with the stable error code, the request id and field errors.
+type Problem struct {
+	Type      string                `json:"type"`
+	Title     string                `json:"title"`
+	Status    int                   `json:"status"`
+	Detail    string                `json:"detail"`
+	Instance  string                `json:"instance,omitempty"`
+	Code      services.Code         `json:"code"`
+	RequestID string                `json:"request_id,omitempty"`
+	Errors    []services.FieldError `json:"errors,omitempty"`
+}
+
+// WriteResponse writes the value returned by h with statusCode as JSON. If h fails the error is written as a Problem
+// and statusCode is ignored.
+func WriteResponse(w http.ResponseWriter, r *http.Request, h func() (interface{}, int, error)) {
 	response, statusCode, err := h()
 	if err != nil {
-		response = struct {
-			Error string `json:"error"`
-		}{Error: err.Error()}
+		WriteError(w, r, err)
+		return
 	}
 
 	data, err := json.Marshal(response)
 	if err != nil {
-		data, _ = json.Marshal(struct {
-			Error string `json:"error"`
-		}{Error: err.Error()})
-		statusCode = http.StatusInternalServerError
+		WriteError(w, r, err)
+		return
 	}
 
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(statusCode)
 
@@ -29,3 +51,61 @@ func WriteResponse(w http.ResponseWriter, h func() (interface{}, int, error)) {
 		return
 	}
 }
+
+// WriteError maps err to an application error and writes it as a Problem.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	appErr := MapError(err)
+	appErr.RequestID = middleware.GetReqID(r.Context())
+
+	if appErr.Status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).WithError(err).WithField("code", appErr.Code).
+			Error("Error in handling request")
+	}
+
+	data, _ := json.Marshal(Problem{
+		Type:      problemTypePrefix + string(appErr.Code),
+		Title:     http.StatusText(appErr.Status),
+		Status:    appErr.Status,
+		Detail:    appErr.Message,
+		Instance:  r.URL.Path,
+		Code:      appErr.Code,
+		RequestID: appErr.RequestID,
+		Errors:    appErr.Details,
+	})
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(appErr.Status)
+
+	if _, err := w.Write(data); err != nil {
+		return
+	}
+}
+
+// MapError returns a copy of the application error in err's chain, an ErrInternal for any other error so that no
+// internal message reaches the client. Request bodies are decoded by services.DecodeJSON, whose errors already are
+// ErrMalformedRequest.
+func MapError(err error) *services.Error {
+	var appErr *services.Error
+	if !errors.As(err, &appErr) {
+		return services.ErrInternal.Wrap(err)
+	}
+
+	c := *appErr
+
+	return &c
+}
+
+// clientMiddleware records the address and user agent of the client along with the audit events of the request. It
+// has to run after middleware.RealIP.
+func clientMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+
+		ctx := services.WithClient(r.Context(), services.Client{IP: ip, UserAgent: r.UserAgent()})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,502 @@
+package routes
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/maknahar/alpha-flow/internal/graph"
+	"github.com/maknahar/alpha-flow/internal/health"
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+	specVersion    = "1.0.0"
+)
+
+// operation documents a single route of the API.
+type operation struct {
+	Summary string
+	// Request is the DTO the body is decoded into, nil if the route has no body.
+	Request interface{}
+	// Response is the DTO written on success, nil if the body is empty.
+	Response interface{}
+	// Query are the query parameters of the route.
+	Query []Parameter
+	// Status is the status code written on success.
+	Status int
+	// Auth is true if the route requires a bearer token.
+	Auth bool
+	// Errors are the status codes of the problems the route can return besides 500.
+	Errors []int
+	// Deprecated marks the route as deprecated.
+	Deprecated bool
+}
+
+// v1Operations documents every route of version v1, keyed by "<METHOD> <pattern>" relative to /v1. Routes missing
+// here are left out of the OpenAPI specification.
+//
+//nolint:gochecknoglobals
+var v1Operations = map[string]operation{
+	"POST /signup": {
+		Summary:  "Create an account",
+		Request:  services.SignUpRequestDTO{},
+		Response: services.SignUpResponseDTO{},
+		Status:   http.StatusOK,
+		Errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge,
+			http.StatusUnprocessableEntity},
+	},
+	"POST /login": {
+		Summary:  "Exchange credentials for an access token",
+		Request:  services.LoginRequestDTO{},
+		Response: services.LoginResponseDTO{},
+		Status:   http.StatusOK,
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusRequestEntityTooLarge,
+			http.StatusUnprocessableEntity},
+	},
+	"GET /secret": {
+		Summary:  "Get the secret of the authenticated user",
+		Response: services.GetSecretResponseDTO{},
+		Status:   http.StatusOK,
+		Auth:     true,
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden},
+	},
+	"PATCH /users/{id}": {
+		Summary:  "Change the email and/or password of the authenticated user",
+		Request:  services.UpdateCredentialsRequestDTO{},
+		Response: services.SignUpResponseDTO{},
+		Status:   http.StatusOK,
+		Auth:     true,
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict,
+			http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+	},
+	"GET /subscriptions/validpairs": {
+		Summary:  "List the pairs that can be subscribed to",
+		Response: []string{},
+		Status:   http.StatusOK,
+		Auth:     true,
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusBadGateway},
+	},
+	"POST /subscriptions": {
+		Summary: "Subscribe the authenticated user to a pair",
+		Request: services.CreateSubscriptionDTO{},
+		Status:  http.StatusOK,
+		Auth:    true,
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden,
+			http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity, http.StatusBadGateway},
+	},
+	"GET /admin/audit-events": {
+		Summary: "List the audit events, newest first. Admins only",
+		Query: []Parameter{
+			{Name: "type", In: "query", Description: "Comma separated event types", Schema: &Schema{Type: "string"}},
+			{Name: "actor_id", In: "query", Schema: &Schema{Type: "integer", Format: "int64"}},
+			{Name: "subject_id", In: "query", Schema: &Schema{Type: "integer", Format: "int64"}},
+			{Name: "since", In: "query", Schema: &Schema{Type: "string", Format: "date-time"}},
+			{Name: "until", In: "query", Schema: &Schema{Type: "string", Format: "date-time"}},
+			{Name: "limit", In: "query", Description: "Default 50, at most 200", Schema: &Schema{Type: "integer"}},
+			{Name: "cursor", In: "query", Description: "next_cursor of the previous page",
+				Schema: &Schema{Type: "string"}},
+		},
+		Response: services.AuditEventsDTO{},
+		Status:   http.StatusOK,
+		Auth:     true,
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusUnprocessableEntity},
+	},
+	"GET /admin/audit-events/verify": {
+		Summary:  "Verify the hash chain of the audit events. Admins only",
+		Response: services.AuditChainDTO{},
+		Status:   http.StatusOK,
+		Auth:     true,
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden},
+	},
+}
+
+// rootOperations documents the unversioned routes.
+//
+//nolint:gochecknoglobals
+var rootOperations = map[string]operation{
+	"POST /graphql": {
+		Summary:  "Execute a GraphQL query or mutation",
+		Request:  graph.Request{},
+		Response: graph.Response{},
+		Status:   http.StatusOK,
+		Errors:   []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+	},
+	"GET /metrics": {
+		Summary: "Get the metrics of the service in the Prometheus exposition format",
+		Status:  http.StatusOK,
+	},
+	"GET /healthz": {
+		Summary:  "Check that the service is up",
+		Response: health.Report{},
+		Status:   http.StatusOK,
+	},
+	"GET /readyz": {
+		// The same report is written with status 503 while the service is not ready.
+		Summary:  "Check that the service and its dependencies are ready to take traffic",
+		Response: health.Report{},
+		Status:   http.StatusOK,
+	},
+	"GET /openapi.json": {
+		Summary: "Get this OpenAPI specification",
+		Status:  http.StatusOK,
+	},
+	"GET /docs": {
+		Summary: "Browse this OpenAPI specification",
+		Status:  http.StatusOK,
+	},
+	"GET /docs/{asset}": {
+		Summary: "Get a Swagger UI file of the documentation page",
+		Status:  http.StatusOK,
+	},
+}
+
+// OpenAPI is an OpenAPI 3 document.
+type OpenAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       map[string]string                `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema                `json:"schemas"`
+	SecuritySchemes map[string]map[string]interface{} `json:"securitySchemes"`
+}
+
+type Operation struct {
+	Summary     string                `json:"summary"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *Body                 `json:"requestBody,omitempty"`
+	Responses   map[string]*Body      `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Body struct {
+	Description string                        `json:"description,omitempty"`
+	Required    bool                          `json:"required,omitempty"`
+	Content     map[string]map[string]*Schema `json:"content,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// BuildOpenAPI describes every route registered in r that is documented by its version or in rootOperations.
+// Root aliases of the legacy version are described as deprecated.
+func BuildOpenAPI(r chi.Routes) (*OpenAPI, error) {
+	doc := &OpenAPI{
+		OpenAPI: openAPIVersion,
+		Info:    map[string]string{"title": "alpha-flow", "version": specVersion},
+		Paths:   make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]map[string]interface{}{
+				"bearerAuth": {"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+
+	doc.schema(reflect.TypeOf(Problem{}))
+
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		op, ok := lookupOperation(method, route)
+		if !ok {
+			return nil
+		}
+
+		if doc.Paths[route] == nil {
+			doc.Paths[route] = make(map[string]*Operation)
+		}
+
+		doc.Paths[route][strings.ToLower(method)] = doc.operation(method, route, op)
+
+		return nil
+	})
+
+	return doc, err
+}
+
+// lookupOperation returns the documentation of a registered route.
+func lookupOperation(method, route string) (operation, bool) {
+	if op, ok := rootOperations[method+" "+route]; ok {
+		return op, true
+	}
+
+	v, relative, alias := versionOf(route)
+	if v == nil {
+		return operation{}, false
+	}
+
+	op, ok := v.Operations[method+" "+relative]
+	op.Deprecated = op.Deprecated || alias || v.Deprecated
+
+	return op, ok
+}
+
+func (doc *OpenAPI) operation(method, route string, op operation) *Operation {
+	o := &Operation{
+		Summary:     op.Summary,
+		OperationID: operationID(method, route),
+		Responses:   make(map[string]*Body),
+		Deprecated:  op.Deprecated,
+	}
+
+	for _, segment := range strings.Split(route, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			o.Parameters = append(o.Parameters, Parameter{Name: strings.Trim(segment, "{}"), In: "path",
+				Required: true, Schema: &Schema{Type: "integer", Format: "int64"}})
+		}
+	}
+
+	o.Parameters = append(o.Parameters, op.Query...)
+
+	if op.Request != nil {
+		o.RequestBody = &Body{Required: true, Content: map[string]map[string]*Schema{
+			"application/json": {"schema": doc.schema(reflect.TypeOf(op.Request))},
+		}}
+	}
+
+	success := &Body{Description: http.StatusText(op.Status)}
+	if op.Response != nil {
+		success.Content = map[string]map[string]*Schema{
+			"application/json": {"schema": doc.schema(reflect.TypeOf(op.Response))},
+		}
+	}
+
+	o.Responses[strconv.Itoa(op.Status)] = success
+
+	if op.Auth {
+		o.Security = []map[string][]string{{"bearerAuth": {}}}
+	}
+
+	for _, status := range append(op.Errors, http.StatusInternalServerError) {
+		o.Responses[strconv.Itoa(status)] = &Body{
+			Description: http.StatusText(status),
+			Content: map[string]map[string]*Schema{
+				problemContentType: {"schema": {Ref: "#/components/schemas/Problem"}},
+			},
+		}
+	}
+
+	return o
+}
+
+// schema returns the schema of t. Named structs are added to the components and referenced.
+func (doc *OpenAPI) schema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		s := doc.schema(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+
+		return s
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: doc.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: doc.schema(t.Elem())}
+	case reflect.Struct:
+		ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+		if _, ok := doc.Components.Schemas[t.Name()]; ok {
+			return ref
+		}
+
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		doc.Components.Schemas[t.Name()] = s
+		doc.properties(s, t)
+
+		return ref
+	default:
+		return &Schema{}
+	}
+}
+
+// properties adds the JSON fields of t, including those of embedded structs, to s.
+func (doc *OpenAPI) properties(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			doc.properties(s, field.Type)
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		p := doc.schema(field.Type)
+
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			rule, arg := services.SplitRule(rule)
+			n, _ := strconv.Atoi(arg)
+
+			switch rule {
+			case "required":
+				s.Required = append(s.Required, name)
+
+				if p.Type == "string" {
+					one := 1
+					p.MinLength = &one
+				}
+			case "min":
+				p.MinLength = &n
+			case "max":
+				p.MaxLength = &n
+			case "email":
+				p.Format = "email"
+			}
+		}
+
+		s.Properties[name] = p
+	}
+}
+
+// operationID derives a stable identifier like patchUsersId from the method and route.
+func operationID(method, route string) string {
+	id := strings.ToLower(method)
+
+	for _, part := range strings.FieldsFunc(route, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '.' || r == '-' || r == '_'
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+
+	return id
+}
+
+// OpenAPIHandler serves the specification of the routes of r, built on first request so every route is registered.
+func OpenAPIHandler(r chi.Routes) http.HandlerFunc {
+	var (
+		once sync.Once
+		data []byte
+		err  error
+	)
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		once.Do(func() {
+			var doc *OpenAPI
+
+			doc, err = BuildOpenAPI(r)
+			if err == nil {
+				data, err = json.Marshal(doc)
+			}
+		})
+
+		if err != nil {
+			WriteError(w, req, err)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		if _, err := w.Write(data); err != nil {
+			return
+		}
+	}
+}
+
+// DocsHandler serves a Swagger UI page for the specification at specURL.
+func DocsHandler(specURL string) http.HandlerFunc {
+	page := strings.Replace(docsPage, "{{SPEC_URL}}", strconv.Quote(specURL), 1)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		if _, err := w.Write([]byte(page)); err != nil {
+			return
+		}
+	}
+}
+
+// docsAssets are the files of Swagger UI 5.18.2 the documentation page loads, served from the binary so that no third
+// party code is loaded at runtime and the page works offline.
+//
+//nolint:gochecknoglobals
+//go:embed swaggerui/swagger-ui-bundle.js swaggerui/swagger-ui.css
+var docsAssets embed.FS
+
+// DocsAssetHandler serves the Swagger UI file named by the asset URL parameter.
+func DocsAssetHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "asset")
+
+	data, err := docsAssets.ReadFile("swaggerui/" + name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>alpha-flow API</title>
+  <link rel="stylesheet" href="docs/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="docs/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      SwaggerUIBundle({url: {{SPEC_URL}}, dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>
+`
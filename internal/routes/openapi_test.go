@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/health"
+)
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	r := Get(&configs.Conf{LegacyRoutes: true}, health.New())
+
+	doc, err := BuildOpenAPI(r)
+	if err != nil {
+		t.Fatalf("BuildOpenAPI() error = %v", err)
+	}
+
+	registered := make(map[string]bool)
+
+	err = chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		registered[method+" "+route] = true
+
+		if doc.Paths[route][strings.ToLower(method)] == nil {
+			t.Errorf("route %s %s is registered but missing from the OpenAPI specification", method, route)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("chi.Walk() error = %v", err)
+	}
+
+	for _, v := range apiVersions {
+		for key := range v.Operations {
+			parts := strings.SplitN(key, " ", 2)
+
+			if !registered[parts[0]+" /"+v.Name+parts[1]] {
+				t.Errorf("route %s of %s is documented but not registered", key, v.Name)
+			}
+		}
+	}
+
+	for key := range rootOperations {
+		if !registered[key] {
+			t.Errorf("route %s is documented but not registered", key)
+		}
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	Get(&configs.Conf{}, health.New()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var doc OpenAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("GET /openapi.json returned invalid JSON: %v", err)
+	}
+
+	if problem := doc.Components.Schemas["Problem"]; problem == nil || problem.Properties["code"] == nil {
+		t.Errorf("Problem schema = %+v, want code property", problem)
+	}
+
+	signup := doc.Components.Schemas["SignUpRequestDTO"]
+	if signup == nil || signup.Properties["email"] == nil || len(signup.Required) != 2 {
+		t.Errorf("SignUpRequestDTO schema = %+v, want email and password required", signup)
+	}
+
+	if update := doc.Components.Schemas["UpdateCredentialsRequestDTO"]; update == nil ||
+		update.Properties["Token"] != nil || update.Properties["ID"] != nil {
+		t.Errorf("UpdateCredentialsRequestDTO schema = %+v, want server owned fields hidden", update)
+	}
+}
+
+func TestDocsHandler(t *testing.T) {
+	r := Get(&configs.Conf{}, health.New())
+
+	tests := []struct {
+		path            string
+		wantStatus      int
+		wantContentType string
+	}{
+		{path: "/docs", wantStatus: http.StatusOK, wantContentType: "text/html"},
+		{path: "/docs/swagger-ui.css", wantStatus: http.StatusOK, wantContentType: "text/css"},
+		{path: "/docs/swagger-ui-bundle.js", wantStatus: http.StatusOK, wantContentType: "javascript"},
+		{path: "/docs/LICENSE", wantStatus: http.StatusNotFound},
+		{path: "/docs/..%2Fopenapi.go", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("GET %s status = %d, want %d", tt.path, w.Code, tt.wantStatus)
+		}
+
+		if got := w.Header().Get("Content-Type"); !strings.Contains(got, tt.wantContentType) {
+			t.Errorf("GET %s Content-Type = %q, want %s", tt.path, got, tt.wantContentType)
+		}
+
+		// Every file of the page is served by the service itself.
+		if strings.Contains(w.Body.String(), "unpkg.com") {
+			t.Errorf("GET %s loads files from unpkg.com", tt.path)
+		}
+	}
+}
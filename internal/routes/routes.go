@@ -2,42 +2,83 @@ package routes
 
 import (
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
 	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/health"
+	"github.com/maknahar/alpha-flow/internal/logging"
+	"github.com/maknahar/alpha-flow/internal/metrics"
+	"github.com/maknahar/alpha-flow/internal/services"
+	"github.com/maknahar/alpha-flow/internal/tracing"
 )
 
-func Get(conf *configs.Conf) *chi.Mux {
+func Get(conf *configs.Conf, checker *health.Checker) *chi.Mux {
 	r := chi.NewRouter()
 
-	cor := cors.New(cors.Options{
-		AllowedOrigins:     []string{"*"}, // Change this according to url of env
-		AllowedMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodOptions},
-		AllowedHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:     []string{"Link"},
-		AllowCredentials:   true,
-		OptionsPassthrough: true,
-		MaxAge:             300, // Maximum value not ignored by any of major browsers
-	})
+	cor := &reloadingCORS{conf: conf}
 
 	// Set a timeout value on the request context (ctx), that will signal through ctx.Done() that the request has timed
 	// out and further processing should be stopped.
 	r.Use(middleware.Timeout(time.Minute), middleware.AllowContentType("application/json"))
 
-	r.Use(cor.Handler, middleware.RequestID, middleware.RealIP, middleware.Logger, middleware.Recoverer)
+	r.Use(cor.Handler, middleware.RequestID, middleware.RealIP, tracing.Middleware, metrics.Middleware,
+		logging.Middleware(conf.Logger, conf.LogSampler), middleware.Recoverer, clientMiddleware)
+
+	users := NewUsersHandler(services.NewUserService(services.UserDepsFrom(conf)), conf.MaxRequestBodyBytes)
+	h := &handlers{user: users, admin: NewAdminHandler(conf)}
+
+	mountVersions(r, h, conf.LegacyRoutes, conf.LegacyRoutesSunset)
+
+	r.Post("/graphql", NewGraphQLHandler(conf).Execute)
 
-	user := NewUsersHandler(conf.DB)
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
 
-	r.Post("/signup", user.SignUp)
-	r.Post("/login", user.Login)
-	r.Get("/secret", user.GetSecret)
-	r.Patch("/users/{id}", user.UpdateCredentials)
+	healthHandler := NewHealthHandler(checker)
+	r.Get("/healthz", healthHandler.Live)
+	r.Get("/readyz", healthHandler.Ready)
 
-	r.Get("/subscriptions/validpairs", user.GetValidPairs)
-	r.Post("/subscriptions", user.CreateSubscription)
+	r.Get("/openapi.json", OpenAPIHandler(r))
+	r.Get("/docs", DocsHandler("/openapi.json"))
+	r.Get("/docs/{asset}", DocsAssetHandler)
 
 	return r
 }
+
+// reloadingCORS applies the CORS policy of the allowed origins of the current runtime configuration, built again
+// whenever the configuration is reloaded.
+type reloadingCORS struct {
+	conf    *configs.Conf
+	current atomic.Value // corsPolicy
+}
+
+type corsPolicy struct {
+	runtime *configs.Runtime
+	cors    *cors.Cors
+}
+
+func (c *reloadingCORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runtime := c.conf.Runtime()
+
+		policy, _ := c.current.Load().(corsPolicy)
+		if policy.runtime != runtime {
+			policy = corsPolicy{runtime: runtime, cors: cors.New(cors.Options{
+				AllowedOrigins: runtime.CORSAllowedOrigins,
+				AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodOptions},
+				AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "traceparent",
+					"tracestate"},
+				ExposedHeaders:     []string{"Link", "Deprecation", "Sunset"},
+				AllowCredentials:   true,
+				OptionsPassthrough: true,
+				MaxAge:             300, // Maximum value not ignored by any of major browsers
+			})}
+			c.current.Store(policy)
+		}
+
+		policy.cors.Handler(next).ServeHTTP(w, r)
+	})
+}
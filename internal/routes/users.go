@@ -1,42 +1,61 @@
 package routes
 
 import (
-	"database/sql"
-	"encoding/json"
-	"errors"
+	"context"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi"
 
-	"github.com/sirupsen/logrus"
-
 	"github.com/maknahar/alpha-flow/internal/services"
 )
 
 type UserHandler struct {
-	service services.UserServicer
+	service      services.UserServicer
+	maxBodyBytes int64
+}
+
+// NewUsersHandler returns the handler of the user routes, serving them with service. Request bodies larger than
+// maxBodyBytes are rejected.
+func NewUsersHandler(service services.UserServicer, maxBodyBytes int64) *UserHandler {
+	return &UserHandler{service: service, maxBodyBytes: maxBodyBytes}
+}
+
+// bearerToken returns the token of the Authorization header.
+func bearerToken(r *http.Request) (string, error) {
+	splitToken := strings.Split(r.Header.Get("Authorization"), "Bearer ")
+	if len(splitToken) < 2 || splitToken[1] == "" {
+		return "", services.ErrMissingToken
+	}
+
+	return splitToken[1], nil
 }
 
-func NewUsersHandler(db *sql.DB) *UserHandler {
-	return &UserHandler{service: services.NewUserService(db)}
+// authenticate returns the user owning the bearer token of the request.
+func authenticate(ctx context.Context, r *http.Request, service services.UserServicer) (
+	*services.GetSecretResponseDTO, error) {
+	reqToken, err := bearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetSecret(ctx, reqToken)
 }
 
 func (u *UserHandler) SignUp(w http.ResponseWriter, r *http.Request) {
-	WriteResponse(w, func() (interface{}, int, error) {
+	WriteResponse(w, r, func() (interface{}, int, error) {
 		ctx := r.Context()
 		body := &services.SignUpRequestDTO{}
 
-		err := json.NewDecoder(r.Body).Decode(body)
+		err := services.DecodeJSON(r.Body, u.maxBodyBytes, body)
 		if err != nil {
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		dto, err := u.service.SignUp(ctx, body)
 		if err != nil {
-			logrus.WithError(err).Error("Error in user signup")
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		return dto, http.StatusOK, nil
@@ -44,23 +63,18 @@ func (u *UserHandler) SignUp(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-	WriteResponse(w, func() (interface{}, int, error) {
+	WriteResponse(w, r, func() (interface{}, int, error) {
 		ctx := r.Context()
 		body := &services.LoginRequestDTO{}
 
-		err := json.NewDecoder(r.Body).Decode(body)
+		err := services.DecodeJSON(r.Body, u.maxBodyBytes, body)
 		if err != nil {
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		dto, err := u.service.Login(ctx, body)
 		if err != nil {
-			if errors.Is(err, services.ErrInvalidCredentials) {
-				return nil, http.StatusUnauthorized, err
-			}
-
-			logrus.WithError(err).Error("Error in user login")
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		return dto, http.StatusOK, nil
@@ -68,23 +82,10 @@ func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *UserHandler) GetSecret(w http.ResponseWriter, r *http.Request) {
-	WriteResponse(w, func() (interface{}, int, error) {
-		ctx := r.Context()
-		reqToken := r.Header.Get("Authorization")
-		splitToken := strings.Split(reqToken, "Bearer ")
-		if len(splitToken) < 2 {
-			return nil, http.StatusUnauthorized, services.ErrInvalidToken
-		}
-		reqToken = splitToken[1]
-
-		dto, err := u.service.GetSecret(ctx, reqToken)
+	WriteResponse(w, r, func() (interface{}, int, error) {
+		dto, err := authenticate(r.Context(), r, u.service)
 		if err != nil {
-			if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrExpiredToken) {
-				return nil, http.StatusForbidden, err
-			}
-
-			logrus.WithError(err).Error("Error in user login")
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		return dto, http.StatusOK, nil
@@ -92,28 +93,17 @@ func (u *UserHandler) GetSecret(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *UserHandler) GetValidPairs(w http.ResponseWriter, r *http.Request) {
-	WriteResponse(w, func() (interface{}, int, error) {
+	WriteResponse(w, r, func() (interface{}, int, error) {
 		ctx := r.Context()
-		reqToken := r.Header.Get("Authorization")
-		splitToken := strings.Split(reqToken, "Bearer ")
-		if len(splitToken) < 2 {
-			return nil, http.StatusUnauthorized, services.ErrInvalidToken
-		}
-		reqToken = splitToken[1]
 
-		_, err := u.service.GetSecret(ctx, reqToken)
+		_, err := authenticate(ctx, r, u.service)
 		if err != nil {
-			if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrExpiredToken) {
-				return nil, http.StatusForbidden, err
-			}
-
-			logrus.WithError(err).Error("Error in user authentication")
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		validPairs, err := u.service.GetAllValidPairs(ctx)
 		if err != nil {
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		return validPairs, http.StatusOK, nil
@@ -121,38 +111,24 @@ func (u *UserHandler) GetValidPairs(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *UserHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
-	WriteResponse(w, func() (interface{}, int, error) {
+	WriteResponse(w, r, func() (interface{}, int, error) {
 		ctx := r.Context()
-		reqToken := r.Header.Get("Authorization")
-		splitToken := strings.Split(reqToken, "Bearer ")
-		if len(splitToken) < 2 {
-			return nil, http.StatusUnauthorized, services.ErrInvalidToken
-		}
-		reqToken = splitToken[1]
 
-		userInfo, err := u.service.GetSecret(ctx, reqToken)
+		userInfo, err := authenticate(ctx, r, u.service)
 		if err != nil {
-			if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrExpiredToken) {
-				return nil, http.StatusForbidden, err
-			}
-
-			logrus.WithError(err).Error("Error in user authentication")
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		body := &services.CreateSubscriptionDTO{}
 
-		err = json.NewDecoder(r.Body).Decode(body)
+		err = services.DecodeJSON(r.Body, u.maxBodyBytes, body)
 		if err != nil {
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		err = u.service.CreateSubscription(ctx, userInfo.ID, body.Pair)
 		if err != nil {
-			if err != services.ErrInvalidPair {
-				return nil, http.StatusUnprocessableEntity, err
-			}
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		return nil, http.StatusOK, nil
@@ -160,24 +136,23 @@ func (u *UserHandler) CreateSubscription(w http.ResponseWriter, r *http.Request)
 }
 
 func (u *UserHandler) UpdateCredentials(w http.ResponseWriter, r *http.Request) {
-	WriteResponse(w, func() (interface{}, int, error) {
+	WriteResponse(w, r, func() (interface{}, int, error) {
 		ctx := r.Context()
 		body := &services.UpdateCredentialsRequestDTO{}
-		reqToken := r.Header.Get("Authorization")
-		splitToken := strings.Split(reqToken, "Bearer ")
-		if len(splitToken) < 2 {
-			return nil, http.StatusForbidden, services.ErrInvalidToken
+
+		reqToken, err := bearerToken(r)
+		if err != nil {
+			return nil, 0, err
 		}
-		reqToken = splitToken[1]
 
 		userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 		if err != nil {
-			return nil, http.StatusBadRequest, errors.New("invalid user id")
+			return nil, 0, services.ErrInvalidUserID
 		}
 
-		err = json.NewDecoder(r.Body).Decode(body)
+		err = services.DecodeJSON(r.Body, u.maxBodyBytes, body)
 		if err != nil {
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		body.Token = reqToken
@@ -185,12 +160,7 @@ func (u *UserHandler) UpdateCredentials(w http.ResponseWriter, r *http.Request)
 
 		dto, err := u.service.Update(ctx, body)
 		if err != nil {
-			if errors.Is(err, services.ErrAccessDenied) || errors.Is(err, services.ErrInvalidCredentials) {
-				return nil, http.StatusForbidden, err
-			}
-
-			logrus.WithError(err).Error("Error in user login")
-			return nil, http.StatusInternalServerError, err
+			return nil, 0, err
 		}
 
 		return dto, http.StatusOK, nil
@@ -0,0 +1,288 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/golang/mock/gomock"
+
+	"github.com/maknahar/alpha-flow/internal/services"
+	"github.com/maknahar/alpha-flow/internal/services/mocks"
+)
+
+// testMaxBodyBytes is the largest request body accepted by the handler under test.
+const testMaxBodyBytes = 64
+
+func TestUserHandler(t *testing.T) {
+	const token = "token"
+
+	owner := &services.GetSecretResponseDTO{ID: 1, Secret: "secret"}
+	failure := errors.New("storage failure")
+
+	// authenticated expects the token to be checked, successfully.
+	authenticated := func(m *mocks.MockUserServicerMockRecorder) {
+		m.GetSecret(gomock.Any(), token).Return(owner, nil)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		setup      func(m *mocks.MockUserServicerMockRecorder)
+		wantStatus int
+		wantCode   services.Code
+	}{
+		{
+			name: "sign up", method: http.MethodPost, path: "/signup",
+			body: `{"email": "alice@example.com", "password": "open1234"}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.SignUp(gomock.Any(), &services.SignUpRequestDTO{Email: "alice@example.com", Password: "open1234"}).
+					Return(&services.SignUpResponseDTO{ID: 1}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "sign up with malformed body", method: http.MethodPost, path: "/signup", body: `{"email": `,
+			wantStatus: http.StatusBadRequest, wantCode: services.CodeMalformedRequest,
+		},
+		{
+			name: "sign up with unknown field", method: http.MethodPost, path: "/signup", body: `{"name": "alice"}`,
+			wantStatus: http.StatusBadRequest, wantCode: services.CodeMalformedRequest,
+		},
+		{
+			name: "sign up with too large body", method: http.MethodPost, path: "/signup",
+			body:       `{"email": "` + strings.Repeat("a", testMaxBodyBytes) + `@example.com"}`,
+			wantStatus: http.StatusRequestEntityTooLarge, wantCode: services.CodeRequestTooLarge,
+		},
+		{
+			name: "sign up of existing account", method: http.MethodPost, path: "/signup", body: `{}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.SignUp(gomock.Any(), gomock.Any()).Return(nil, services.ErrAccountExists)
+			},
+			wantStatus: http.StatusConflict, wantCode: services.CodeAccountExists,
+		},
+		{
+			name: "sign up with invalid email", method: http.MethodPost, path: "/signup", body: `{}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.SignUp(gomock.Any(), gomock.Any()).Return(nil, services.ErrInvalidEmail)
+			},
+			wantStatus: http.StatusUnprocessableEntity, wantCode: services.CodeInvalidEmail,
+		},
+		{
+			name: "sign up failing", method: http.MethodPost, path: "/signup", body: `{}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.SignUp(gomock.Any(), gomock.Any()).Return(nil, failure)
+			},
+			wantStatus: http.StatusInternalServerError, wantCode: services.CodeInternal,
+		},
+		{
+			name: "login", method: http.MethodPost, path: "/login",
+			body: `{"email": "alice@example.com", "password": "open1234"}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.Login(gomock.Any(), &services.LoginRequestDTO{Email: "alice@example.com", Password: "open1234"}).
+					Return(&services.LoginResponseDTO{Token: token}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "login with malformed body", method: http.MethodPost, path: "/login", body: `[]`,
+			wantStatus: http.StatusBadRequest, wantCode: services.CodeMalformedRequest,
+		},
+		{
+			name: "login with invalid credentials", method: http.MethodPost, path: "/login", body: `{}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.Login(gomock.Any(), gomock.Any()).Return(nil, services.ErrInvalidCredentials)
+			},
+			wantStatus: http.StatusUnauthorized, wantCode: services.CodeInvalidCredentials,
+		},
+		{
+			name: "login failing", method: http.MethodPost, path: "/login", body: `{}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.Login(gomock.Any(), gomock.Any()).Return(nil, failure)
+			},
+			wantStatus: http.StatusInternalServerError, wantCode: services.CodeInternal,
+		},
+		{
+			// The storage failing mid-query is no mistake of the client.
+			name: "login dropping the connection", method: http.MethodPost, path: "/login", body: `{}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.Login(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("issuing token: %w", io.ErrUnexpectedEOF))
+			},
+			wantStatus: http.StatusInternalServerError, wantCode: services.CodeInternal,
+		},
+		{
+			name: "secret", method: http.MethodGet, path: "/secret", token: token, setup: authenticated,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "secret without token", method: http.MethodGet, path: "/secret",
+			wantStatus: http.StatusUnauthorized, wantCode: services.CodeMissingToken,
+		},
+		{
+			name: "secret with invalid token", method: http.MethodGet, path: "/secret", token: token,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.GetSecret(gomock.Any(), token).Return(nil, services.ErrInvalidToken)
+			},
+			wantStatus: http.StatusForbidden, wantCode: services.CodeInvalidToken,
+		},
+		{
+			name: "secret with expired token", method: http.MethodGet, path: "/secret", token: token,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.GetSecret(gomock.Any(), token).Return(nil, services.ErrExpiredToken)
+			},
+			wantStatus: http.StatusForbidden, wantCode: services.CodeExpiredToken,
+		},
+		{
+			name: "valid pairs", method: http.MethodGet, path: "/subscriptions/validpairs", token: token,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				authenticated(m)
+				m.GetAllValidPairs(gomock.Any()).Return([]string{"btc_eth"}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "valid pairs without token", method: http.MethodGet, path: "/subscriptions/validpairs",
+			wantStatus: http.StatusUnauthorized, wantCode: services.CodeMissingToken,
+		},
+		{
+			name: "valid pairs with invalid token", method: http.MethodGet, path: "/subscriptions/validpairs",
+			token: token,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.GetSecret(gomock.Any(), token).Return(nil, services.ErrInvalidToken)
+			},
+			wantStatus: http.StatusForbidden, wantCode: services.CodeInvalidToken,
+		},
+		{
+			name: "valid pairs with provider down", method: http.MethodGet, path: "/subscriptions/validpairs",
+			token: token,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				authenticated(m)
+				m.GetAllValidPairs(gomock.Any()).Return(nil, services.ErrUpstream)
+			},
+			wantStatus: http.StatusBadGateway, wantCode: services.CodeUpstream,
+		},
+		{
+			name: "subscription", method: http.MethodPost, path: "/subscriptions", token: token,
+			body: `{"pair": "btc_eth"}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				authenticated(m)
+				m.CreateSubscription(gomock.Any(), int64(1), "btc_eth").Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "subscription without token", method: http.MethodPost, path: "/subscriptions",
+			body:       `{"pair": "btc_eth"}`,
+			wantStatus: http.StatusUnauthorized, wantCode: services.CodeMissingToken,
+		},
+		{
+			name: "subscription with malformed body", method: http.MethodPost, path: "/subscriptions", token: token,
+			body: `{"pair": 1}`, setup: authenticated,
+			wantStatus: http.StatusBadRequest, wantCode: services.CodeMalformedRequest,
+		},
+		{
+			name: "subscription to invalid pair", method: http.MethodPost, path: "/subscriptions", token: token,
+			body: `{"pair": "xxx_yyy"}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				authenticated(m)
+				m.CreateSubscription(gomock.Any(), int64(1), "xxx_yyy").Return(services.ErrInvalidPair)
+			},
+			wantStatus: http.StatusUnprocessableEntity, wantCode: services.CodeInvalidPair,
+		},
+		{
+			name: "subscription with provider down", method: http.MethodPost, path: "/subscriptions", token: token,
+			body: `{"pair": "btc_eth"}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				authenticated(m)
+				m.CreateSubscription(gomock.Any(), int64(1), "btc_eth").Return(services.ErrUpstream)
+			},
+			wantStatus: http.StatusBadGateway, wantCode: services.CodeUpstream,
+		},
+		{
+			name: "update", method: http.MethodPatch, path: "/users/1", token: token,
+			body: `{"email": "bob@example.com"}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.Update(gomock.Any(), &services.UpdateCredentialsRequestDTO{Email: "bob@example.com", Token: token,
+					ID: 1}).Return(&services.SignUpResponseDTO{ID: 1, Email: "bob@example.com"}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "update without token", method: http.MethodPatch, path: "/users/1", body: `{}`,
+			wantStatus: http.StatusUnauthorized, wantCode: services.CodeMissingToken,
+		},
+		{
+			name: "update of invalid user id", method: http.MethodPatch, path: "/users/me", token: token,
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest, wantCode: services.CodeInvalidUserID,
+		},
+		{
+			name: "update with malformed body", method: http.MethodPatch, path: "/users/1", token: token,
+			body:       `{"email": 1}`,
+			wantStatus: http.StatusBadRequest, wantCode: services.CodeMalformedRequest,
+		},
+		{
+			name: "update of another user", method: http.MethodPatch, path: "/users/2", token: token, body: `{}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.Update(gomock.Any(), gomock.Any()).Return(nil, services.ErrAccessDenied)
+			},
+			wantStatus: http.StatusForbidden, wantCode: services.CodeAccessDenied,
+		},
+		{
+			name: "update with invalid password", method: http.MethodPatch, path: "/users/1", token: token,
+			body: `{"password": "open123"}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.Update(gomock.Any(), gomock.Any()).Return(nil, services.ErrInvalidPassword)
+			},
+			wantStatus: http.StatusUnprocessableEntity, wantCode: services.CodeInvalidPassword,
+		},
+		{
+			name: "update failing", method: http.MethodPatch, path: "/users/1", token: token, body: `{}`,
+			setup: func(m *mocks.MockUserServicerMockRecorder) {
+				m.Update(gomock.Any(), gomock.Any()).Return(nil, failure)
+			},
+			wantStatus: http.StatusInternalServerError, wantCode: services.CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			service := mocks.NewMockUserServicer(ctrl)
+			if tt.setup != nil {
+				tt.setup(service.EXPECT())
+			}
+
+			r := chi.NewRouter()
+			mountV1(r, &handlers{user: NewUsersHandler(service, testMaxBodyBytes)})
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %d; want %d, body %s", tt.method, tt.path, w.Code, tt.wantStatus, w.Body)
+			}
+
+			if tt.wantCode != "" && !strings.Contains(w.Body.String(), `"code":"`+string(tt.wantCode)+`"`) {
+				t.Errorf("%s %s body = %s; want code %s", tt.method, tt.path, w.Body, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// apiVersion is a version of the API mounted under /<Name>. A new version gets its own mount function registering
+// its handlers, which decode and return the DTOs of that version, and its own operations documenting them.
+// Handlers and DTOs unchanged from the previous version are simply registered again.
+type apiVersion struct {
+	Name string
+
+	// Mount registers the routes of the version on r.
+	Mount func(r chi.Router, h *handlers)
+
+	// Operations documents the routes registered by Mount, keyed by "<METHOD> <pattern>" relative to the version.
+	Operations map[string]operation
+
+	// Deprecated marks every route of the version with a Deprecation header. Sunset, if set, is announced too.
+	Deprecated bool
+	Sunset     time.Time
+}
+
+// legacyVersion is the version the unversioned root routes are an alias of.
+const legacyVersion = "v1"
+
+//nolint:gochecknoglobals
+var apiVersions = []apiVersion{
+	{Name: "v1", Mount: mountV1, Operations: v1Operations},
+}
+
+// handlers are the handlers the versions register their routes with.
+type handlers struct {
+	user  *UserHandler
+	admin *AdminHandler
+}
+
+func mountV1(r chi.Router, h *handlers) {
+	r.Post("/signup", h.user.SignUp)
+	r.Post("/login", h.user.Login)
+	r.Get("/secret", h.user.GetSecret)
+	r.Patch("/users/{id}", h.user.UpdateCredentials)
+
+	r.Get("/subscriptions/validpairs", h.user.GetValidPairs)
+	r.Post("/subscriptions", h.user.CreateSubscription)
+
+	r.Get("/admin/audit-events", h.admin.AuditEvents)
+	r.Get("/admin/audit-events/verify", h.admin.VerifyAuditChain)
+}
+
+// mountVersions registers every version under its prefix and, if legacyRoutes is set, the legacy version again at the
+// root. The root aliases are deprecated in favour of the versioned routes and sunset at legacySunset, if set.
+func mountVersions(r chi.Router, h *handlers, legacyRoutes bool, legacySunset time.Time) {
+	for _, v := range apiVersions {
+		v := v
+
+		r.Route("/"+v.Name, func(r chi.Router) {
+			if v.Deprecated {
+				r.Use(Deprecated(v.Sunset, ""))
+			}
+
+			v.Mount(r, h)
+		})
+
+		if legacyRoutes && v.Name == legacyVersion {
+			r.Group(func(r chi.Router) {
+				r.Use(Deprecated(legacySunset, "/"+v.Name))
+				v.Mount(r, h)
+			})
+		}
+	}
+}
+
+// Deprecated sets the Deprecation header and, if sunset is not zero, the Sunset header on every response. If
+// successorPrefix is set a Link to the same path under that prefix is added as the successor version.
+func Deprecated(sunset time.Time, successorPrefix string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+
+			if successorPrefix != "" {
+				w.Header().Add("Link", "<"+successorPrefix+r.URL.Path+`>; rel="successor-version"`)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// versionOf returns the version a route pattern belongs to and the pattern relative to it. Unversioned routes that
+// alias the legacy version are reported as such with alias set.
+func versionOf(route string) (v *apiVersion, relative string, alias bool) {
+	for i := range apiVersions {
+		prefix := "/" + apiVersions[i].Name
+
+		if strings.HasPrefix(route, prefix+"/") {
+			return &apiVersions[i], strings.TrimPrefix(route, prefix), false
+		}
+	}
+
+	for i := range apiVersions {
+		if apiVersions[i].Name == legacyVersion {
+			return &apiVersions[i], route, true
+		}
+	}
+
+	return nil, route, false
+}
@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/maknahar/alpha-flow/internal/logging"
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+// grpcCodes maps the HTTP status of an application error to the closest gRPC code.
+//
+//nolint:gochecknoglobals
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.AlreadyExists,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+	http.StatusUnprocessableEntity:   codes.InvalidArgument,
+	http.StatusBadGateway:            codes.Unavailable,
+}
+
+// grpcErrorCodes overrides grpcCodes for the application errors whose status maps to a misleading gRPC code.
+//
+//nolint:gochecknoglobals
+var grpcErrorCodes = map[services.Code]codes.Code{
+	services.CodeCredentialsChanged: codes.Aborted,
+}
+
+// toStatus converts err into a gRPC status error carrying the stable error code and field errors of the application
+// error, the same way routes.MapError does for HTTP. Unknown errors become Internal without their message.
+func toStatus(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	appErr := services.ErrInternal.Wrap(err)
+	errors.As(err, &appErr)
+
+	code, ok := grpcErrorCodes[appErr.Code]
+	if !ok {
+		code, ok = grpcCodes[appErr.Status]
+	}
+
+	if !ok {
+		code = codes.Internal
+		logging.FromContext(ctx).WithError(err).WithField("code", appErr.Code).Error("Error in handling rpc")
+	}
+
+	st := status.New(code, appErr.Message)
+
+	info := &errdetails.ErrorInfo{Reason: string(appErr.Code), Domain: "alpha-flow"}
+
+	var badRequest *errdetails.BadRequest
+
+	if len(appErr.Details) > 0 {
+		badRequest = &errdetails.BadRequest{}
+
+		for _, d := range appErr.Details {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       d.Field,
+				Description: d.Rule + ": " + d.Message,
+			})
+		}
+	}
+
+	withDetails, err := st.WithDetails(info)
+	if err == nil && badRequest != nil {
+		withDetails, err = withDetails.WithDetails(badRequest)
+	}
+
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
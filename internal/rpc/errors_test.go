@@ -0,0 +1,133 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+// details returns the ErrorInfo reason and the field violations carried by st.
+func details(st *status.Status) (reason string, violations []string) {
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			reason = d.Reason
+		case *errdetails.BadRequest:
+			for _, v := range d.FieldViolations {
+				violations = append(violations, v.Field+" "+v.Description)
+			}
+		}
+	}
+
+	return reason, violations
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantCode       codes.Code
+		wantMessage    string
+		wantReason     string
+		wantViolations []string
+	}{
+		{
+			name: "bad request", err: services.ErrMalformedRequest,
+			wantCode: codes.InvalidArgument, wantMessage: "malformed request body", wantReason: "malformed_request",
+		},
+		{
+			name: "unauthorized", err: services.ErrInvalidCredentials,
+			wantCode: codes.Unauthenticated, wantMessage: "invalid credentials", wantReason: "invalid_credentials",
+		},
+		{
+			name: "forbidden", err: services.ErrInvalidToken,
+			wantCode: codes.PermissionDenied, wantMessage: "token invalid", wantReason: "invalid_token",
+		},
+		{
+			name: "conflict", err: services.ErrAccountExists,
+			wantCode: codes.AlreadyExists, wantMessage: services.ErrAccountExists.Message, wantReason: "account_exists",
+		},
+		{
+			name: "changed meanwhile", err: services.ErrCredentialsChanged,
+			wantCode: codes.Aborted, wantMessage: services.ErrCredentialsChanged.Message,
+			wantReason: "credentials_changed",
+		},
+		{
+			name: "too large", err: services.ErrRequestTooLarge,
+			wantCode: codes.ResourceExhausted, wantMessage: "request body too large", wantReason: "request_too_large",
+		},
+		{
+			name: "upstream", err: services.ErrUpstream.Wrap(errors.New("connection refused")),
+			wantCode: codes.Unavailable, wantMessage: "upstream service unavailable",
+			wantReason: "upstream_unavailable",
+		},
+		{
+			name: "wrapped", err: fmt.Errorf("login: %w", services.ErrInvalidCredentials),
+			wantCode: codes.Unauthenticated, wantMessage: "invalid credentials", wantReason: "invalid_credentials",
+		},
+		{
+			name: "field errors",
+			err: services.ErrInvalidPassword.WithDetails(
+				services.FieldError{Field: "password", Rule: "min_length", Message: "must be at least 8 characters"},
+				services.FieldError{Field: "password", Rule: "breached", Message: "appears in a data breach"},
+			),
+			wantCode: codes.InvalidArgument, wantMessage: "validation error: password", wantReason: "invalid_password",
+			wantViolations: []string{
+				"password min_length: must be at least 8 characters",
+				"password breached: appears in a data breach",
+			},
+		},
+		{
+			name: "unknown error", err: errors.New("pq: connection reset"),
+			wantCode: codes.Internal, wantMessage: "internal server error", wantReason: "internal_error",
+		},
+		{
+			name: "internal error", err: services.ErrInternal.Wrap(errors.New("pq: connection reset")),
+			wantCode: codes.Internal, wantMessage: "internal server error", wantReason: "internal_error",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			st, ok := status.FromError(toStatus(context.Background(), tt.err))
+			if !ok {
+				t.Fatalf("toStatus(%v) is not a status error", tt.err)
+			}
+
+			if st.Code() != tt.wantCode || st.Message() != tt.wantMessage {
+				t.Errorf("toStatus(%v) = %v %q; want %v %q", tt.err, st.Code(), st.Message(), tt.wantCode,
+					tt.wantMessage)
+			}
+
+			reason, violations := details(st)
+			if reason != tt.wantReason {
+				t.Errorf("toStatus(%v) reason = %q; want %q", tt.err, reason, tt.wantReason)
+			}
+
+			if !reflect.DeepEqual(violations, tt.wantViolations) {
+				t.Errorf("toStatus(%v) violations = %q; want %q", tt.err, violations, tt.wantViolations)
+			}
+		})
+	}
+}
+
+func TestToStatusPassThrough(t *testing.T) {
+	if err := toStatus(context.Background(), nil); err != nil {
+		t.Errorf("toStatus(nil) = %v; want nil", err)
+	}
+
+	err := status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	if got := toStatus(context.Background(), err); got != err {
+		t.Errorf("toStatus(%v) = %v; want the status unchanged", err, got)
+	}
+}
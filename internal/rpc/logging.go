@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/maknahar/alpha-flow/internal/logging"
+)
+
+// callLogger puts logger in the context of every call, with the full method name, and writes an access log once the
+// call is handled. The access logs of calls not failing with a server error are sampled by method.
+type callLogger struct {
+	logger  *logrus.Logger
+	sampler *logging.Sampler
+}
+
+func (l *callLogger) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx = logging.NewContext(ctx, l.logger, l.sampler, logrus.Fields{"grpc_method": info.FullMethod})
+
+	resp, err := handler(ctx, req)
+
+	logCall(ctx, info.FullMethod, start, err)
+
+	return resp, err
+}
+
+func (l *callLogger) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx := logging.NewContext(ss.Context(), l.logger, l.sampler, logrus.Fields{"grpc_method": info.FullMethod})
+
+	err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+
+	logCall(ctx, info.FullMethod, start, err)
+
+	return err
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+
+	var entry *logrus.Entry
+
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss:
+		entry = logging.FromContext(ctx)
+	default:
+		entry = logging.Sampled(ctx, "grpc "+method)
+	}
+
+	entry.WithField("grpc_code", code.String()).WithField("duration", time.Since(start).String()).
+		Info("Handled call")
+}
@@ -0,0 +1,212 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.25.0
+// 	protoc        (unknown)
+// source: alphaflow/v1/pairs.proto
+
+package pb
+
+import (
+	proto "github.com/golang/protobuf/proto"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// This is a compile-time assertion that a sufficiently up-to-date version
+// of the legacy proto package is being used.
+const _ = proto.ProtoPackageIsVersion4
+
+type ListValidPairsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListValidPairsRequest) Reset() {
+	*x = ListValidPairsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_alphaflow_v1_pairs_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListValidPairsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListValidPairsRequest) ProtoMessage() {}
+
+func (x *ListValidPairsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_alphaflow_v1_pairs_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListValidPairsRequest.ProtoReflect.Descriptor instead.
+func (*ListValidPairsRequest) Descriptor() ([]byte, []int) {
+	return file_alphaflow_v1_pairs_proto_rawDescGZIP(), []int{0}
+}
+
+type ListValidPairsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Pairs []string `protobuf:"bytes,1,rep,name=pairs,proto3" json:"pairs,omitempty"`
+}
+
+func (x *ListValidPairsResponse) Reset() {
+	*x = ListValidPairsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_alphaflow_v1_pairs_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListValidPairsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListValidPairsResponse) ProtoMessage() {}
+
+func (x *ListValidPairsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_alphaflow_v1_pairs_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListValidPairsResponse.ProtoReflect.Descriptor instead.
+func (*ListValidPairsResponse) Descriptor() ([]byte, []int) {
+	return file_alphaflow_v1_pairs_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListValidPairsResponse) GetPairs() []string {
+	if x != nil {
+		return x.Pairs
+	}
+	return nil
+}
+
+var File_alphaflow_v1_pairs_proto protoreflect.FileDescriptor
+
+var file_alphaflow_v1_pairs_proto_rawDesc = []byte{
+	0x0a, 0x18, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x66, 0x6c, 0x6f, 0x77, 0x2f, 0x76, 0x31, 0x2f, 0x70,
+	0x61, 0x69, 0x72, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0c, 0x61, 0x6c, 0x70, 0x68,
+	0x61, 0x66, 0x6c, 0x6f, 0x77, 0x2e, 0x76, 0x31, 0x22, 0x17, 0x0a, 0x15, 0x4c, 0x69, 0x73, 0x74,
+	0x56, 0x61, 0x6c, 0x69, 0x64, 0x50, 0x61, 0x69, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x22, 0x2e, 0x0a, 0x16, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x61, 0x6c, 0x69, 0x64, 0x50, 0x61,
+	0x69, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x70,
+	0x61, 0x69, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x70, 0x61, 0x69, 0x72,
+	0x73, 0x32, 0x6a, 0x0a, 0x0b, 0x50, 0x61, 0x69, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x12, 0x5b, 0x0a, 0x0e, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x61, 0x6c, 0x69, 0x64, 0x50, 0x61, 0x69,
+	0x72, 0x73, 0x12, 0x23, 0x2e, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x66, 0x6c, 0x6f, 0x77, 0x2e, 0x76,
+	0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x61, 0x6c, 0x69, 0x64, 0x50, 0x61, 0x69, 0x72, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x24, 0x2e, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x66,
+	0x6c, 0x6f, 0x77, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x61, 0x6c, 0x69, 0x64,
+	0x50, 0x61, 0x69, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x33, 0x5a,
+	0x31, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x61, 0x6b, 0x6e,
+	0x61, 0x68, 0x61, 0x72, 0x2f, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x2d, 0x66, 0x6c, 0x6f, 0x77, 0x2f,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x72, 0x70, 0x63, 0x2f, 0x70, 0x62, 0x3b,
+	0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_alphaflow_v1_pairs_proto_rawDescOnce sync.Once
+	file_alphaflow_v1_pairs_proto_rawDescData = file_alphaflow_v1_pairs_proto_rawDesc
+)
+
+func file_alphaflow_v1_pairs_proto_rawDescGZIP() []byte {
+	file_alphaflow_v1_pairs_proto_rawDescOnce.Do(func() {
+		file_alphaflow_v1_pairs_proto_rawDescData = protoimpl.X.CompressGZIP(file_alphaflow_v1_pairs_proto_rawDescData)
+	})
+	return file_alphaflow_v1_pairs_proto_rawDescData
+}
+
+var file_alphaflow_v1_pairs_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_alphaflow_v1_pairs_proto_goTypes = []interface{}{
+	(*ListValidPairsRequest)(nil),  // 0: alphaflow.v1.ListValidPairsRequest
+	(*ListValidPairsResponse)(nil), // 1: alphaflow.v1.ListValidPairsResponse
+}
+var file_alphaflow_v1_pairs_proto_depIdxs = []int32{
+	0, // 0: alphaflow.v1.PairService.ListValidPairs:input_type -> alphaflow.v1.ListValidPairsRequest
+	1, // 1: alphaflow.v1.PairService.ListValidPairs:output_type -> alphaflow.v1.ListValidPairsResponse
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_alphaflow_v1_pairs_proto_init() }
+func file_alphaflow_v1_pairs_proto_init() {
+	if File_alphaflow_v1_pairs_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_alphaflow_v1_pairs_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListValidPairsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_alphaflow_v1_pairs_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListValidPairsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_alphaflow_v1_pairs_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_alphaflow_v1_pairs_proto_goTypes,
+		DependencyIndexes: file_alphaflow_v1_pairs_proto_depIdxs,
+		MessageInfos:      file_alphaflow_v1_pairs_proto_msgTypes,
+	}.Build()
+	File_alphaflow_v1_pairs_proto = out.File
+	file_alphaflow_v1_pairs_proto_rawDesc = nil
+	file_alphaflow_v1_pairs_proto_goTypes = nil
+	file_alphaflow_v1_pairs_proto_depIdxs = nil
+}
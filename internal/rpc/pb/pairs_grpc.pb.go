@@ -0,0 +1,97 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion7
+
+// PairServiceClient is the client API for PairService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PairServiceClient interface {
+	ListValidPairs(ctx context.Context, in *ListValidPairsRequest, opts ...grpc.CallOption) (*ListValidPairsResponse, error)
+}
+
+type pairServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPairServiceClient(cc grpc.ClientConnInterface) PairServiceClient {
+	return &pairServiceClient{cc}
+}
+
+func (c *pairServiceClient) ListValidPairs(ctx context.Context, in *ListValidPairsRequest, opts ...grpc.CallOption) (*ListValidPairsResponse, error) {
+	out := new(ListValidPairsResponse)
+	err := c.cc.Invoke(ctx, "/alphaflow.v1.PairService/ListValidPairs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PairServiceServer is the server API for PairService service.
+// All implementations must embed UnimplementedPairServiceServer
+// for forward compatibility
+type PairServiceServer interface {
+	ListValidPairs(context.Context, *ListValidPairsRequest) (*ListValidPairsResponse, error)
+	mustEmbedUnimplementedPairServiceServer()
+}
+
+// UnimplementedPairServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedPairServiceServer struct {
+}
+
+func (UnimplementedPairServiceServer) ListValidPairs(context.Context, *ListValidPairsRequest) (*ListValidPairsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListValidPairs not implemented")
+}
+func (UnimplementedPairServiceServer) mustEmbedUnimplementedPairServiceServer() {}
+
+// UnsafePairServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PairServiceServer will
+// result in compilation errors.
+type UnsafePairServiceServer interface {
+	mustEmbedUnimplementedPairServiceServer()
+}
+
+func RegisterPairServiceServer(s grpc.ServiceRegistrar, srv PairServiceServer) {
+	s.RegisterService(&_PairService_serviceDesc, srv)
+}
+
+func _PairService_ListValidPairs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListValidPairsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PairServiceServer).ListValidPairs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/alphaflow.v1.PairService/ListValidPairs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PairServiceServer).ListValidPairs(ctx, req.(*ListValidPairsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _PairService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "alphaflow.v1.PairService",
+	HandlerType: (*PairServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListValidPairs",
+			Handler:    _PairService_ListValidPairs_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "alphaflow/v1/pairs.proto",
+}
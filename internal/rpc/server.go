@@ -0,0 +1,210 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/rpc/pb"
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+// publicServices can be called without an access token.
+//
+//nolint:gochecknoglobals
+var publicServices = []string{
+	"/alphaflow.v1.AuthService/",
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+// Server serves the gRPC API on top of the same service layer as the REST API, along with the gRPC health and
+// reflection services.
+type Server struct {
+	grpc   *grpc.Server
+	health *health.Server
+}
+
+// NewServer registers every gRPC service. Calls to services other than AuthService are authenticated with the
+// "authorization: Bearer <token>" metadata entry.
+func NewServer(conf *configs.Conf) *Server {
+	service := services.NewUserService(services.UserDepsFrom(conf))
+	a := &auth{service: service}
+	l := &callLogger{logger: conf.Logger, sampler: conf.LogSampler}
+
+	s := &Server{
+		grpc: grpc.NewServer(
+			grpc.ChainUnaryInterceptor(l.unaryInterceptor, clientUnaryInterceptor, errorInterceptor,
+				a.unaryInterceptor),
+			grpc.ChainStreamInterceptor(l.streamInterceptor, clientStreamInterceptor, a.streamInterceptor),
+		),
+		health: health.NewServer(),
+	}
+
+	pb.RegisterAuthServiceServer(s.grpc, &authServer{service: service})
+	pb.RegisterUserServiceServer(s.grpc, &userServer{service: service})
+	pb.RegisterSubscriptionServiceServer(s.grpc, &subscriptionServer{service: service})
+	pb.RegisterPairServiceServer(s.grpc, &pairServer{service: service})
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	reflection.Register(s.grpc)
+
+	for name := range s.grpc.GetServiceInfo() {
+		s.health.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	return s
+}
+
+// Serve accepts connections on lis until Shutdown is called.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Drain reports every service as not serving while calls are still served, so that clients watching the health
+// service stop sending calls before Shutdown.
+func (s *Server) Drain() {
+	s.health.Shutdown()
+}
+
+// Shutdown reports every service as not serving and waits for pending calls to finish. Once ctx is done the
+// remaining calls are cancelled.
+func (s *Server) Shutdown(ctx context.Context) {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpc.Stop()
+	}
+}
+
+func errorInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+
+	return resp, toStatus(ctx, err)
+}
+
+// withClient records the address and user agent of the caller along with the audit events of the call.
+func withClient(ctx context.Context) context.Context {
+	var client services.Client
+
+	if p, ok := peer.FromContext(ctx); ok {
+		client.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client.IP); err == nil {
+			client.IP = host
+		}
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		client.UserAgent = strings.Join(md.Get("user-agent"), " ")
+	}
+
+	return services.WithClient(ctx, client)
+}
+
+func clientUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withClient(ctx), req)
+}
+
+func clientStreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: ss, ctx: withClient(ss.Context())})
+}
+
+type userKey struct{}
+
+// currentUser returns the user authenticated by the auth interceptor.
+func currentUser(ctx context.Context) *services.GetSecretResponseDTO {
+	user, _ := ctx.Value(userKey{}).(*services.GetSecretResponseDTO)
+	return user
+}
+
+type tokenKey struct{}
+
+// currentToken returns the access token the call was authenticated with.
+func currentToken(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	return token
+}
+
+type auth struct {
+	service services.UserServicer
+}
+
+func (a *auth) authenticate(ctx context.Context, method string) (context.Context, error) {
+	for _, prefix := range publicServices {
+		if strings.HasPrefix(method, prefix) {
+			return ctx, nil
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var token string
+
+	for _, v := range md.Get("authorization") {
+		if strings.HasPrefix(v, "Bearer ") {
+			token = strings.TrimPrefix(v, "Bearer ")
+		}
+	}
+
+	if token == "" {
+		return nil, services.ErrMissingToken
+	}
+
+	user, err := a.service.GetSecret(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, userKey{}, user)
+
+	return context.WithValue(ctx, tokenKey{}, token), nil
+}
+
+func (a *auth) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (a *auth) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return toStatus(ss.Context(), err)
+	}
+
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// contextStream replaces the context of a stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
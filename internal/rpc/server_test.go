@@ -0,0 +1,248 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/maknahar/alpha-flow/internal/rpc/pb"
+	"github.com/maknahar/alpha-flow/internal/services"
+	"github.com/maknahar/alpha-flow/internal/services/mocks"
+)
+
+// dial serves the gRPC services backed by service with the interceptors of NewServer, apart from call logging, and
+// returns a connection to them.
+func dial(t *testing.T, service services.UserServicer) *grpc.ClientConn {
+	t.Helper()
+
+	a := &auth{service: service}
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(clientUnaryInterceptor, errorInterceptor, a.unaryInterceptor),
+		grpc.ChainStreamInterceptor(clientStreamInterceptor, a.streamInterceptor),
+	)
+
+	pb.RegisterAuthServiceServer(s, &authServer{service: service})
+	pb.RegisterUserServiceServer(s, &userServer{service: service})
+
+	return serve(t, s)
+}
+
+// serve serves s in memory until the test ends and returns a connection to it.
+func serve(t *testing.T, s *grpc.Server) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+
+	go func() { _ = s.Serve(lis) }()
+
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithInsecure(), grpc.WithUserAgent("test"),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }))
+	if err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func TestWithClient(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want services.Client
+	}{
+		{name: "no peer", ctx: context.Background()},
+		{
+			name: "tcp peer",
+			ctx: peer.NewContext(context.Background(),
+				&peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 5000}}),
+			want: services.Client{IP: "192.0.2.1"},
+		},
+		{
+			name: "address without port",
+			ctx:  peer.NewContext(context.Background(), &peer.Peer{Addr: &net.UnixAddr{Name: "@", Net: "unix"}}),
+			want: services.Client{IP: "@"},
+		},
+		{
+			name: "user agent",
+			ctx: metadata.NewIncomingContext(context.Background(),
+				metadata.Pairs("user-agent", "grpc-go/1.34.0")),
+			want: services.Client{UserAgent: "grpc-go/1.34.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			if got := services.ClientFrom(withClient(tt.ctx)); got != tt.want {
+				t.Errorf("withClient() client = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	user := &services.GetSecretResponseDTO{ID: 1, Secret: "secret"}
+
+	tests := []struct {
+		name      string
+		method    string
+		md        metadata.MD
+		setup     func(m *mocks.MockUserServicer)
+		wantErr   error
+		wantUser  *services.GetSecretResponseDTO
+		wantToken string
+	}{
+		{name: "public service", method: "/alphaflow.v1.AuthService/Login"},
+		{name: "health", method: "/grpc.health.v1.Health/Check"},
+		{name: "missing metadata", method: "/alphaflow.v1.UserService/GetSecret", wantErr: services.ErrMissingToken},
+		{
+			name: "not a bearer token", method: "/alphaflow.v1.UserService/GetSecret",
+			md: metadata.Pairs("authorization", "Basic dXNlcjpwYXNz"), wantErr: services.ErrMissingToken,
+		},
+		{
+			name: "valid token", method: "/alphaflow.v1.UserService/GetSecret",
+			md: metadata.Pairs("authorization", "Bearer token"),
+			setup: func(m *mocks.MockUserServicer) {
+				m.EXPECT().GetSecret(gomock.Any(), "token").Return(user, nil)
+			},
+			wantUser: user, wantToken: "token",
+		},
+		{
+			name: "invalid token", method: "/alphaflow.v1.UserService/GetSecret",
+			md: metadata.Pairs("authorization", "Bearer expired"),
+			setup: func(m *mocks.MockUserServicer) {
+				m.EXPECT().GetSecret(gomock.Any(), "expired").Return(nil, services.ErrExpiredToken)
+			},
+			wantErr: services.ErrExpiredToken,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			service := mocks.NewMockUserServicer(ctrl)
+			if tt.setup != nil {
+				tt.setup(service)
+			}
+
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			ctx, err := (&auth{service: service}).authenticate(ctx, tt.method)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authenticate(%s) error = %v; want %v", tt.method, err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got := currentUser(ctx); got != tt.wantUser {
+				t.Errorf("currentUser() = %+v; want %+v", got, tt.wantUser)
+			}
+
+			if got := currentToken(ctx); got != tt.wantToken {
+				t.Errorf("currentToken() = %q; want %q", got, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mocks.NewMockUserServicer(ctrl)
+	user := &services.GetSecretResponseDTO{ID: 7, Secret: "secret"}
+
+	service.EXPECT().Login(gomock.Any(), &services.LoginRequestDTO{Email: "a@example.com", Password: "wrong"}).
+		Return(nil, services.ErrInvalidCredentials)
+	service.EXPECT().GetSecret(gomock.Any(), "token").Return(user, nil).Times(2)
+	service.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, dto *services.UpdateCredentialsRequestDTO) (*services.SignUpResponseDTO, error) {
+			// The handler sees the token it was authenticated with and the client of the call.
+			if dto.Token != "token" || dto.ID != 7 {
+				t.Errorf("Update() dto = %+v; want the token of the call", dto)
+			}
+
+			if client := services.ClientFrom(ctx); client.IP == "" || client.UserAgent == "" {
+				t.Errorf("Update() client = %+v; want the peer and user agent", client)
+			}
+
+			return nil, services.ErrInvalidPassword.WithDetails(services.FieldError{Field: "password", Rule: "min"})
+		})
+
+	conn := dial(t, service)
+	authClient := pb.NewAuthServiceClient(conn)
+	userClient := pb.NewUserServiceClient(conn)
+	authenticated := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token")
+
+	_, err := authClient.Login(context.Background(), &pb.LoginRequest{Email: "a@example.com", Password: "wrong"})
+	checkStatus(t, "Login()", err, codes.Unauthenticated, "invalid_credentials")
+
+	_, err = userClient.GetSecret(context.Background(), &pb.GetSecretRequest{})
+	checkStatus(t, "GetSecret() without token", err, codes.Unauthenticated, "missing_token")
+
+	resp, err := userClient.GetSecret(authenticated, &pb.GetSecretRequest{})
+	if err != nil || resp.UserId != 7 || resp.Secret != "secret" {
+		t.Errorf("GetSecret() = %v, %v; want the user of the token", resp, err)
+	}
+
+	_, err = userClient.UpdateCredentials(authenticated, &pb.UpdateCredentialsRequest{Id: 7, Password: "short"})
+	checkStatus(t, "UpdateCredentials()", err, codes.InvalidArgument, "invalid_password")
+}
+
+func checkStatus(t *testing.T, call string, err error, wantCode codes.Code, wantReason string) {
+	t.Helper()
+
+	st, _ := status.FromError(err)
+	if reason, _ := details(st); st.Code() != wantCode || reason != wantReason {
+		t.Errorf("%s error = %v %q; want %v %q", call, st.Code(), reason, wantCode, wantReason)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	s := &Server{grpc: grpc.NewServer(), health: health.NewServer()}
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	s.health.SetServingStatus("alphaflow.v1.UserService", healthpb.HealthCheckResponse_SERVING)
+
+	client := healthpb.NewHealthClient(serve(t, s.grpc))
+
+	for _, want := range []healthpb.HealthCheckResponse_ServingStatus{
+		healthpb.HealthCheckResponse_SERVING, healthpb.HealthCheckResponse_NOT_SERVING,
+	} {
+		if want == healthpb.HealthCheckResponse_NOT_SERVING {
+			s.Drain()
+		}
+
+		for _, service := range []string{"", "alphaflow.v1.UserService"} {
+			// Calls are still served while draining.
+			resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			if err != nil || resp.Status != want {
+				t.Errorf("Check(%q) = %v, %v; want %v", service, resp, err, want)
+			}
+		}
+	}
+}
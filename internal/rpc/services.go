@@ -0,0 +1,106 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/maknahar/alpha-flow/internal/rpc/pb"
+	"github.com/maknahar/alpha-flow/internal/services"
+)
+
+type authServer struct {
+	pb.UnimplementedAuthServiceServer
+	service services.UserServicer
+}
+
+func (s *authServer) SignUp(ctx context.Context, req *pb.SignUpRequest) (*pb.SignUpResponse, error) {
+	dto, err := s.service.SignUp(ctx, &services.SignUpRequestDTO{Email: req.Email, Password: req.Password})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.SignUpResponse{User: toUser(dto)}, nil
+}
+
+func (s *authServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	dto, err := s.service.Login(ctx, &services.LoginRequestDTO{Email: req.Email, Password: req.Password})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.LoginResponse{Token: dto.Token}, nil
+}
+
+type userServer struct {
+	pb.UnimplementedUserServiceServer
+	service services.UserServicer
+}
+
+func (s *userServer) GetSecret(ctx context.Context, _ *pb.GetSecretRequest) (*pb.GetSecretResponse, error) {
+	user := currentUser(ctx)
+
+	return &pb.GetSecretResponse{UserId: user.ID, Secret: user.Secret}, nil
+}
+
+func (s *userServer) UpdateCredentials(ctx context.Context,
+	req *pb.UpdateCredentialsRequest) (*pb.UpdateCredentialsResponse, error) {
+	dto, err := s.service.Update(ctx, &services.UpdateCredentialsRequestDTO{
+		Email:    req.Email,
+		Password: req.Password,
+		Token:    currentToken(ctx),
+		ID:       req.Id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.UpdateCredentialsResponse{User: toUser(dto)}, nil
+}
+
+type subscriptionServer struct {
+	pb.UnimplementedSubscriptionServiceServer
+	service services.UserServicer
+}
+
+func (s *subscriptionServer) CreateSubscription(ctx context.Context,
+	req *pb.CreateSubscriptionRequest) (*pb.CreateSubscriptionResponse, error) {
+	if err := s.service.CreateSubscription(ctx, currentUser(ctx).ID, req.Pair); err != nil {
+		return nil, err
+	}
+
+	return &pb.CreateSubscriptionResponse{}, nil
+}
+
+type pairServer struct {
+	pb.UnimplementedPairServiceServer
+	service services.UserServicer
+}
+
+func (s *pairServer) ListValidPairs(ctx context.Context,
+	_ *pb.ListValidPairsRequest) (*pb.ListValidPairsResponse, error) {
+	pairs, err := s.service.GetAllValidPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ListValidPairsResponse{Pairs: pairs}, nil
+}
+
+func toUser(dto *services.SignUpResponseDTO) *pb.User {
+	return &pb.User{
+		Id:        dto.ID,
+		Email:     dto.Email,
+		CreatedAt: toTimestamp(dto.CreatedAt),
+		UpdatedAt: toTimestamp(dto.UpdatedAt),
+	}
+}
+
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,387 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/logging"
+	"github.com/maknahar/alpha-flow/internal/models"
+)
+
+// Types of the audit events.
+const (
+	AuditSignUp              = "signup"
+	AuditLoginSucceeded      = "login_succeeded"
+	AuditLoginFailed         = "login_failed"
+	AuditTokenIssued         = "token_issued"
+	AuditCredentialsChanged  = "credentials_changed"
+	AuditSubscriptionCreated = "subscription_created"
+	AuditEventsQueried       = "audit_events_queried"
+	AuditChainVerified       = "audit_chain_verified"
+)
+
+const (
+	// DefaultAuditEventsLimit is the number of audit events returned when no limit is given.
+	DefaultAuditEventsLimit = 50
+
+	// MaxAuditEventsLimit is the largest number of audit events that can be requested at once.
+	MaxAuditEventsLimit = 200
+
+	// auditChainBatch is the number of chained events loaded at once while verifying the chain.
+	auditChainBatch = 500
+)
+
+// Client describes who a request was made by.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx carrying client, recorded along with the audit events of the request.
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFrom returns the client recorded in ctx by WithClient, the zero Client if none.
+func ClientFrom(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
+
+// Change is the value of a field before and after an event. Secrets are recorded as logging.Redacted, only telling
+// that they changed.
+type Change struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+//nolint:gochecknoglobals
+var (
+	secretChange  = Change{Before: logging.Redacted, After: logging.Redacted}
+	invalidCursor = FieldError{Field: "cursor", Rule: "type", Message: "is not a cursor of this API"}
+)
+
+// auditor records audit events. Failing to record an event is logged but does not fail the action, which already
+// happened.
+type auditor struct {
+	model models.AuditModel
+	chain bool
+}
+
+func newAuditor(conf *configs.Conf) auditor {
+	return auditor{model: conf.Audit, chain: conf.AuditHashChain}
+}
+
+// record stores an event of eventType done by actorID to subjectID, either being 0 if unknown.
+func (a auditor) record(ctx context.Context, eventType string, actorID, subjectID int64, changes map[string]Change) {
+	client := ClientFrom(ctx)
+
+	event := &models.AuditEvent{
+		Type:      eventType,
+		ActorID:   sqlID(actorID),
+		SubjectID: sqlID(subjectID),
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	var err error
+
+	if len(changes) > 0 {
+		event.Changes, err = json.Marshal(changes)
+	}
+
+	if err == nil {
+		err = a.model.Append(ctx, event, a.chain)
+	}
+
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithFields(logrus.Fields{"audit_type": eventType,
+			"actor_id": actorID, "subject_id": subjectID}).Error("Unable to record audit event")
+	}
+}
+
+// AuditServicer queries the audit events. Only admins may use it, and every use is audited too.
+type AuditServicer interface {
+	// Events returns a page of the events matching query, newest first.
+	Events(ctx context.Context, actorID int64, query *AuditQueryDTO) (*AuditEventsDTO, error)
+
+	// VerifyChain computes again the hash of every chained event and reports the first one not matching.
+	VerifyChain(ctx context.Context, actorID int64) (*AuditChainDTO, error)
+}
+
+type audit struct {
+	model   models.AuditModel
+	users   models.UserModel
+	admins  map[string]bool
+	auditor auditor
+}
+
+func NewAuditService(conf *configs.Conf) AuditServicer {
+	admins := make(map[string]bool, len(conf.AdminEmails))
+	for _, email := range conf.AdminEmails {
+		admins[strings.ToLower(email)] = true
+	}
+
+	return tracedAudit{next: &audit{
+		model:   conf.Audit,
+		users:   conf.Users,
+		admins:  admins,
+		auditor: newAuditor(conf),
+	}}
+}
+
+// AuditQueryDTO filters the audit events. Zero values match every event. Cursor is the NextCursor of the previous
+// page.
+type AuditQueryDTO struct {
+	Types     []string
+	ActorID   int64
+	SubjectID int64
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+	Cursor    string
+}
+
+// ParseAuditQuery reads the type, actor_id, subject_id, since, until, limit and cursor query parameters. type is a
+// comma separated list and since and until are RFC 3339 times.
+func ParseAuditQuery(values url.Values) (*AuditQueryDTO, error) {
+	query := &AuditQueryDTO{Limit: DefaultAuditEventsLimit, Cursor: values.Get("cursor")}
+
+	var details []FieldError
+
+	if types := values.Get("type"); types != "" {
+		query.Types = strings.Split(types, ",")
+	}
+
+	for _, id := range []struct {
+		name  string
+		value *int64
+	}{{"actor_id", &query.ActorID}, {"subject_id", &query.SubjectID}} {
+		if v := values.Get(id.name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 1 {
+				details = append(details, FieldError{Field: id.name, Rule: "type", Message: "must be a user id"})
+			}
+
+			*id.value = n
+		}
+	}
+
+	for _, t := range []struct {
+		name  string
+		value *time.Time
+	}{{"since", &query.Since}, {"until", &query.Until}} {
+		if v := values.Get(t.name); v != "" {
+			var err error
+
+			if *t.value, err = time.Parse(time.RFC3339, v); err != nil {
+				details = append(details, FieldError{Field: t.name, Rule: "type",
+					Message: "must be an RFC 3339 time, e.g. 2021-06-30T00:00:00Z"})
+			}
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxAuditEventsLimit {
+			details = append(details, FieldError{Field: "limit", Rule: "max",
+				Message: "must be between 1 and " + strconv.Itoa(MaxAuditEventsLimit)})
+		}
+
+		query.Limit = n
+	}
+
+	if _, err := decodeAuditCursor(query.Cursor); err != nil {
+		details = append(details, invalidCursor)
+	}
+
+	if len(details) > 0 {
+		return nil, ErrValidation.WithDetails(details...)
+	}
+
+	return query, nil
+}
+
+type AuditEventDTO struct {
+	ID         int64             `json:"id"`
+	Type       string            `json:"type"`
+	ActorID    *int64            `json:"actor_id"`
+	SubjectID  *int64            `json:"subject_id"`
+	IP         string            `json:"ip"`
+	UserAgent  string            `json:"user_agent"`
+	Changes    map[string]Change `json:"changes,omitempty"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	PrevHash   string            `json:"prev_hash,omitempty"`
+	Hash       string            `json:"hash,omitempty"`
+}
+
+type AuditEventsDTO struct {
+	Events []AuditEventDTO `json:"events"`
+
+	// NextCursor fetches the next page of older events. It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type AuditChainDTO struct {
+	// Verified is the number of chained events whose hash matched.
+	Verified int  `json:"verified"`
+	Valid    bool `json:"valid"`
+
+	// BrokenAt is the id of the first event whose hash, or link to the previous event, does not match.
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+}
+
+func (a audit) Events(ctx context.Context, actorID int64, query *AuditQueryDTO) (*AuditEventsDTO, error) {
+	if err := a.authorize(ctx, actorID); err != nil {
+		return nil, err
+	}
+
+	beforeID, err := decodeAuditCursor(query.Cursor)
+	if err != nil {
+		return nil, ErrValidation.WithDetails(invalidCursor)
+	}
+
+	a.auditor.record(ctx, AuditEventsQueried, actorID, 0, nil)
+
+	events, err := a.model.Query(ctx, models.AuditFilter{
+		Types:     query.Types,
+		ActorID:   query.ActorID,
+		SubjectID: query.SubjectID,
+		Since:     query.Since,
+		Until:     query.Until,
+		BeforeID:  beforeID,
+		Limit:     query.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AuditEventsDTO{Events: make([]AuditEventDTO, 0, len(events))}
+
+	for _, e := range events {
+		dto := AuditEventDTO{
+			ID:         e.ID,
+			Type:       e.Type,
+			IP:         e.IP,
+			UserAgent:  e.UserAgent,
+			OccurredAt: e.OccurredAt,
+			PrevHash:   e.PrevHash.String,
+			Hash:       e.Hash.String,
+		}
+
+		if e.ActorID.Valid {
+			dto.ActorID = &e.ActorID.Int64
+		}
+
+		if e.SubjectID.Valid {
+			dto.SubjectID = &e.SubjectID.Int64
+		}
+
+		if len(e.Changes) > 0 {
+			if err = json.Unmarshal(e.Changes, &dto.Changes); err != nil {
+				return nil, err
+			}
+		}
+
+		page.Events = append(page.Events, dto)
+	}
+
+	if len(events) == query.Limit {
+		page.NextCursor = encodeAuditCursor(events[len(events)-1].ID)
+	}
+
+	return page, nil
+}
+
+func (a audit) VerifyChain(ctx context.Context, actorID int64) (*AuditChainDTO, error) {
+	if err := a.authorize(ctx, actorID); err != nil {
+		return nil, err
+	}
+
+	a.auditor.record(ctx, AuditChainVerified, actorID, 0, nil)
+
+	result := &AuditChainDTO{Valid: true}
+
+	var (
+		afterID  int64
+		prevHash string
+	)
+
+	for {
+		events, err := a.model.Chained(ctx, afterID, auditChainBatch)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range events {
+			e := &events[i]
+
+			hash, err := e.ComputeHash(e.PrevHash.String)
+			if err != nil {
+				return nil, err
+			}
+
+			if e.PrevHash.String != prevHash || e.Hash.String != hash {
+				result.Valid = false
+				result.BrokenAt = &e.ID
+
+				return result, nil
+			}
+
+			result.Verified++
+			prevHash = e.Hash.String
+			afterID = e.ID
+		}
+
+		if len(events) < auditChainBatch {
+			return result, nil
+		}
+	}
+}
+
+// authorize checks that the user actorID is an admin.
+func (a audit) authorize(ctx context.Context, actorID int64) error {
+	users, err := a.users.ByIDs(ctx, []int64{actorID})
+	if err != nil {
+		return err
+	}
+
+	if len(users) == 0 || !a.admins[strings.ToLower(users[0].Email)] {
+		return ErrAccessDenied
+	}
+
+	return nil
+}
+
+func sqlID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+func encodeAuditCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// decodeAuditCursor returns the id of the last event of the previous page, 0 if cursor is empty.
+func decodeAuditCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(data), 10, 64)
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/maknahar/alpha-flow/internal/models"
+	"github.com/maknahar/alpha-flow/internal/models/mocks"
+)
+
+// chainAudit serves chained events from memory, recording the events appended apart from them.
+type chainAudit struct {
+	fakeAudit
+	chained []models.AuditEvent
+	err     error
+}
+
+func (a *chainAudit) Chained(_ context.Context, afterID int64, limit int) ([]models.AuditEvent, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	var events []models.AuditEvent
+
+	for _, e := range a.chained {
+		if e.ID > afterID && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+
+	return events, nil
+}
+
+// chain returns n events hash chained the way they are appended.
+func chain(t *testing.T, n int) []models.AuditEvent {
+	t.Helper()
+
+	events := make([]models.AuditEvent, n)
+
+	var prevHash string
+
+	for i := range events {
+		e := &events[i]
+		*e = models.AuditEvent{
+			ID:         int64(i + 1),
+			Type:       AuditLoginSucceeded,
+			ActorID:    sql.NullInt64{Int64: 1, Valid: true},
+			SubjectID:  sql.NullInt64{Int64: 1, Valid: true},
+			IP:         "192.0.2." + strconv.Itoa(i%250),
+			OccurredAt: time.Date(2021, 6, 30, 0, 0, i, 0, time.UTC),
+		}
+
+		if prevHash != "" {
+			e.PrevHash = sql.NullString{String: prevHash, Valid: true}
+		}
+
+		hash, err := e.ComputeHash(prevHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		e.Hash = sql.NullString{String: hash, Valid: true}
+		prevHash = hash
+	}
+
+	return events
+}
+
+func TestAuditVerifyChain(t *testing.T) {
+	tests := []struct {
+		name         string
+		events       []models.AuditEvent
+		modify       func(events []models.AuditEvent) []models.AuditEvent
+		err          error
+		wantVerified int
+		wantBrokenAt int64
+		wantErr      error
+	}{
+		{name: "empty chain"},
+		{name: "valid chain", events: chain(t, 3), wantVerified: 3},
+		{
+			name: "valid chain of several batches", events: chain(t, auditChainBatch+2),
+			wantVerified: auditChainBatch + 2,
+		},
+		{
+			name: "changed event", events: chain(t, 3),
+			modify: func(events []models.AuditEvent) []models.AuditEvent {
+				events[1].IP = "198.51.100.1"
+				return events
+			},
+			wantVerified: 1, wantBrokenAt: 2,
+		},
+		{
+			name: "deleted event", events: chain(t, 3),
+			modify: func(events []models.AuditEvent) []models.AuditEvent {
+				return append(events[:1], events[2:]...)
+			},
+			wantVerified: 1, wantBrokenAt: 3,
+		},
+		{
+			name: "rehashed event", events: chain(t, 3),
+			modify: func(events []models.AuditEvent) []models.AuditEvent {
+				// Changing an event along with its hash breaks the link of the next event.
+				events[1].IP = "198.51.100.1"
+				events[1].Hash.String, _ = events[1].ComputeHash(events[1].PrevHash.String)
+
+				return events
+			},
+			wantVerified: 2, wantBrokenAt: 3,
+		},
+		{
+			name: "event of another chain first", events: chain(t, 2),
+			modify: func(events []models.AuditEvent) []models.AuditEvent {
+				events[0].PrevHash = sql.NullString{String: events[1].Hash.String, Valid: true}
+				return events
+			},
+			wantBrokenAt: 1,
+		},
+		{name: "storage failure", err: errStorage, wantErr: errStorage},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			events := tt.events
+			if tt.modify != nil {
+				events = tt.modify(events)
+			}
+
+			// Admins are matched regardless of the case of their email.
+			users := mocks.NewMockUserModel(ctrl)
+			users.EXPECT().ByIDs(gomock.Any(), []int64{1}).
+				Return([]*models.UserDetails{{ID: 1, Email: "Admin@example.com"}}, nil)
+
+			model := &chainAudit{chained: events, err: tt.err}
+			service := audit{model: model, users: users, admins: map[string]bool{"admin@example.com": true},
+				auditor: auditor{model: model}}
+
+			got, err := service.VerifyChain(context.Background(), 1)
+			checkErr(t, err, tt.wantErr)
+			checkAudit(t, &model.fakeAudit, AuditChainVerified)
+
+			if err != nil {
+				return
+			}
+
+			var brokenAt int64
+			if got.BrokenAt != nil {
+				brokenAt = *got.BrokenAt
+			}
+
+			if got.Verified != tt.wantVerified || brokenAt != tt.wantBrokenAt || got.Valid != (brokenAt == 0) {
+				t.Errorf("VerifyChain() = %+v, broken at %d; want %d verified, broken at %d", got, brokenAt,
+					tt.wantVerified, tt.wantBrokenAt)
+			}
+		})
+	}
+}
+
+func TestAuditVerifyChainNotAdmin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	users := mocks.NewMockUserModel(ctrl)
+	users.EXPECT().ByIDs(gomock.Any(), []int64{2}).Return([]*models.UserDetails{{ID: 2, Email: testEmail}}, nil)
+
+	model := &chainAudit{chained: chain(t, 1)}
+	service := audit{model: model, users: users, admins: map[string]bool{"admin@example.com": true},
+		auditor: auditor{model: model}}
+
+	_, err := service.VerifyChain(context.Background(), 2)
+	checkErr(t, err, ErrAccessDenied)
+	checkAudit(t, &model.fakeAudit)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/maknahar/alpha-flow/internal/passwords"
+	"github.com/maknahar/alpha-flow/internal/utils"
+)
+
+// Code is a stable, machine readable identifier of an application error. Codes are part of the API contract and
+// must not be changed or reused.
+type Code string
+
+const (
+	CodeMalformedRequest   Code = "malformed_request"
+	CodeRequestTooLarge    Code = "request_too_large"
+	CodeValidation         Code = "validation_failed"
+	CodeInvalidEmail       Code = "invalid_email"
+	CodeInvalidPassword    Code = "invalid_password"
+	CodeInvalidUserID      Code = "invalid_user_id"
+	CodeAccountExists      Code = "account_exists"
+	CodeMissingToken       Code = "missing_token"
+	CodeInvalidToken       Code = "invalid_token"
+	CodeExpiredToken       Code = "expired_token"
+	CodeAccessDenied       Code = "access_denied"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeCredentialsChanged Code = "credentials_changed"
+	CodeInvalidPair        Code = "invalid_pair"
+	CodeInvalidQuery       Code = "invalid_query"
+	CodeQueryTooComplex    Code = "query_too_complex"
+	CodeUpstream           Code = "upstream_unavailable"
+	CodeInternal           Code = "internal_error"
+)
+
+// FieldError describes why a single field of a request was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is an application error that is safe to show to a client. Err holds the underlying cause, which is only
+// logged. Errors match each other with errors.Is by Code, so the package level errors can be compared against
+// copies carrying details.
+type Error struct {
+	Code      Code
+	Status    int
+	Message   string
+	Details   []FieldError
+	RequestID string
+	Err       error
+}
+
+func NewError(code Code, status int, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+
+	return ok && t.Code == e.Code
+}
+
+// WithDetails returns a copy of e with the field errors added.
+func (e *Error) WithDetails(details ...FieldError) *Error {
+	c := *e
+	c.Details = append(append([]FieldError(nil), e.Details...), details...)
+
+	return &c
+}
+
+// Wrap returns a copy of e caused by err.
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+
+	return &c
+}
+
+//nolint:gochecknoglobals
+var (
+	ErrMalformedRequest = NewError(CodeMalformedRequest, http.StatusBadRequest, "malformed request body")
+	ErrInvalidUserID    = NewError(CodeInvalidUserID, http.StatusBadRequest, "invalid user id")
+	ErrMissingToken     = NewError(CodeMissingToken, http.StatusUnauthorized, "token missing")
+	ErrUpstream         = NewError(CodeUpstream, http.StatusBadGateway, "upstream service unavailable")
+	ErrInternal         = NewError(CodeInternal, http.StatusInternalServerError, "internal server error")
+)
+
+// emailError converts a failed email check into ErrInvalidEmail with the failed rule as detail.
+func emailError(err error) error {
+	rule := "syntax"
+
+	switch {
+	case errors.Is(err, utils.ErrEmailNoMXRecord):
+		rule = "mx"
+	case errors.Is(err, utils.ErrEmailDisposable):
+		rule = "disposable"
+	case errors.Is(err, utils.ErrEmailDomainDeny):
+		rule = "domain"
+	}
+
+	return ErrInvalidEmail.WithDetails(FieldError{Field: "email", Rule: rule, Message: err.Error()})
+}
+
+// passwordError converts a password policy violation into ErrInvalidPassword with one detail per failed rule.
+func passwordError(err error) error {
+	var policyErr *passwords.PolicyError
+	if !errors.As(err, &policyErr) {
+		return err
+	}
+
+	details := make([]FieldError, len(policyErr.Violations))
+	for i, v := range policyErr.Violations {
+		details[i] = FieldError{Field: "password", Rule: v.Rule, Message: v.Message}
+	}
+
+	return ErrInvalidPassword.WithDetails(details...)
+}
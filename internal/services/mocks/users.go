@@ -0,0 +1,154 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/maknahar/alpha-flow/internal/services (interfaces: UserServicer)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	gomock "github.com/golang/mock/gomock"
+	services "github.com/maknahar/alpha-flow/internal/services"
+	reflect "reflect"
+)
+
+// MockUserServicer is a mock of UserServicer interface
+type MockUserServicer struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserServicerMockRecorder
+}
+
+// MockUserServicerMockRecorder is the mock recorder for MockUserServicer
+type MockUserServicerMockRecorder struct {
+	mock *MockUserServicer
+}
+
+// NewMockUserServicer creates a new mock instance
+func NewMockUserServicer(ctrl *gomock.Controller) *MockUserServicer {
+	mock := &MockUserServicer{ctrl: ctrl}
+	mock.recorder = &MockUserServicerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockUserServicer) EXPECT() *MockUserServicerMockRecorder {
+	return m.recorder
+}
+
+// CreateSubscription mocks base method
+func (m *MockUserServicer) CreateSubscription(arg0 context.Context, arg1 int64, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSubscription", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSubscription indicates an expected call of CreateSubscription
+func (mr *MockUserServicerMockRecorder) CreateSubscription(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSubscription", reflect.TypeOf((*MockUserServicer)(nil).CreateSubscription), arg0, arg1, arg2)
+}
+
+// GetAllValidPairs mocks base method
+func (m *MockUserServicer) GetAllValidPairs(arg0 context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllValidPairs", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllValidPairs indicates an expected call of GetAllValidPairs
+func (mr *MockUserServicerMockRecorder) GetAllValidPairs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllValidPairs", reflect.TypeOf((*MockUserServicer)(nil).GetAllValidPairs), arg0)
+}
+
+// GetSecret mocks base method
+func (m *MockUserServicer) GetSecret(arg0 context.Context, arg1 string) (*services.GetSecretResponseDTO, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSecret", arg0, arg1)
+	ret0, _ := ret[0].(*services.GetSecretResponseDTO)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSecret indicates an expected call of GetSecret
+func (mr *MockUserServicerMockRecorder) GetSecret(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecret", reflect.TypeOf((*MockUserServicer)(nil).GetSecret), arg0, arg1)
+}
+
+// Login mocks base method
+func (m *MockUserServicer) Login(arg0 context.Context, arg1 *services.LoginRequestDTO) (*services.LoginResponseDTO, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Login", arg0, arg1)
+	ret0, _ := ret[0].(*services.LoginResponseDTO)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Login indicates an expected call of Login
+func (mr *MockUserServicerMockRecorder) Login(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUserServicer)(nil).Login), arg0, arg1)
+}
+
+// SignUp mocks base method
+func (m *MockUserServicer) SignUp(arg0 context.Context, arg1 *services.SignUpRequestDTO) (*services.SignUpResponseDTO, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SignUp", arg0, arg1)
+	ret0, _ := ret[0].(*services.SignUpResponseDTO)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SignUp indicates an expected call of SignUp
+func (mr *MockUserServicerMockRecorder) SignUp(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignUp", reflect.TypeOf((*MockUserServicer)(nil).SignUp), arg0, arg1)
+}
+
+// Subscriptions mocks base method
+func (m *MockUserServicer) Subscriptions(arg0 context.Context, arg1 []int64) (map[int64][]services.SubscriptionDTO, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscriptions", arg0, arg1)
+	ret0, _ := ret[0].(map[int64][]services.SubscriptionDTO)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscriptions indicates an expected call of Subscriptions
+func (mr *MockUserServicerMockRecorder) Subscriptions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscriptions", reflect.TypeOf((*MockUserServicer)(nil).Subscriptions), arg0, arg1)
+}
+
+// Update mocks base method
+func (m *MockUserServicer) Update(arg0 context.Context, arg1 *services.UpdateCredentialsRequestDTO) (*services.SignUpResponseDTO, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0, arg1)
+	ret0, _ := ret[0].(*services.SignUpResponseDTO)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update
+func (mr *MockUserServicerMockRecorder) Update(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockUserServicer)(nil).Update), arg0, arg1)
+}
+
+// Users mocks base method
+func (m *MockUserServicer) Users(arg0 context.Context, arg1 []int64) (map[int64]*services.SignUpResponseDTO, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Users", arg0, arg1)
+	ret0, _ := ret[0].(map[int64]*services.SignUpResponseDTO)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Users indicates an expected call of Users
+func (mr *MockUserServicerMockRecorder) Users(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Users", reflect.TypeOf((*MockUserServicer)(nil).Users), arg0, arg1)
+}
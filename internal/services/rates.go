@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/logging"
+	"github.com/maknahar/alpha-flow/internal/models"
+)
+
+const (
+	// DefaultRateHistoryLimit is the number of rates per pair returned when no limit is given.
+	DefaultRateHistoryLimit = 10
+
+	// MaxRateHistoryLimit is the largest number of rates per pair that can be requested.
+	MaxRateHistoryLimit = 100
+)
+
+// RateServicer provides the exchange rates of pairs. Every rate fetched from the pair provider is recorded, so the
+// rate history of a pair is the series of rates observed by the service.
+type RateServicer interface {
+	// LatestRates fetches the current rates of the given pairs, keyed by pair, with a single upstream call. Pairs
+	// unknown to the provider are left out.
+	LatestRates(ctx context.Context, pairs []string) (map[string]*RateDTO, error)
+
+	// RateHistory returns up to limit most recently observed rates of every given pair, keyed by pair, newest first.
+	RateHistory(ctx context.Context, pairs []string, limit int) (map[string][]RateDTO, error)
+}
+
+type rate struct {
+	model   models.RateModel
+	runtime func() *configs.Runtime
+}
+
+func NewRateService(conf *configs.Conf) RateServicer {
+	return tracedRates{next: &rate{
+		model:   conf.Rates,
+		runtime: conf.Runtime,
+	}}
+}
+
+type RateDTO struct {
+	Pair       string    `json:"pair"`
+	Rate       float64   `json:"rate"`
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+// marketInfo is an entry of the market info of the pair provider.
+type marketInfo struct {
+	Pair string  `json:"pair"`
+	Rate float64 `json:"rate"`
+}
+
+func (r rate) LatestRates(ctx context.Context, pairs []string) (map[string]*RateDTO, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.runtime().PairProviderURL+"/marketinfo", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := pairProvider.Do(req)
+	if err != nil {
+		return nil, ErrUpstream.Wrap(err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, ErrUpstream.Wrap(fmt.Errorf("unexpected status %d from pair provider", res.StatusCode))
+	}
+
+	var markets []marketInfo
+
+	if err = json.NewDecoder(res.Body).Decode(&markets); err != nil {
+		return nil, ErrUpstream.Wrap(err)
+	}
+
+	requested := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		requested[strings.ToLower(p)] = p
+	}
+
+	now := time.Now().UTC()
+	latest := make(map[string]*RateDTO, len(pairs))
+	observed := make([]models.Rate, 0, len(pairs))
+
+	for _, m := range markets {
+		pair, ok := requested[strings.ToLower(m.Pair)]
+		if !ok {
+			continue
+		}
+
+		latest[pair] = &RateDTO{Pair: pair, Rate: m.Rate, ObservedAt: now}
+		observed = append(observed, models.Rate{Pair: pair, Rate: m.Rate, ObservedAt: now})
+	}
+
+	// The rates are valid even if they could not be added to the history.
+	if err = r.model.Record(ctx, observed); err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("Unable to record rates")
+	}
+
+	return latest, nil
+}
+
+func (r rate) RateHistory(ctx context.Context, pairs []string, limit int) (map[string][]RateDTO, error) {
+	if limit < 1 || limit > MaxRateHistoryLimit {
+		return nil, ErrValidation.WithDetails(FieldError{Field: "limit", Rule: "max",
+			Message: "must be between 1 and " + strconv.Itoa(MaxRateHistoryLimit)})
+	}
+
+	history, err := r.model.History(ctx, pairs, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	byPair := make(map[string][]RateDTO, len(pairs))
+	for _, p := range pairs {
+		byPair[p] = []RateDTO{}
+	}
+
+	for _, h := range history {
+		byPair[h.Pair] = append(byPair[h.Pair], RateDTO{Pair: h.Pair, Rate: h.Rate, ObservedAt: h.ObservedAt})
+	}
+
+	return byPair, nil
+}
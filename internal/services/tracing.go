@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+
+	"github.com/maknahar/alpha-flow/internal/tracing"
+)
+
+// tracedUsers records a span for every call of the user service.
+type tracedUsers struct {
+	next UserServicer
+}
+
+func (t tracedUsers) SignUp(ctx context.Context, dto *SignUpRequestDTO) (res *SignUpResponseDTO, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.SignUp")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.SignUp(ctx, dto)
+}
+
+func (t tracedUsers) Login(ctx context.Context, dto *LoginRequestDTO) (res *LoginResponseDTO, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Login")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Login(ctx, dto)
+}
+
+func (t tracedUsers) GetSecret(ctx context.Context, token string) (res *GetSecretResponseDTO, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetSecret")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetSecret(ctx, token)
+}
+
+func (t tracedUsers) Update(ctx context.Context, dto *UpdateCredentialsRequestDTO) (res *SignUpResponseDTO,
+	err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Update")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Update(ctx, dto)
+}
+
+func (t tracedUsers) GetAllValidPairs(ctx context.Context) (res []string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetAllValidPairs")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetAllValidPairs(ctx)
+}
+
+func (t tracedUsers) CreateSubscription(ctx context.Context, userID int64, pair string) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.CreateSubscription")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.CreateSubscription(ctx, userID, pair)
+}
+
+func (t tracedUsers) Users(ctx context.Context, ids []int64) (res map[int64]*SignUpResponseDTO, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Users")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Users(ctx, ids)
+}
+
+func (t tracedUsers) Subscriptions(ctx context.Context, userIDs []int64) (res map[int64][]SubscriptionDTO,
+	err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Subscriptions")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Subscriptions(ctx, userIDs)
+}
+
+// tracedRates records a span for every call of the rate service.
+type tracedRates struct {
+	next RateServicer
+}
+
+func (t tracedRates) LatestRates(ctx context.Context, pairs []string) (res map[string]*RateDTO, err error) {
+	ctx, span := tracing.Start(ctx, "RateService.LatestRates")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.LatestRates(ctx, pairs)
+}
+
+func (t tracedRates) RateHistory(ctx context.Context, pairs []string, limit int) (res map[string][]RateDTO,
+	err error) {
+	ctx, span := tracing.Start(ctx, "RateService.RateHistory")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.RateHistory(ctx, pairs, limit)
+}
+
+// tracedAudit records a span for every call of the audit service.
+type tracedAudit struct {
+	next AuditServicer
+}
+
+func (t tracedAudit) Events(ctx context.Context, actorID int64, query *AuditQueryDTO) (res *AuditEventsDTO,
+	err error) {
+	ctx, span := tracing.Start(ctx, "AuditService.Events")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Events(ctx, actorID, query)
+}
+
+func (t tracedAudit) VerifyChain(ctx context.Context, actorID int64) (res *AuditChainDTO, err error) {
+	ctx, span := tracing.Start(ctx, "AuditService.VerifyChain")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.VerifyChain(ctx, actorID)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/tracing"
+)
+
+func TestPairProviderTraceContext(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), tracing.ExporterNone, "", 1); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	previous := otel.GetTracerProvider()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.AlwaysSample()})))
+	defer otel.SetTracerProvider(previous)
+
+	var traceparent string
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer provider.Close()
+
+	runtime := &configs.Runtime{PairProviderURL: provider.URL}
+	service := user{runtime: func() *configs.Runtime { return runtime }}
+
+	ctx, span := tracing.Start(context.Background(), "test")
+	defer span.End()
+
+	if _, err := service.GetAllValidPairs(ctx); err != nil {
+		t.Fatalf("GetAllValidPairs() error = %v", err)
+	}
+
+	// The call is traced as part of the trace of the request.
+	if traceID := span.SpanContext().TraceID.String(); !strings.Contains(traceparent, "-"+traceID+"-") {
+		t.Errorf("traceparent = %q; want the trace %s", traceparent, traceID)
+	}
+}
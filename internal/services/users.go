@@ -5,29 +5,81 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
+	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/logging"
+	"github.com/maknahar/alpha-flow/internal/metrics"
 	"github.com/maknahar/alpha-flow/internal/models"
+	"github.com/maknahar/alpha-flow/internal/passwords"
+	"github.com/maknahar/alpha-flow/internal/tracing"
 	"github.com/maknahar/alpha-flow/internal/utils"
 )
 
+//nolint:gochecknoglobals
 var (
-	ErrInvalidEmail       = errors.New("validation error: email")
-	ErrInvalidPassword    = errors.New("validation error: password")
-	ErrAccountExists      = errors.New("validation error: account with given email already exists")
-	ErrInvalidToken       = errors.New("token invalid")
-	ErrAccessDenied       = errors.New("access denied")
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrExpiredToken       = errors.New("token expired")
-	ErrInvalidPair        = errors.New("invalid pair")
+	ErrInvalidEmail    = NewError(CodeInvalidEmail, http.StatusUnprocessableEntity, "validation error: email")
+	ErrInvalidPassword = NewError(CodeInvalidPassword, http.StatusUnprocessableEntity, "validation error: password")
+	ErrAccountExists   = NewError(CodeAccountExists, http.StatusConflict,
+		"validation error: account with given email already exists")
+	ErrInvalidToken       = NewError(CodeInvalidToken, http.StatusForbidden, "token invalid")
+	ErrAccessDenied       = NewError(CodeAccessDenied, http.StatusForbidden, "access denied")
+	ErrInvalidCredentials = NewError(CodeInvalidCredentials, http.StatusUnauthorized, "invalid credentials")
+	ErrExpiredToken       = NewError(CodeExpiredToken, http.StatusForbidden, "token expired")
+	ErrInvalidPair        = NewError(CodeInvalidPair, http.StatusUnprocessableEntity, "invalid pair")
+	ErrCredentialsChanged = NewError(CodeCredentialsChanged, http.StatusConflict,
+		"credentials changed by another request, try again")
+
+	// errHashChanged tells that the password hash of a login changed after it was verified.
+	errHashChanged = errors.New("password hash changed since verified")
 )
 
-var (
-	ShareShiftHostURL = "https://shapeshift.io"
-)
+// loginAttempts is how many times a login verifies the password at most, when the hash changes after it is verified,
+// e.g. upgraded by a concurrent login.
+const loginAttempts = 2
+
+// pairProvider is the client used for every call to the pair provider.
+//
+//nolint:gochecknoglobals
+var pairProvider = &http.Client{
+	Transport: tracing.Transport(metrics.Transport("pair_provider", http.DefaultTransport)),
+}
+
+// PingPairProvider returns a check that the pair provider of conf is reachable and not failing.
+func PingPairProvider(conf *configs.Conf) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return pingPairProvider(ctx, conf.Runtime().PairProviderURL)
+	}
+}
+
+func pingPairProvider(ctx context.Context, providerURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, providerURL+"/validpairs", nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := pairProvider.Do(req)
+	if err != nil {
+		return err
+	}
 
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status %d from pair provider", res.StatusCode)
+	}
+
+	return nil
+}
+
+//go:generate go run github.com/golang/mock/mockgen -destination=mocks/users.go -package=mocks . UserServicer
 type UserServicer interface {
 	SignUp(ctx context.Context, dto *SignUpRequestDTO) (*SignUpResponseDTO, error)
 	Login(ctx context.Context, dto *LoginRequestDTO) (*LoginResponseDTO, error)
@@ -35,18 +87,100 @@ type UserServicer interface {
 	Update(ctx context.Context, dto *UpdateCredentialsRequestDTO) (*SignUpResponseDTO, error)
 	GetAllValidPairs(ctx context.Context) ([]string, error)
 	CreateSubscription(ctx context.Context, userId int64, pair string) error
+
+	// Users returns the users with given ids, keyed by id, loading all of them at once.
+	Users(ctx context.Context, ids []int64) (map[int64]*SignUpResponseDTO, error)
+
+	// Subscriptions returns the subscriptions of the given users, keyed by user id, loading all of them at once.
+	Subscriptions(ctx context.Context, userIDs []int64) (map[int64][]SubscriptionDTO, error)
 }
 
 type user struct {
-	model models.UserModel
+	model   models.UserModel
+	uow     models.UnitOfWork
+	tx      models.TxOptions
+	hasher  passwords.Hasher
+	runtime func() *configs.Runtime
+	audit   auditor
+	dummy   *dummyHash
 }
 
-func NewUserService(db *sql.DB) UserServicer {
-	return &user{model: models.NewUser(db)}
+// dummyHash is a hash of the current hasher that logins of unknown emails are verified against, so that they take as
+// long as those of registered emails and response times do not reveal which emails are registered.
+type dummyHash struct {
+	once   sync.Once
+	hasher passwords.Hasher
+	hash   string
+}
+
+// verify verifies password against the dummy hash, computed on first use.
+func (d *dummyHash) verify(password string) {
+	d.once.Do(func() {
+		d.hash, _ = d.hasher.Hash("dummy password")
+	})
+
+	_, _ = d.hasher.Verify(password, d.hash)
+}
+
+// UserDeps are what the user service runs on. They are interfaces, so that the service can be tested without a
+// database.
+type UserDeps struct {
+	Users      models.UserModel
+	UnitOfWork models.UnitOfWork
+	Tx         models.TxOptions
+	Hasher     passwords.Hasher
+	Audit      models.AuditModel
+	AuditChain bool
+
+	// Runtime returns the current runtime configuration, read on every call so that reloads apply.
+	Runtime func() *configs.Runtime
+}
+
+// UserDepsFrom returns the dependencies of the user service configured by conf.
+func UserDepsFrom(conf *configs.Conf) UserDeps {
+	return UserDeps{
+		Users:      conf.Users,
+		UnitOfWork: conf.UnitOfWork,
+		Tx:         conf.TxOptions,
+		Hasher:     conf.PasswordHasher,
+		Audit:      conf.Audit,
+		AuditChain: conf.AuditHashChain,
+		Runtime:    conf.Runtime,
+	}
+}
+
+func NewUserService(deps UserDeps) UserServicer {
+	return tracedUsers{next: &user{
+		model:   deps.Users,
+		uow:     deps.UnitOfWork,
+		tx:      deps.Tx,
+		hasher:  deps.Hasher,
+		runtime: deps.Runtime,
+		audit:   auditor{model: deps.Audit, chain: deps.AuditChain},
+		dummy:   &dummyHash{hasher: deps.Hasher},
+	}}
 }
 
 type SignUpRequestDTO struct {
-	LoginRequestDTO
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// Validate checks the email against emails and that the password satisfies every rule of policy.
+func (s *SignUpRequestDTO) Validate(ctx context.Context, emails *utils.EmailValidator, policy *passwords.Policy) error {
+	var emailErr, passwordErr error
+
+	if s.Email != "" {
+		if err := emails.Validate(ctx, s.Email); err != nil {
+			emailErr = emailError(err)
+		}
+	}
+
+	if s.Password != "" {
+		passwordErr = passwordError(policy.Check(s.Password))
+	}
+
+	return joinErrors(Validate(s), emailErr, passwordErr)
 }
 
 type SignUpResponseDTO struct {
@@ -57,20 +191,45 @@ type SignUpResponseDTO struct {
 }
 
 func (u user) SignUp(ctx context.Context, dto *SignUpRequestDTO) (*SignUpResponseDTO, error) {
-	if err := dto.Validate(); err != nil {
+	runtime := u.runtime()
+
+	if err := dto.Validate(ctx, runtime.EmailValidator, runtime.PasswordPolicy); err != nil {
 		return nil, err
 	}
 
-	_, err := u.model.ByEmail(ctx, dto.Email)
-	if !errors.Is(err, sql.ErrNoRows) {
-		return nil, ErrAccountExists
+	passwordHash, err := u.hasher.Hash(dto.Password)
+	if err != nil {
+		return nil, err
 	}
 
-	userDetails, err := u.model.Create(ctx, dto.Email, dto.Password)
+	var userDetails *models.UserDetails
+
+	// Create updates the user of an existing email, which has to be checked for in the same transaction.
+	err = u.uow.Do(ctx, u.tx, func(ctx context.Context, tx models.Tx) error {
+		// Other errors are returned as they are, so that serialization failures are retried.
+		_, err := tx.Users.ByEmail(ctx, dto.Email)
+		if err == nil {
+			return ErrAccountExists
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		userDetails, err = tx.Users.Create(ctx, dto.Email, passwordHash)
+
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	metrics.SignUps.Inc()
+	u.audit.record(ctx, AuditSignUp, userDetails.ID, userDetails.ID, map[string]Change{
+		"email":    {After: userDetails.Email},
+		"password": {After: logging.Redacted},
+	})
+
 	response := &SignUpResponseDTO{
 		ID:        userDetails.ID,
 		Email:     userDetails.Email,
@@ -85,20 +244,14 @@ func (u user) SignUp(ctx context.Context, dto *SignUpRequestDTO) (*SignUpRespons
 }
 
 type LoginRequestDTO struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
 }
 
+// Validate checks the shape of the credentials only. Neither the email checks nor the password policy are applied, as
+// they may have changed since the account was created.
 func (s *LoginRequestDTO) Validate() error {
-	if !utils.IsEmailValid(s.Email) {
-		return ErrInvalidEmail
-	}
-
-	if len(s.Password) < 8 {
-		return ErrInvalidPassword
-	}
-
-	return nil
+	return Validate(s)
 }
 
 type LoginResponseDTO struct {
@@ -110,20 +263,116 @@ func (u user) Login(ctx context.Context, dto *LoginRequestDTO) (*LoginResponseDT
 		return nil, err
 	}
 
-	userDetails, err := u.model.Login(ctx, dto.Email, dto.Password)
+	var (
+		id           int64
+		passwordHash string
+		userDetails  *models.UserDetails
+		err          error
+	)
+
+	// The password is verified before the transaction, which only issues the token if the hash verified is still the
+	// current one.
+	for attempt := 1; ; attempt++ {
+		id, passwordHash, err = u.verifyPassword(ctx, dto.Email, dto.Password)
+		if err == nil {
+			err = u.uow.Do(ctx, u.tx, func(ctx context.Context, tx models.Tx) error {
+				currentID, current, err := tx.Users.PasswordHash(ctx, dto.Email)
+				if errors.Is(err, sql.ErrNoRows) {
+					return ErrInvalidCredentials
+				} else if err != nil {
+					return err
+				}
+
+				if currentID != id || current != passwordHash {
+					return errHashChanged
+				}
+
+				userDetails, err = tx.Users.IssueToken(ctx, id)
+
+				return err
+			})
+		}
+
+		if !errors.Is(err, errHashChanged) || attempt == loginAttempts {
+			break
+		}
+	}
+
+	if errors.Is(err, errHashChanged) {
+		err = ErrInvalidCredentials
+	}
+
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrInvalidCredentials
+		if errors.Is(err, ErrInvalidCredentials) {
+			// The email tells which account was targeted, even one that does not exist.
+			metrics.Logins.WithLabelValues("failure").Inc()
+			u.audit.record(ctx, AuditLoginFailed, 0, id, map[string]Change{
+				"email": {After: strings.ToLower(strings.TrimSpace(dto.Email))},
+			})
 		}
 
 		return nil, err
 	}
 
+	u.rehash(ctx, id, dto.Email, dto.Password, passwordHash)
+
+	metrics.Logins.WithLabelValues("success").Inc()
+	u.audit.record(ctx, AuditLoginSucceeded, id, id, nil)
+	u.audit.record(ctx, AuditTokenIssued, id, id, nil)
+
 	return &LoginResponseDTO{
 		Token: userDetails.Token.String,
 	}, nil
 }
 
+// verifyPassword returns the id and the password hash of the user with email, along with ErrInvalidCredentials
+// unless password matches the hash. Unknown emails are verified against the dummy hash.
+func (u user) verifyPassword(ctx context.Context, email, password string) (int64, string, error) {
+	id, passwordHash, err := u.model.PasswordHash(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		u.dummy.verify(password)
+		return 0, "", ErrInvalidCredentials
+	} else if err != nil {
+		return 0, "", err
+	}
+
+	ok, err := u.hasher.Verify(password, passwordHash)
+	if err != nil {
+		return id, passwordHash, err
+	}
+
+	if !ok {
+		return id, passwordHash, ErrInvalidCredentials
+	}
+
+	return id, passwordHash, nil
+}
+
+// rehash upgrades a verified password hash to the current algorithm and parameters, if it is still the current hash
+// of the user with email. It runs in a transaction of its own after the token is issued, as failing to upgrade must
+// not fail the login: the existing hash is still valid.
+func (u user) rehash(ctx context.Context, id int64, email, password, passwordHash string) {
+	if !u.hasher.NeedsRehash(passwordHash) {
+		return
+	}
+
+	newHash, err := u.hasher.Hash(password)
+	if err == nil {
+		err = u.uow.Do(ctx, u.tx, func(ctx context.Context, tx models.Tx) error {
+			currentID, current, err := tx.Users.PasswordHash(ctx, email)
+			if err != nil || currentID != id || current != passwordHash {
+				return err
+			}
+
+			return tx.Users.SetPasswordHash(ctx, id, newHash)
+		})
+	}
+
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("user_id", id).Warn("Unable to upgrade password hash")
+	}
+}
+
 type GetSecretResponseDTO struct {
 	ID     int64  `json:"user_id"`
 	Secret string `json:"secret"`
@@ -139,53 +388,139 @@ func (u user) GetSecret(ctx context.Context, token string) (*GetSecretResponseDT
 		return nil, err
 	}
 
-	if userDetails.TokenCreationTime.Before(time.Now().Add(-time.Minute * 60)) {
+	if userDetails.TokenCreationTime.Before(time.Now().Add(-u.runtime().AccessTokenValidityDuration)) {
 		return nil, ErrExpiredToken
 	}
 
+	// Every transport authenticates through here, so the rest of the request is logged with its user.
+	logging.AddFields(ctx, logrus.Fields{"user_id": userDetails.ID})
+
 	return &GetSecretResponseDTO{
 		ID:     userDetails.ID,
 		Secret: userDetails.Secret.String,
 	}, nil
 }
 
+// UpdateCredentialsRequestDTO holds the fields a user can change. Token and ID are set by the server from the
+// request and never bound from the body.
 type UpdateCredentialsRequestDTO struct {
-	LoginRequestDTO
-	Token string
-	ID    int64
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Token    string `json:"-"`
+	ID       int64  `json:"-"`
 }
 
-func (u *UpdateCredentialsRequestDTO) Validate() error {
-	if u.Email != "" && !utils.IsEmailValid(u.Email) {
-		return ErrInvalidEmail
+// Validate checks the fields being changed. previousHashes are the recent password hashes of the user that must
+// not be reused.
+func (u *UpdateCredentialsRequestDTO) Validate(ctx context.Context, emails *utils.EmailValidator,
+	policy *passwords.Policy, previousHashes ...string) error {
+	var emailErr, passwordErr error
+
+	if u.Email != "" {
+		if err := emails.Validate(ctx, u.Email); err != nil {
+			emailErr = emailError(err)
+		}
 	}
 
-	if u.Password != "" && len(u.Password) < 8 {
-		return ErrInvalidPassword
+	if u.Password != "" {
+		passwordErr = passwordError(policy.Check(u.Password, previousHashes...))
 	}
 
-	return nil
+	return joinErrors(emailErr, passwordErr)
 }
 
 func (u user) Update(ctx context.Context, dto *UpdateCredentialsRequestDTO) (*SignUpResponseDTO, error) {
-	if err := dto.Validate(); err != nil {
+	userInfo, err := u.GetSecret(ctx, dto.Token)
+	if err != nil {
 		return nil, err
 	}
 
-	_, err := u.GetSecret(ctx, dto.Token)
-	if err != nil {
+	if userInfo.ID != dto.ID {
+		return nil, ErrAccessDenied
+	}
+
+	runtime := u.runtime()
+
+	// The checks, including the MX lookup, and the hashing run before the transaction, which only writes the changes
+	// if the password hash the history was read with is still the current one.
+	var (
+		previousHashes []string
+		passwordHash   string
+	)
+
+	if dto.Password != "" {
+		previousHashes, err = u.model.PasswordHistory(ctx, dto.ID, runtime.PasswordPolicy.HistorySize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = dto.Validate(ctx, runtime.EmailValidator, runtime.PasswordPolicy, previousHashes...); err != nil {
 		return nil, err
 	}
 
-	userDetails, err := u.model.ChangeCredentials(ctx, dto.Email, dto.Password, dto.Token, dto.ID)
-	if err != nil {
+	if dto.Password != "" {
+		passwordHash, err = u.hasher.Hash(dto.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		previousEmail string
+		userDetails   *models.UserDetails
+	)
+
+	err = u.uow.Do(ctx, u.tx, func(ctx context.Context, tx models.Tx) error {
+		if len(previousHashes) > 0 {
+			current, err := tx.Users.PasswordHistory(ctx, dto.ID, 1)
+			if err != nil {
+				return err
+			}
+
+			if len(current) > 0 && current[0] != previousHashes[0] {
+				return ErrCredentialsChanged
+			}
+		}
+
+		if dto.Email != "" {
+			previous, err := tx.Users.ByIDs(ctx, []int64{dto.ID})
+			if err != nil {
+				return err
+			}
+
+			if len(previous) > 0 {
+				previousEmail = previous[0].Email
+			}
+		}
+
+		var err error
+
+		userDetails, err = tx.Users.ChangeCredentials(ctx, dto.Email, passwordHash, dto.Token, dto.ID)
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrAccessDenied
+			return ErrAccessDenied
 		}
 
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	changes := make(map[string]Change)
+
+	if dto.Email != "" && dto.Email != previousEmail {
+		changes["email"] = Change{Before: previousEmail, After: userDetails.Email}
+	}
+
+	if dto.Password != "" {
+		changes["password"] = secretChange
+	}
+
+	if len(changes) > 0 {
+		u.audit.record(ctx, AuditCredentialsChanged, userInfo.ID, dto.ID, changes)
+	}
+
 	response := &SignUpResponseDTO{
 		ID:        userDetails.ID,
 		Email:     userDetails.Email,
@@ -197,31 +532,48 @@ func (u user) Update(ctx context.Context, dto *UpdateCredentialsRequestDTO) (*Si
 }
 
 func (u user) GetAllValidPairs(ctx context.Context) (validPairs []string, err error) {
-	res, err := http.Get(ShareShiftHostURL + "/validpairs")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.runtime().PairProviderURL+"/validpairs", nil)
 	if err != nil {
 		return nil, err
 	}
 
+	res, err := pairProvider.Do(req)
+	if err != nil {
+		return nil, ErrUpstream.Wrap(err)
+	}
+
 	defer res.Body.Close()
 
+	if res.StatusCode != http.StatusOK {
+		return nil, ErrUpstream.Wrap(fmt.Errorf("unexpected status %d from pair provider", res.StatusCode))
+	}
+
 	data, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		return nil, ErrUpstream.Wrap(err)
 	}
 
 	err = json.Unmarshal(data, &validPairs)
 	if err != nil {
-		return nil, err
+		return nil, ErrUpstream.Wrap(err)
 	}
 
 	return validPairs, nil
 }
 
 type CreateSubscriptionDTO struct {
-	Pair string `json:"pair"`
+	Pair string `json:"pair" validate:"required,max=32"`
+}
+
+func (c *CreateSubscriptionDTO) Validate() error {
+	return Validate(c)
 }
 
 func (u user) CreateSubscription(ctx context.Context, userID int64, pair string) error {
+	if err := (&CreateSubscriptionDTO{Pair: pair}).Validate(); err != nil {
+		return err
+	}
+
 	pairs, err := u.GetAllValidPairs(ctx)
 	if err != nil {
 		return err
@@ -240,5 +592,62 @@ func (u user) CreateSubscription(ctx context.Context, userID int64, pair string)
 		return ErrInvalidPair
 	}
 
-	return u.model.CreateSubscription(ctx, userID, pair)
+	created, err := u.model.CreateSubscription(ctx, userID, pair)
+	if err != nil {
+		return err
+	}
+
+	// Subscribing again to the same pair changes nothing, so there is nothing to count or audit.
+	if created {
+		metrics.SubscriptionsCreated.Inc()
+		u.audit.record(ctx, AuditSubscriptionCreated, userID, userID, map[string]Change{"pair": {After: pair}})
+	}
+
+	return nil
+}
+
+func (u user) Users(ctx context.Context, ids []int64) (map[int64]*SignUpResponseDTO, error) {
+	details, err := u.model.ByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[int64]*SignUpResponseDTO, len(details))
+
+	for _, d := range details {
+		users[d.ID] = &SignUpResponseDTO{
+			ID:        d.ID,
+			Email:     d.Email,
+			CreatedAt: d.CreatedAt,
+		}
+
+		if d.UpdatedAt.Valid {
+			users[d.ID].UpdatedAt = &d.UpdatedAt.Time
+		}
+	}
+
+	return users, nil
+}
+
+type SubscriptionDTO struct {
+	Pair      string    `json:"pair"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (u user) Subscriptions(ctx context.Context, userIDs []int64) (map[int64][]SubscriptionDTO, error) {
+	subscriptions, err := u.model.Subscriptions(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[int64][]SubscriptionDTO, len(userIDs))
+	for _, id := range userIDs {
+		byUser[id] = []SubscriptionDTO{}
+	}
+
+	for _, s := range subscriptions {
+		byUser[s.UserID] = append(byUser[s.UserID], SubscriptionDTO{Pair: s.Pair, CreatedAt: s.CreatedAt})
+	}
+
+	return byUser, nil
 }
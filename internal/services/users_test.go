@@ -0,0 +1,828 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/metrics"
+	"github.com/maknahar/alpha-flow/internal/models"
+	"github.com/maknahar/alpha-flow/internal/models/mocks"
+	"github.com/maknahar/alpha-flow/internal/passwords"
+	"github.com/maknahar/alpha-flow/internal/utils"
+)
+
+//nolint:gochecknoglobals
+var (
+	// testHasher is the cheapest hasher, so that the tests stay fast.
+	testHasher = passwords.NewHasher(passwords.NewBcrypt(4))
+
+	errStorage = errors.New("storage failure")
+)
+
+const (
+	testEmail    = "alice@example.com"
+	testPassword = "open1234"
+	testToken    = "token"
+)
+
+// fakeUnitOfWork runs units of work on users directly, without a transaction.
+type fakeUnitOfWork struct {
+	users models.UserModel
+}
+
+func (w fakeUnitOfWork) Do(ctx context.Context, _ models.TxOptions,
+	fn func(ctx context.Context, tx models.Tx) error) error {
+	return fn(ctx, models.Tx{Users: w.users})
+}
+
+// fakeAudit records the events appended and their types.
+type fakeAudit struct {
+	types  []string
+	events []*models.AuditEvent
+}
+
+func (a *fakeAudit) Append(_ context.Context, event *models.AuditEvent, _ bool) error {
+	a.types = append(a.types, event.Type)
+	a.events = append(a.events, event)
+
+	return nil
+}
+
+func (a *fakeAudit) Query(context.Context, models.AuditFilter) ([]models.AuditEvent, error) {
+	return nil, nil
+}
+
+func (a *fakeAudit) Chained(context.Context, int64, int) ([]models.AuditEvent, error) {
+	return nil, nil
+}
+
+// userTest is a user service on a mocked UserModel, with a fake pair provider answering status and body.
+type userTest struct {
+	users   *mocks.MockUserModel
+	audit   *fakeAudit
+	service UserServicer
+}
+
+func newUserTest(t *testing.T, status int, body string) userTest {
+	t.Helper()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(provider.Close)
+
+	runtime := &configs.Runtime{
+		AccessTokenValidityDuration: time.Hour,
+		PasswordPolicy:              &passwords.Policy{MinLength: 8, MaxLength: 64, HistorySize: 2, Hasher: testHasher},
+		EmailValidator:              utils.NewEmailValidator(),
+		PairProviderURL:             provider.URL,
+	}
+
+	ut := userTest{users: mocks.NewMockUserModel(ctrl), audit: &fakeAudit{}}
+	ut.service = NewUserService(UserDeps{
+		Users:      ut.users,
+		UnitOfWork: fakeUnitOfWork{users: ut.users},
+		Hasher:     testHasher,
+		Audit:      ut.audit,
+		Runtime:    func() *configs.Runtime { return runtime },
+	})
+
+	return ut
+}
+
+func mustHash(t *testing.T, hasher passwords.Hasher, password string) string {
+	t.Helper()
+
+	hash, err := hasher.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	return hash
+}
+
+// checkErr fails t unless err is want, or nil if want is.
+func checkErr(t *testing.T, err, want error) {
+	t.Helper()
+
+	if (want == nil && err != nil) || !errors.Is(err, want) {
+		t.Errorf("error = %v; want %v", err, want)
+	}
+}
+
+// checkAudit fails t unless the events recorded are of the want types.
+func checkAudit(t *testing.T, audit *fakeAudit, want ...string) {
+	t.Helper()
+
+	if len(audit.types)+len(want) > 0 && !reflect.DeepEqual(audit.types, want) {
+		t.Errorf("audit events = %q; want %q", audit.types, want)
+	}
+}
+
+// validToken is the details of the user 1 owning a fresh token.
+func validToken() *models.UserDetails {
+	return &models.UserDetails{ID: 1, Email: testEmail, TokenCreationTime: time.Now(),
+		Secret: sql.NullString{String: "secret", Valid: true}}
+}
+
+func TestUserSignUp(t *testing.T) {
+	tests := []struct {
+		name      string
+		dto       SignUpRequestDTO
+		setup     func(m *mocks.MockUserModelMockRecorder)
+		wantErr   error
+		wantAudit []string
+	}{
+		{
+			name: "created",
+			dto:  SignUpRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.ByEmail(gomock.Any(), testEmail).Return(nil, sql.ErrNoRows)
+				m.Create(gomock.Any(), testEmail, gomock.Any()).
+					Return(&models.UserDetails{ID: 1, Email: testEmail}, nil)
+			},
+			wantAudit: []string{AuditSignUp},
+		},
+		{
+			name:    "missing credentials",
+			wantErr: ErrValidation,
+		},
+		{
+			name:    "invalid email",
+			dto:     SignUpRequestDTO{Email: "x@x", Password: testPassword},
+			wantErr: ErrInvalidEmail,
+		},
+		{
+			name:    "weak password",
+			dto:     SignUpRequestDTO{Email: testEmail, Password: "open123"},
+			wantErr: ErrInvalidPassword,
+		},
+		{
+			name: "account exists",
+			dto:  SignUpRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.ByEmail(gomock.Any(), testEmail).Return(&models.UserDetails{ID: 1}, nil)
+			},
+			wantErr: ErrAccountExists,
+		},
+		{
+			// Returned as is, so that the unit of work retries a serialization failure.
+			name: "lookup failure",
+			dto:  SignUpRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.ByEmail(gomock.Any(), testEmail).Return(nil, errStorage)
+			},
+			wantErr: errStorage,
+		},
+		{
+			name: "storage failure",
+			dto:  SignUpRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.ByEmail(gomock.Any(), testEmail).Return(nil, sql.ErrNoRows)
+				m.Create(gomock.Any(), testEmail, gomock.Any()).Return(nil, errStorage)
+			},
+			wantErr: errStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ut := newUserTest(t, http.StatusOK, "")
+			if tt.setup != nil {
+				tt.setup(ut.users.EXPECT())
+			}
+
+			res, err := ut.service.SignUp(context.Background(), &tt.dto)
+			checkErr(t, err, tt.wantErr)
+
+			if tt.wantErr == nil && (res == nil || res.ID != 1 || res.Email != testEmail) {
+				t.Errorf("SignUp() = %+v; want user 1", res)
+			}
+
+			checkAudit(t, ut.audit, tt.wantAudit...)
+		})
+	}
+}
+
+func TestUserLogin(t *testing.T) {
+	hash := mustHash(t, testHasher, testPassword)
+	legacyHash := mustHash(t, passwords.NewBcrypt(5), testPassword)
+	issued := &models.UserDetails{ID: 1, Token: sql.NullString{String: testToken, Valid: true}}
+
+	tests := []struct {
+		name      string
+		dto       LoginRequestDTO
+		setup     func(m *mocks.MockUserModelMockRecorder)
+		wantErr   error
+		wantAudit []string
+	}{
+		{
+			name: "logged in",
+			dto:  LoginRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				// Read to be verified, then again in the transaction issuing the token.
+				m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), hash, nil).Times(2)
+				m.IssueToken(gomock.Any(), int64(1)).Return(issued, nil)
+			},
+			wantAudit: []string{AuditLoginSucceeded, AuditTokenIssued},
+		},
+		{
+			name: "outdated hash upgraded",
+			dto:  LoginRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), legacyHash, nil).Times(3)
+				m.IssueToken(gomock.Any(), int64(1)).Return(issued, nil)
+				m.SetPasswordHash(gomock.Any(), int64(1), gomock.Any()).Return(nil)
+			},
+			wantAudit: []string{AuditLoginSucceeded, AuditTokenIssued},
+		},
+		{
+			name: "failed upgrade ignored",
+			dto:  LoginRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), legacyHash, nil).Times(3)
+				m.IssueToken(gomock.Any(), int64(1)).Return(issued, nil)
+				m.SetPasswordHash(gomock.Any(), int64(1), gomock.Any()).Return(errStorage)
+			},
+			wantAudit: []string{AuditLoginSucceeded, AuditTokenIssued},
+		},
+		{
+			name: "hash upgraded by another login before the upgrade",
+			dto:  LoginRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				gomock.InOrder(
+					m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), legacyHash, nil).Times(2),
+					m.IssueToken(gomock.Any(), int64(1)).Return(issued, nil),
+					m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), hash, nil),
+				)
+			},
+			wantAudit: []string{AuditLoginSucceeded, AuditTokenIssued},
+		},
+		{
+			name: "hash upgraded by another login before the token",
+			dto:  LoginRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				// The password is verified again against the new hash.
+				gomock.InOrder(
+					m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), legacyHash, nil),
+					m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), hash, nil).Times(3),
+					m.IssueToken(gomock.Any(), int64(1)).Return(issued, nil),
+				)
+			},
+			wantAudit: []string{AuditLoginSucceeded, AuditTokenIssued},
+		},
+		{
+			name: "password changed before the token",
+			dto:  LoginRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				changed := mustHash(t, testHasher, "changed1")
+
+				gomock.InOrder(
+					m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), hash, nil),
+					m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), changed, nil).Times(2),
+				)
+			},
+			wantErr:   ErrInvalidCredentials,
+			wantAudit: []string{AuditLoginFailed},
+		},
+		{
+			name: "email changed before the token",
+			dto:  LoginRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				gomock.InOrder(
+					m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), hash, nil),
+					m.PasswordHash(gomock.Any(), testEmail).Return(int64(0), "", sql.ErrNoRows),
+				)
+			},
+			wantErr:   ErrInvalidCredentials,
+			wantAudit: []string{AuditLoginFailed},
+		},
+		{
+			name:    "invalid email",
+			dto:     LoginRequestDTO{Email: "alice", Password: testPassword},
+			wantErr: ErrInvalidEmail,
+		},
+		{
+			name: "unknown email",
+			dto:  LoginRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.PasswordHash(gomock.Any(), testEmail).Return(int64(0), "", sql.ErrNoRows)
+			},
+			wantErr:   ErrInvalidCredentials,
+			wantAudit: []string{AuditLoginFailed},
+		},
+		{
+			name: "wrong password",
+			dto:  LoginRequestDTO{Email: testEmail, Password: "wrong password"},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), hash, nil)
+			},
+			wantErr:   ErrInvalidCredentials,
+			wantAudit: []string{AuditLoginFailed},
+		},
+		{
+			name: "storage failure",
+			dto:  LoginRequestDTO{Email: testEmail, Password: testPassword},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), hash, nil).Times(2)
+				m.IssueToken(gomock.Any(), int64(1)).Return(nil, errStorage)
+			},
+			wantErr: errStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ut := newUserTest(t, http.StatusOK, "")
+			if tt.setup != nil {
+				tt.setup(ut.users.EXPECT())
+			}
+
+			res, err := ut.service.Login(context.Background(), &tt.dto)
+			checkErr(t, err, tt.wantErr)
+
+			if tt.wantErr == nil && (res == nil || res.Token != testToken) {
+				t.Errorf("Login() = %+v; want the issued token", res)
+			}
+
+			checkAudit(t, ut.audit, tt.wantAudit...)
+		})
+	}
+}
+
+// countingHasher counts the verifications of the hasher it wraps.
+type countingHasher struct {
+	passwords.Hasher
+
+	verified int
+}
+
+func (h *countingHasher) Verify(password, encoded string) (bool, error) {
+	h.verified++
+	return h.Hasher.Verify(password, encoded)
+}
+
+func TestUserLoginUnknownEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	users := mocks.NewMockUserModel(ctrl)
+	users.EXPECT().PasswordHash(gomock.Any(), gomock.Any()).Return(int64(0), "", sql.ErrNoRows)
+
+	hasher := &countingHasher{Hasher: testHasher}
+	audit := &fakeAudit{}
+	service := NewUserService(UserDeps{
+		Users:      users,
+		UnitOfWork: fakeUnitOfWork{users: users},
+		Hasher:     hasher,
+		Audit:      audit,
+		Runtime:    func() *configs.Runtime { return &configs.Runtime{} },
+	})
+
+	_, err := service.Login(context.Background(),
+		&LoginRequestDTO{Email: strings.ToUpper(testEmail), Password: testPassword})
+	checkErr(t, err, ErrInvalidCredentials)
+
+	// The password is verified all the same, so that unknown emails cannot be told apart by response time.
+	if hasher.verified != 1 {
+		t.Errorf("Verify() called %d times; want 1", hasher.verified)
+	}
+
+	// Without a user, the attempted email is what tells the failures against an account apart.
+	checkAudit(t, audit, AuditLoginFailed)
+
+	if event := audit.events[0]; event.ActorID.Valid || event.SubjectID.Valid ||
+		string(event.Changes) != `{"email":{"after":"`+testEmail+`"}}` {
+		t.Errorf("audit event = %+v, changes %s; want no user and the email %s", event, event.Changes, testEmail)
+	}
+}
+
+func TestUserGetSecret(t *testing.T) {
+	expired := validToken()
+	expired.TokenCreationTime = time.Now().Add(-2 * time.Hour)
+
+	tests := []struct {
+		name    string
+		details *models.UserDetails
+		err     error
+		wantErr error
+	}{
+		{name: "valid token", details: validToken()},
+		{name: "unknown token", err: sql.ErrNoRows, wantErr: ErrInvalidToken},
+		{name: "expired token", details: expired, wantErr: ErrExpiredToken},
+		{name: "storage failure", err: errStorage, wantErr: errStorage},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ut := newUserTest(t, http.StatusOK, "")
+			ut.users.EXPECT().GetDetails(gomock.Any(), testToken).Return(tt.details, tt.err)
+
+			res, err := ut.service.GetSecret(context.Background(), testToken)
+			checkErr(t, err, tt.wantErr)
+
+			if tt.wantErr == nil && (res == nil || res.ID != 1 || res.Secret != "secret") {
+				t.Errorf("GetSecret() = %+v; want the secret of user 1", res)
+			}
+		})
+	}
+}
+
+func TestUserUpdate(t *testing.T) {
+	previous := mustHash(t, testHasher, "previous1")
+	changed := &models.UserDetails{ID: 1, Email: "bob@example.com",
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+
+	tests := []struct {
+		name      string
+		dto       UpdateCredentialsRequestDTO
+		setup     func(m *mocks.MockUserModelMockRecorder)
+		wantErr   error
+		wantAudit []string
+	}{
+		{
+			name: "email changed",
+			dto:  UpdateCredentialsRequestDTO{Email: "bob@example.com", ID: 1},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.ByIDs(gomock.Any(), []int64{1}).Return([]*models.UserDetails{{ID: 1, Email: testEmail}}, nil)
+				m.ChangeCredentials(gomock.Any(), "bob@example.com", "", testToken, int64(1)).Return(changed, nil)
+			},
+			wantAudit: []string{AuditCredentialsChanged},
+		},
+		{
+			name: "password changed",
+			dto:  UpdateCredentialsRequestDTO{Password: "1234open", ID: 1},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.PasswordHistory(gomock.Any(), int64(1), 2).Return([]string{previous}, nil)
+				m.PasswordHistory(gomock.Any(), int64(1), 1).Return([]string{previous}, nil)
+				m.ChangeCredentials(gomock.Any(), "", gomock.Not(""), testToken, int64(1)).Return(changed, nil)
+			},
+			wantAudit: []string{AuditCredentialsChanged},
+		},
+		{
+			name: "password changed by another request",
+			dto:  UpdateCredentialsRequestDTO{Password: "1234open", ID: 1},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.PasswordHistory(gomock.Any(), int64(1), 2).Return([]string{previous}, nil)
+				m.PasswordHistory(gomock.Any(), int64(1), 1).Return([]string{"other"}, nil)
+			},
+			wantErr: ErrCredentialsChanged,
+		},
+		{
+			name: "nothing changed",
+			dto:  UpdateCredentialsRequestDTO{ID: 1},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.ChangeCredentials(gomock.Any(), "", "", testToken, int64(1)).Return(changed, nil)
+			},
+		},
+		{
+			name:    "other user",
+			dto:     UpdateCredentialsRequestDTO{Email: "bob@example.com", ID: 2},
+			wantErr: ErrAccessDenied,
+		},
+		{
+			name:    "invalid email",
+			dto:     UpdateCredentialsRequestDTO{Email: "x@x", ID: 1},
+			wantErr: ErrInvalidEmail,
+		},
+		{
+			name: "reused password",
+			dto:  UpdateCredentialsRequestDTO{Password: "previous1", ID: 1},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.PasswordHistory(gomock.Any(), int64(1), 2).Return([]string{previous}, nil)
+			},
+			wantErr: ErrInvalidPassword,
+		},
+		{
+			name: "token revoked meanwhile",
+			dto:  UpdateCredentialsRequestDTO{Email: "bob@example.com", ID: 1},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.ByIDs(gomock.Any(), []int64{1}).Return([]*models.UserDetails{{ID: 1, Email: testEmail}}, nil)
+				m.ChangeCredentials(gomock.Any(), "bob@example.com", "", testToken, int64(1)).
+					Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrAccessDenied,
+		},
+		{
+			name: "storage failure",
+			dto:  UpdateCredentialsRequestDTO{Password: "1234open", ID: 1},
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.PasswordHistory(gomock.Any(), int64(1), 2).Return(nil, errStorage)
+			},
+			wantErr: errStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ut := newUserTest(t, http.StatusOK, "")
+			ut.users.EXPECT().GetDetails(gomock.Any(), testToken).Return(validToken(), nil)
+
+			if tt.setup != nil {
+				tt.setup(ut.users.EXPECT())
+			}
+
+			tt.dto.Token = testToken
+
+			res, err := ut.service.Update(context.Background(), &tt.dto)
+			checkErr(t, err, tt.wantErr)
+
+			if tt.wantErr == nil && (res == nil || res.Email != changed.Email || res.UpdatedAt == nil) {
+				t.Errorf("Update() = %+v; want the changed user", res)
+			}
+
+			checkAudit(t, ut.audit, tt.wantAudit...)
+		})
+	}
+}
+
+func TestUserUpdateInvalidToken(t *testing.T) {
+	ut := newUserTest(t, http.StatusOK, "")
+	ut.users.EXPECT().GetDetails(gomock.Any(), testToken).Return(nil, sql.ErrNoRows)
+
+	_, err := ut.service.Update(context.Background(), &UpdateCredentialsRequestDTO{Email: testEmail, Token: testToken,
+		ID: 1})
+	checkErr(t, err, ErrInvalidToken)
+}
+
+// txTracker tracks whether a unit of work is running, failing the test if a hash, a verification or a check of an
+// email runs inside of it: they are slow, and would hold the transaction and its connection open meanwhile.
+type txTracker struct {
+	passwords.Hasher
+
+	t     *testing.T
+	users models.UserModel
+	inTx  bool
+}
+
+func (w *txTracker) Do(ctx context.Context, _ models.TxOptions,
+	fn func(ctx context.Context, tx models.Tx) error) error {
+	w.inTx = true
+	defer func() { w.inTx = false }()
+
+	return fn(ctx, models.Tx{Users: w.users})
+}
+
+func (w *txTracker) outside(what string) {
+	if w.inTx {
+		w.t.Errorf("%s called inside a transaction", what)
+	}
+}
+
+func (w *txTracker) Hash(password string) (string, error) {
+	w.outside("Hash()")
+	return w.Hasher.Hash(password)
+}
+
+func (w *txTracker) Verify(password, encoded string) (bool, error) {
+	w.outside("Verify()")
+	return w.Hasher.Verify(password, encoded)
+}
+
+func (w *txTracker) Check(context.Context, string) error {
+	w.outside("Check()")
+	return nil
+}
+
+func TestUserSlowWorkOutsideTransactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	legacyHash := mustHash(t, passwords.NewBcrypt(5), testPassword)
+	previous := mustHash(t, testHasher, "previous1")
+
+	users := mocks.NewMockUserModel(ctrl)
+	m := users.EXPECT()
+	m.PasswordHash(gomock.Any(), testEmail).Return(int64(1), legacyHash, nil).Times(3)
+	m.IssueToken(gomock.Any(), int64(1)).
+		Return(&models.UserDetails{ID: 1, Token: sql.NullString{String: testToken, Valid: true}}, nil)
+	m.SetPasswordHash(gomock.Any(), int64(1), gomock.Any()).Return(nil)
+	m.GetDetails(gomock.Any(), testToken).Return(validToken(), nil)
+	m.PasswordHistory(gomock.Any(), int64(1), gomock.Any()).Return([]string{previous}, nil).Times(2)
+	m.ByIDs(gomock.Any(), []int64{1}).Return([]*models.UserDetails{{ID: 1, Email: testEmail}}, nil)
+	m.ChangeCredentials(gomock.Any(), "bob@example.com", gomock.Not(""), testToken, int64(1)).
+		Return(&models.UserDetails{ID: 1, Email: "bob@example.com"}, nil)
+
+	tracker := &txTracker{t: t, users: users}
+	tracker.Hasher = testHasher
+	runtime := &configs.Runtime{
+		AccessTokenValidityDuration: time.Hour,
+		PasswordPolicy:              &passwords.Policy{MinLength: 8, MaxLength: 64, HistorySize: 2, Hasher: tracker},
+		EmailValidator:              utils.NewEmailValidator(tracker),
+	}
+	service := NewUserService(UserDeps{
+		Users:      users,
+		UnitOfWork: tracker,
+		Hasher:     tracker,
+		Audit:      &fakeAudit{},
+		Runtime:    func() *configs.Runtime { return runtime },
+	})
+
+	_, err := service.Login(context.Background(), &LoginRequestDTO{Email: testEmail, Password: testPassword})
+	checkErr(t, err, nil)
+
+	_, err = service.Update(context.Background(), &UpdateCredentialsRequestDTO{Email: "bob@example.com",
+		Password: "1234open", Token: testToken, ID: 1})
+	checkErr(t, err, nil)
+}
+
+func TestUserGetAllValidPairs(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    []string
+		wantErr error
+	}{
+		{name: "pairs", status: http.StatusOK, body: `["btc_eth", "eth_ltc"]`, want: []string{"btc_eth", "eth_ltc"}},
+		{name: "provider failure", status: http.StatusInternalServerError, wantErr: ErrUpstream},
+		{name: "invalid response", status: http.StatusOK, body: `{"pairs": []}`, wantErr: ErrUpstream},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ut := newUserTest(t, tt.status, tt.body)
+
+			got, err := ut.service.GetAllValidPairs(context.Background())
+			checkErr(t, err, tt.wantErr)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetAllValidPairs() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserCreateSubscription(t *testing.T) {
+	tests := []struct {
+		name      string
+		pair      string
+		status    int
+		setup     func(m *mocks.MockUserModelMockRecorder)
+		wantErr   error
+		wantAudit []string
+
+		// wantCreated is the number of subscriptions counted as created.
+		wantCreated float64
+	}{
+		{
+			name:   "subscribed",
+			pair:   "btc_eth",
+			status: http.StatusOK,
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.CreateSubscription(gomock.Any(), int64(1), "btc_eth").Return(true, nil)
+			},
+			wantAudit:   []string{AuditSubscriptionCreated},
+			wantCreated: 1,
+		},
+		{
+			name:   "already subscribed",
+			pair:   "btc_eth",
+			status: http.StatusOK,
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.CreateSubscription(gomock.Any(), int64(1), "btc_eth").Return(false, nil)
+			},
+		},
+		{name: "missing pair", status: http.StatusOK, wantErr: ErrInvalidPair},
+		{name: "unknown pair", pair: "xxx_yyy", status: http.StatusOK, wantErr: ErrInvalidPair},
+		{name: "provider failure", pair: "btc_eth", status: http.StatusBadGateway, wantErr: ErrUpstream},
+		{
+			name:   "storage failure",
+			pair:   "btc_eth",
+			status: http.StatusOK,
+			setup: func(m *mocks.MockUserModelMockRecorder) {
+				m.CreateSubscription(gomock.Any(), int64(1), "btc_eth").Return(false, errStorage)
+			},
+			wantErr: errStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ut := newUserTest(t, tt.status, `["btc_eth"]`)
+			if tt.setup != nil {
+				tt.setup(ut.users.EXPECT())
+			}
+
+			before := testutil.ToFloat64(metrics.SubscriptionsCreated)
+
+			err := ut.service.CreateSubscription(context.Background(), 1, tt.pair)
+			checkErr(t, err, tt.wantErr)
+			checkAudit(t, ut.audit, tt.wantAudit...)
+
+			if got := testutil.ToFloat64(metrics.SubscriptionsCreated) - before; got != tt.wantCreated {
+				t.Errorf("SubscriptionsCreated increased by %v; want %v", got, tt.wantCreated)
+			}
+		})
+	}
+}
+
+func TestUserUsers(t *testing.T) {
+	created := time.Now()
+	updated := sql.NullTime{Time: created.Add(time.Hour), Valid: true}
+
+	tests := []struct {
+		name    string
+		details []*models.UserDetails
+		err     error
+		want    map[int64]*SignUpResponseDTO
+		wantErr error
+	}{
+		{
+			name: "users",
+			details: []*models.UserDetails{
+				{ID: 1, Email: testEmail, CreatedAt: &created},
+				{ID: 2, Email: "bob@example.com", CreatedAt: &created, UpdatedAt: updated},
+			},
+			want: map[int64]*SignUpResponseDTO{
+				1: {ID: 1, Email: testEmail, CreatedAt: &created},
+				2: {ID: 2, Email: "bob@example.com", CreatedAt: &created, UpdatedAt: &updated.Time},
+			},
+		},
+		{name: "storage failure", err: errStorage, wantErr: errStorage},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ut := newUserTest(t, http.StatusOK, "")
+			ut.users.EXPECT().ByIDs(gomock.Any(), []int64{1, 2}).Return(tt.details, tt.err)
+
+			got, err := ut.service.Users(context.Background(), []int64{1, 2})
+			checkErr(t, err, tt.wantErr)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Users() = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserSubscriptions(t *testing.T) {
+	created := time.Now()
+
+	tests := []struct {
+		name          string
+		subscriptions []models.Subscription
+		err           error
+		want          map[int64][]SubscriptionDTO
+		wantErr       error
+	}{
+		{
+			name: "subscriptions",
+			subscriptions: []models.Subscription{
+				{UserID: 1, Pair: "btc_eth", CreatedAt: created},
+				{UserID: 1, Pair: "eth_ltc", CreatedAt: created},
+			},
+			want: map[int64][]SubscriptionDTO{
+				1: {{Pair: "btc_eth", CreatedAt: created}, {Pair: "eth_ltc", CreatedAt: created}},
+				2: {},
+			},
+		},
+		{name: "storage failure", err: errStorage, wantErr: errStorage},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ut := newUserTest(t, http.StatusOK, "")
+			ut.users.EXPECT().Subscriptions(gomock.Any(), []int64{1, 2}).Return(tt.subscriptions, tt.err)
+
+			got, err := ut.service.Subscriptions(context.Background(), []int64{1, 2})
+			checkErr(t, err, tt.wantErr)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Subscriptions() = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
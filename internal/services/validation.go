@@ -0,0 +1,374 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/maknahar/alpha-flow/internal/utils"
+)
+
+const (
+	// DefaultMaxBodyBytes is the largest request body DecodeJSON accepts by default.
+	DefaultMaxBodyBytes = 1 << 20
+)
+
+//nolint:gochecknoglobals
+var (
+	ErrValidation      = NewError(CodeValidation, http.StatusUnprocessableEntity, "validation error")
+	ErrRequestTooLarge = NewError(CodeRequestTooLarge, http.StatusRequestEntityTooLarge, "request body too large")
+)
+
+// fieldErrors maps a field to the error reported when it is the only field failing validation.
+//
+//nolint:gochecknoglobals
+var fieldErrors = map[string]*Error{
+	"email":    ErrInvalidEmail,
+	"password": ErrInvalidPassword,
+	"pair":     ErrInvalidPair,
+}
+
+// DecodeJSON strictly decodes a single JSON object from r into dst. Bodies larger than limit, unknown fields,
+// fields of the wrong type and trailing data are rejected with field level details where possible.
+func DecodeJSON(r io.Reader, limit int64, dst interface{}) error {
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+
+	lr := &limitedReader{r: r, n: limit}
+
+	data, err := ioutil.ReadAll(lr)
+	if err != nil {
+		if lr.exceeded {
+			return ErrRequestTooLarge.Wrap(err)
+		}
+
+		return ErrMalformedRequest.Wrap(err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(dst)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after JSON object")
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+
+	if errors.As(err, &typeErr) {
+		return ErrMalformedRequest.Wrap(err).WithDetails(FieldError{Field: typeErr.Field, Rule: "type",
+			Message: "must be of type " + typeErr.Type.String()})
+	}
+
+	if field := unknownField(data, dst); field != "" {
+		return ErrMalformedRequest.Wrap(err).WithDetails(FieldError{Field: field, Rule: "unknown",
+			Message: "is not a known field"})
+	}
+
+	return ErrMalformedRequest.Wrap(err)
+}
+
+// unknownField returns the first, in sorted order, of the fields of the JSON object data that dst has no field for,
+// empty if none or if data is not an object.
+func unknownField(data []byte, dst interface{}) string {
+	var object map[string]json.RawMessage
+	if json.Unmarshal(data, &object) != nil {
+		return ""
+	}
+
+	t := reflect.TypeOf(dst)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return ""
+	}
+
+	known := jsonFields(t)
+
+	var unknown []string
+
+	for name := range object {
+		// Like encoding/json, field names are matched case insensitively.
+		if _, ok := known[strings.ToLower(name)]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return ""
+	}
+
+	sort.Strings(unknown)
+
+	return unknown[0]
+}
+
+// jsonFields returns the lower cased JSON names of the fields of the struct t, including embedded structs.
+func jsonFields(t reflect.Type) map[string]struct{} {
+	fields := make(map[string]struct{})
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		switch {
+		case name == "-":
+			continue
+		case field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct:
+			for embedded := range jsonFields(field.Type) {
+				fields[embedded] = struct{}{}
+			}
+
+			continue
+		case field.PkgPath != "":
+			continue
+		case name == "":
+			name = field.Name
+		}
+
+		fields[strings.ToLower(name)] = struct{}{}
+	}
+
+	return fields
+}
+
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// A body of exactly the limit is accepted, only a byte past it is too large.
+		var probe [1]byte
+
+		n, err := l.r.Read(probe[:])
+		if n == 0 {
+			return 0, err
+		}
+
+		l.exceeded = true
+
+		return 0, ErrRequestTooLarge
+	}
+
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+
+	return n, err
+}
+
+// Validate checks the `validate` struct tag rules of every field of the struct v points to, including embedded
+// structs, and reports all failures at once. Supported comma separated rules:
+//
+//	required  the field must not be the zero value
+//	min=N     strings must have at least N characters, numbers must be at least N
+//	max=N     strings must have at most N characters, numbers must be at most N
+//	email     the string must be a syntactically valid email address
+//
+// Rules other than required are skipped for zero values. Fields are reported by their JSON name. Invalid rules are
+// reported as an error of their own, which CheckRules finds without a value to validate.
+func Validate(v interface{}) error {
+	details, err := validateStruct(reflect.Indirect(reflect.ValueOf(v)))
+	if err != nil {
+		return err
+	}
+
+	return fieldsError(details)
+}
+
+// CheckRules returns an error for the first invalid `validate` rule of the struct v points to: an unknown rule, a
+// missing or non numeric argument of min and max, or email on a field other than a string.
+func CheckRules(v interface{}) error {
+	_, err := validateStruct(reflect.Zero(reflect.Indirect(reflect.ValueOf(v)).Type()))
+	return err
+}
+
+func validateStruct(v reflect.Value) ([]FieldError, error) {
+	var details []FieldError
+
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+
+		if field.Anonymous && value.Kind() == reflect.Struct {
+			embedded, err := validateStruct(value)
+			if err != nil {
+				return nil, err
+			}
+
+			details = append(details, embedded...)
+
+			continue
+		}
+
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+
+		for _, rule := range strings.Split(tag, ",") {
+			r, err := parseRule(rule, value.Kind())
+			if err != nil {
+				return nil, fmt.Errorf("%w; field %s of %s", err, field.Name, t)
+			}
+
+			if d, ok := r.check(name, value); !ok {
+				details = append(details, d)
+			}
+		}
+	}
+
+	return details, nil
+}
+
+// rule is a parsed `validate` rule.
+type rule struct {
+	name  string
+	arg   string
+	limit float64
+}
+
+// parseRule parses a rule of a field of kind.
+func parseRule(s string, kind reflect.Kind) (rule, error) {
+	r := rule{}
+	r.name, r.arg = SplitRule(s)
+
+	switch r.name {
+	case "required":
+	case "email":
+		if kind != reflect.String {
+			return r, fmt.Errorf("validate: rule %q on a %s", r.name, kind)
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(r.arg, 64)
+		if err != nil {
+			return r, fmt.Errorf("validate: invalid argument %q of rule %q", r.arg, r.name)
+		}
+
+		r.limit = limit
+
+		return r, nil
+	default:
+		return r, fmt.Errorf("validate: unknown rule %q", r.name)
+	}
+
+	if r.arg != "" {
+		return r, fmt.Errorf("validate: rule %q takes no argument", r.name)
+	}
+
+	return r, nil
+}
+
+// check reports whether value of the field name satisfies the rule, and the failure otherwise.
+func (r rule) check(name string, value reflect.Value) (FieldError, bool) {
+	if value.IsZero() {
+		return FieldError{Field: name, Rule: r.name, Message: "is required"}, r.name != "required"
+	}
+
+	var size float64
+
+	switch value.Kind() {
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(value.String()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = value.Float()
+	case reflect.Slice, reflect.Map:
+		size = float64(value.Len())
+	}
+
+	switch r.name {
+	case "min":
+		return FieldError{Field: name, Rule: r.name, Message: "must be at least " + r.arg}, size >= r.limit
+	case "max":
+		return FieldError{Field: name, Rule: r.name, Message: "must be at most " + r.arg}, size <= r.limit
+	case "email":
+		return FieldError{Field: name, Rule: "syntax", Message: utils.ErrEmailSyntax.Error()},
+			utils.IsEmailSyntaxValid(value.String())
+	default:
+		return FieldError{}, true
+	}
+}
+
+// SplitRule splits a `validate` rule into its name and argument, empty if none.
+func SplitRule(rule string) (name, arg string) {
+	parts := strings.SplitN(strings.TrimSpace(rule), "=", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+
+	return parts[0], ""
+}
+
+// joinErrors combines the validation errors of several checks into one. Details of all application errors are
+// merged; any other error is returned as is.
+func joinErrors(errs ...error) error {
+	var details []FieldError
+
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		var appErr *Error
+		if !errors.As(err, &appErr) || len(appErr.Details) == 0 {
+			return err
+		}
+
+		details = append(details, appErr.Details...)
+	}
+
+	return fieldsError(details)
+}
+
+// fieldsError returns nil without details, the field's own error if all details are about a single field with one,
+// or ErrValidation.
+func fieldsError(details []FieldError) error {
+	if len(details) == 0 {
+		return nil
+	}
+
+	for _, d := range details[1:] {
+		if d.Field != details[0].Field {
+			return ErrValidation.WithDetails(details...)
+		}
+	}
+
+	if err, ok := fieldErrors[details[0].Field]; ok {
+		return err.WithDetails(details...)
+	}
+
+	return ErrValidation.WithDetails(details...)
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestRequestRules checks the `validate` rules of every request, which would otherwise only fail on the first
+// request validated.
+func TestRequestRules(t *testing.T) {
+	for _, dto := range []interface{}{
+		&SignUpRequestDTO{},
+		&LoginRequestDTO{},
+		&UpdateCredentialsRequestDTO{},
+		&CreateSubscriptionDTO{},
+		&AuditQueryDTO{},
+	} {
+		if err := CheckRules(dto); err != nil {
+			t.Errorf("CheckRules(%T) error = %v", dto, err)
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		dto     interface{}
+		wantErr string
+	}{
+		{name: "valid", dto: &struct {
+			Name  string `validate:"required,min=1,max=2.5"`
+			Email string `validate:" required , email"`
+		}{}},
+		{name: "unknown rule", dto: &struct {
+			Name string `validate:"requird"`
+		}{}, wantErr: `unknown rule "requird"`},
+		{name: "missing argument", dto: &struct {
+			Name string `validate:"max"`
+		}{}, wantErr: `invalid argument "" of rule "max"`},
+		{name: "invalid argument", dto: &struct {
+			Name string `validate:"min=ten"`
+		}{}, wantErr: `invalid argument "ten" of rule "min"`},
+		{name: "unexpected argument", dto: &struct {
+			Name string `validate:"required=true"`
+		}{}, wantErr: `rule "required" takes no argument`},
+		{name: "email of a number", dto: &struct {
+			Name int `validate:"email"`
+		}{}, wantErr: `rule "email" on a int`},
+		{name: "embedded", dto: &struct {
+			LoginRequestDTO
+			Name string `validate:"maximum=3"`
+		}{}, wantErr: `unknown rule "maximum"`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRules(tt.dto)
+
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(),
+				tt.wantErr)) {
+				t.Errorf("CheckRules() error = %v; want %q", err, tt.wantErr)
+			}
+
+			// The same error is returned by Validate instead of panicking.
+			if verr := Validate(tt.dto); tt.wantErr != "" && (verr == nil || verr.Error() != err.Error()) {
+				t.Errorf("Validate() error = %v; want %v", verr, err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type embedded struct {
+		Pair string `json:"pair" validate:"required,max=3"`
+	}
+
+	type dto struct {
+		embedded
+
+		Email string  `json:"email" validate:"required,email"`
+		Name  string  `json:"name,omitempty" validate:"min=2,max=4"`
+		Count int     `validate:"min=1,max=10"`
+		Rate  float64 `json:"rate" validate:"max=1.5"`
+		Tags  []int   `json:"tags" validate:"max=2"`
+	}
+
+	valid := func() dto {
+		return dto{embedded: embedded{Pair: "abc"}, Email: "a@example.com", Name: "äöü", Count: 10, Rate: 1.5,
+			Tags: []int{1, 2}}
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(d *dto)
+		wantErr     error
+		wantDetails []FieldError
+	}{
+		{name: "valid", modify: func(*dto) {}},
+		{name: "optional rules of zero values skipped", modify: func(d *dto) { d.Name, d.Count, d.Rate = "", 0, 0 }},
+		{
+			name:        "required",
+			modify:      func(d *dto) { d.Pair = "" },
+			wantErr:     ErrInvalidPair,
+			wantDetails: []FieldError{{Field: "pair", Rule: "required", Message: "is required"}},
+		},
+		{
+			name:        "required of a field with other rules",
+			modify:      func(d *dto) { d.Email = "" },
+			wantErr:     ErrInvalidEmail,
+			wantDetails: []FieldError{{Field: "email", Rule: "required", Message: "is required"}},
+		},
+		{
+			name:        "email",
+			modify:      func(d *dto) { d.Email = "alice" },
+			wantErr:     ErrInvalidEmail,
+			wantDetails: []FieldError{{Field: "email", Rule: "syntax", Message: "email: invalid syntax"}},
+		},
+		{
+			name:        "min of a string in characters",
+			modify:      func(d *dto) { d.Name = "ä" },
+			wantErr:     ErrValidation,
+			wantDetails: []FieldError{{Field: "name", Rule: "min", Message: "must be at least 2"}},
+		},
+		{
+			name:        "max of a number",
+			modify:      func(d *dto) { d.Count = 11 },
+			wantErr:     ErrValidation,
+			wantDetails: []FieldError{{Field: "Count", Rule: "max", Message: "must be at most 10"}},
+		},
+		{
+			name:        "max of a float",
+			modify:      func(d *dto) { d.Rate = 1.51 },
+			wantErr:     ErrValidation,
+			wantDetails: []FieldError{{Field: "rate", Rule: "max", Message: "must be at most 1.5"}},
+		},
+		{
+			name:        "max of a slice",
+			modify:      func(d *dto) { d.Tags = []int{1, 2, 3} },
+			wantErr:     ErrValidation,
+			wantDetails: []FieldError{{Field: "tags", Rule: "max", Message: "must be at most 2"}},
+		},
+		{
+			name:    "every failure at once",
+			modify:  func(d *dto) { d.Pair, d.Email = "abcd", "" },
+			wantErr: ErrValidation,
+			wantDetails: []FieldError{
+				{Field: "pair", Rule: "max", Message: "must be at most 3"},
+				{Field: "email", Rule: "required", Message: "is required"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			d := valid()
+			tt.modify(&d)
+
+			err := Validate(&d)
+			checkErr(t, err, tt.wantErr)
+
+			var appErr *Error
+			if errors.As(err, &appErr) && !reflect.DeepEqual(appErr.Details, tt.wantDetails) {
+				t.Errorf("Validate() details = %+v; want %+v", appErr.Details, tt.wantDetails)
+			}
+		})
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type dto struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Token    string `json:"-"`
+		Count    int
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		limit       int64
+		want        dto
+		wantErr     error
+		wantDetails []FieldError
+	}{
+		{name: "valid", body: `{"email": "a@example.com", "password": "open1234"}`,
+			want: dto{Email: "a@example.com", Password: "open1234"}},
+		{name: "case insensitive names", body: `{"EMAIL": "a@example.com", "count": 2}`,
+			want: dto{Email: "a@example.com", Count: 2}},
+		{name: "trailing white space", body: "{}\n\n", want: dto{}},
+		{name: "body of the limit", body: `{"email": "a"}`, limit: 14, want: dto{Email: "a"}},
+		{name: "too large", body: `{"email": "ab"}`, limit: 14, wantErr: ErrRequestTooLarge},
+		{name: "malformed", body: `{"email": `, wantErr: ErrMalformedRequest},
+		{name: "empty", body: ``, wantErr: ErrMalformedRequest},
+		{
+			name: "not an object", body: `["email"]`,
+			wantErr:     ErrMalformedRequest,
+			wantDetails: []FieldError{{Rule: "type", Message: "must be of type services.dto"}},
+		},
+		{name: "trailing data", body: `{} {}`, wantErr: ErrMalformedRequest},
+		{
+			name: "unknown field", body: `{"email": "a@example.com", "name": "alice", "age": 3}`,
+			wantErr:     ErrMalformedRequest,
+			wantDetails: []FieldError{{Field: "age", Rule: "unknown", Message: "is not a known field"}},
+		},
+		{
+			name: "ignored field", body: `{"Token": "token"}`,
+			wantErr:     ErrMalformedRequest,
+			wantDetails: []FieldError{{Field: "Token", Rule: "unknown", Message: "is not a known field"}},
+		},
+		{
+			name: "wrong type", body: `{"email": 1}`,
+			wantErr:     ErrMalformedRequest,
+			wantDetails: []FieldError{{Field: "email", Rule: "type", Message: "must be of type string"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			var got dto
+
+			err := DecodeJSON(strings.NewReader(tt.body), tt.limit, &got)
+			checkErr(t, err, tt.wantErr)
+
+			if tt.wantErr == nil && got != tt.want {
+				t.Errorf("DecodeJSON(%s) = %+v; want %+v", tt.body, got, tt.want)
+			}
+
+			var appErr *Error
+			if errors.As(err, &appErr) && !reflect.DeepEqual(appErr.Details, tt.wantDetails) {
+				t.Errorf("DecodeJSON(%s) details = %+v; want %+v", tt.body, appErr.Details, tt.wantDetails)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	apitrace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ServiceName identifies the service in the exported spans.
+	ServiceName = "alpha-flow"
+
+	instrumentationName = "github.com/maknahar/alpha-flow"
+)
+
+// Exporters spans can be sent to.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Setup installs the W3C trace context propagator and a tracer provider sending a sampleRatio share of the traces
+// to exporter. endpoint is the address of the OTLP collector. With ExporterNone traces are still propagated, but
+// no spans are recorded. The returned function flushes the pending spans and stops the exporter.
+func Setup(ctx context.Context, exporter, endpoint string, sampleRatio float64) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{},
+		propagation.Baggage{}))
+
+	var (
+		exp trace.SpanExporter
+		err error
+	)
+
+	switch exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exp, err = stdout.NewExporter(stdout.WithWriter(os.Stdout), stdout.WithoutMetricExport())
+	case ExporterOTLP:
+		exp, err = otlp.NewExporter(ctx, otlpgrpc.NewDriver(otlpgrpc.WithInsecure(), otlpgrpc.WithEndpoint(endpoint)))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w; Unable to create trace exporter", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithConfig(sdktrace.Config{
+			DefaultSampler: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio)),
+		}),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.ServiceNameKey.String(ServiceName))),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named name as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, opts ...apitrace.SpanOption) (context.Context, apitrace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End records err, if any, on span and ends it.
+func End(span apitrace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// Middleware continues the trace of the incoming trace context headers, if any, with a span for the request named
+// after the chi route pattern it matched. Only 5xx responses mark the span as failed.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), r.Header)
+
+		ctx, span := Start(ctx, "HTTP "+r.Method, apitrace.WithSpanKind(apitrace.SpanKindServer),
+			apitrace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(ServiceName, "", r)...))
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName(r.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(semconv.HTTPRouteKey.String(rctx.RoutePattern()))
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+
+		// Client errors are the client's fault, not a failure of the request.
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// Transport records a client span for every call made through next and propagates the trace context to the callee.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(next)
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	apitrace "go.opentelemetry.io/otel/trace"
+)
+
+// spanRecorder keeps the spans ended, in order.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*trace.SpanSnapshot
+}
+
+func (r *spanRecorder) ExportSpans(_ context.Context, spans []*trace.SpanSnapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans = append(r.spans, spans...)
+
+	return nil
+}
+
+func (r *spanRecorder) Shutdown(context.Context) error {
+	return nil
+}
+
+// record installs the propagator of Setup and a tracer provider recording every span, restored at the end of t.
+func record(t *testing.T) *spanRecorder {
+	t.Helper()
+
+	if _, err := Setup(context.Background(), ExporterNone, "", 1); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	recorder := &spanRecorder{}
+	previous := otel.GetTracerProvider()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(recorder),
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.AlwaysSample()})))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
+
+func TestMiddleware(t *testing.T) {
+	const (
+		traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentID = "00f067aa0ba902b7"
+	)
+
+	recorder := record(t)
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(recorder.spans) != 1 {
+		t.Fatalf("recorded %d spans; want 1", len(recorder.spans))
+	}
+
+	span := recorder.spans[0]
+	if span.Name != "GET /users/{id}" || span.SpanKind != apitrace.SpanKindServer {
+		t.Errorf("span = %s of kind %v; want GET /users/{id} of kind server", span.Name, span.SpanKind)
+	}
+
+	// The trace of the caller is continued.
+	if span.SpanContext.TraceID.String() != traceID || span.ParentSpanID.String() != parentID ||
+		!span.HasRemoteParent {
+		t.Errorf("span trace = %s, parent %s; want %s, remote parent %s", span.SpanContext.TraceID,
+			span.ParentSpanID, traceID, parentID)
+	}
+
+	if span.StatusCode != codes.Error {
+		t.Errorf("span status = %v; want an error for a 500", span.StatusCode)
+	}
+}
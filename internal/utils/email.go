@@ -1,31 +1,246 @@
 package utils
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
 	"net"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
+)
+
+// mxCacheSize is the number of domains cached by an MXCheck, above which the expired ones are forgotten and, while
+// none is, no other domain is cached.
+const mxCacheSize = 1024
+
+var (
+	ErrEmailSyntax     = errors.New("email: invalid syntax")
+	ErrEmailNoMXRecord = errors.New("email: domain does not accept mail")
+	ErrEmailDisposable = errors.New("email: disposable domain")
+	ErrEmailDomainDeny = errors.New("email: domain not allowed")
 )
 
 // Conform to W3C standard
 var emailRegex = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
-// isEmailValid checks if the email provided passes the required structure
-// and length test. It also checks the domain has a valid MX record.
-func IsEmailValid(e string) bool {
+// EmailCheck is a single rule an email address has to satisfy. It receives the lower cased domain of an address
+// which already passed the syntax check.
+type EmailCheck interface {
+	Check(ctx context.Context, domain string) error
+}
+
+// EmailValidator checks the structure and length of an email address followed by every configured EmailCheck.
+type EmailValidator struct {
+	checks []EmailCheck
+}
+
+// NewEmailValidator returns a validator running checks in order. Without checks only the syntax is validated.
+func NewEmailValidator(checks ...EmailCheck) *EmailValidator {
+	return &EmailValidator{checks: checks}
+}
+
+// Validate returns the error of the first check e fails.
+func (v *EmailValidator) Validate(ctx context.Context, e string) error {
+	if !IsEmailSyntaxValid(e) {
+		return ErrEmailSyntax
+	}
+
+	domain := strings.ToLower(e[strings.LastIndex(e, "@")+1:])
+
+	for _, c := range v.checks {
+		if err := c.Check(ctx, domain); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsValid reports whether e passes every check.
+func (v *EmailValidator) IsValid(ctx context.Context, e string) bool {
+	return v.Validate(ctx, e) == nil
+}
+
+// IsEmailSyntaxValid checks if the email provided passes the required structure and length test. Dotless domains,
+// while allowed by the standard, are not routable on the internet and rejected.
+func IsEmailSyntaxValid(e string) bool {
 	if len(e) < 3 || len(e) > 254 {
 		return false
 	}
 
-	if !emailRegex.MatchString(e) {
-		return false
+	return emailRegex.MatchString(e) && strings.Contains(e[strings.LastIndex(e, "@"):], ".")
+}
+
+// MXResolver looks up the MX records of a domain. *net.Resolver satisfies it.
+type MXResolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+type mxEntry struct {
+	ok      bool
+	expires time.Time
+}
+
+// MXCheck requires the domain to have at least one MX record. Results are cached for TTL, of up to mxCacheSize
+// domains. Temporary DNS failures are not cached and let the address pass, so a flaky resolver does not lock users
+// out.
+type MXCheck struct {
+	resolver MXResolver
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]mxEntry
+}
+
+// NewMXCheck returns an MXCheck using resolver, or net.DefaultResolver if nil.
+func NewMXCheck(resolver MXResolver, ttl time.Duration) *MXCheck {
+	if resolver == nil {
+		resolver = net.DefaultResolver
 	}
 
-	parts := strings.Split(e, "@")
+	return &MXCheck{resolver: resolver, ttl: ttl, now: time.Now, cache: make(map[string]mxEntry)}
+}
 
-	mx, err := net.LookupMX(parts[1])
-	if err != nil || len(mx) == 0 {
-		return false
+func (c *MXCheck) Check(ctx context.Context, domain string) error {
+	c.mu.Lock()
+	entry, found := c.cache[domain]
+	c.mu.Unlock()
+
+	if !found || c.now().After(entry.expires) {
+		mx, err := c.resolver.LookupMX(ctx, domain)
+
+		var dnsErr *net.DNSError
+		if err != nil && (!errors.As(err, &dnsErr) || dnsErr.Temporary()) {
+			return nil
+		}
+
+		entry = mxEntry{ok: err == nil && len(mx) > 0, expires: c.now().Add(c.ttl)}
+		c.store(domain, entry)
+	}
+
+	if !entry.ok {
+		return ErrEmailNoMXRecord
+	}
+
+	return nil
+}
+
+// store caches entry of domain. The domains are given by the users, so the cache is bounded: once full, the expired
+// entries are forgotten, and the entry is dropped if none is.
+func (c *MXCheck) store(domain string, entry mxEntry) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, found := c.cache[domain]; !found && len(c.cache) >= mxCacheSize {
+		for d, e := range c.cache {
+			if now.After(e.expires) {
+				delete(c.cache, d)
+			}
+		}
+
+		if len(c.cache) >= mxCacheSize {
+			return
+		}
+	}
+
+	c.cache[domain] = entry
+}
+
+// DomainSet is a set of domains. A domain matches if it or any of its parent domains is in the set.
+type DomainSet map[string]struct{}
+
+// NewDomainSet returns a set of domains, ignoring blank entries.
+func NewDomainSet(domains ...string) DomainSet {
+	s := make(DomainSet)
+
+	for _, d := range domains {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			s[d] = struct{}{}
+		}
+	}
+
+	return s
+}
+
+// ReadDomainSet reads one domain per line. Blank lines and lines starting with # are ignored.
+func ReadDomainSet(r io.Reader) (DomainSet, error) {
+	var domains []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); !strings.HasPrefix(line, "#") {
+			domains = append(domains, line)
+		}
+	}
+
+	return NewDomainSet(domains...), scanner.Err()
+}
+
+// Contains reports whether domain or one of its parent domains is in the set.
+func (s DomainSet) Contains(domain string) bool {
+	for {
+		if _, ok := s[domain]; ok {
+			return true
+		}
+
+		i := strings.Index(domain, ".")
+		if i < 0 {
+			return false
+		}
+
+		domain = domain[i+1:]
+	}
+}
+
+// DisposableCheck rejects domains of disposable email providers.
+type DisposableCheck struct {
+	Domains DomainSet
+}
+
+func (c DisposableCheck) Check(_ context.Context, domain string) error {
+	if c.Domains.Contains(domain) {
+		return ErrEmailDisposable
 	}
 
-	return true
+	return nil
+}
+
+// DomainListCheck rejects denied domains and, if Allowed is not empty, every domain not in it.
+type DomainListCheck struct {
+	Allowed DomainSet
+	Denied  DomainSet
 }
+
+func (c DomainListCheck) Check(_ context.Context, domain string) error {
+	if c.Denied.Contains(domain) || (len(c.Allowed) > 0 && !c.Allowed.Contains(domain)) {
+		return ErrEmailDomainDeny
+	}
+
+	return nil
+}
+
+// DisposableDomains is a built-in list of well known disposable email providers.
+//
+//nolint:gochecknoglobals
+var DisposableDomains = NewDomainSet(
+	"10minutemail.com",
+	"discard.email",
+	"dispostable.com",
+	"fakeinbox.com",
+	"getnada.com",
+	"guerrillamail.com",
+	"mailinator.com",
+	"maildrop.cc",
+	"mintemail.com",
+	"sharklasers.com",
+	"temp-mail.org",
+	"throwawaymail.com",
+	"trashmail.com",
+	"yopmail.com",
+)
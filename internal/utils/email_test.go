@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers every lookup with mx and err, counting the lookups of each domain.
+type fakeResolver struct {
+	mx      []*net.MX
+	err     error
+	lookups map[string]int
+}
+
+func (r *fakeResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	r.lookups[name]++
+	return r.mx, r.err
+}
+
+// recordingCheck records the domains checked and fails with err.
+type recordingCheck struct {
+	domains []string
+	err     error
+}
+
+func (c *recordingCheck) Check(_ context.Context, domain string) error {
+	c.domains = append(c.domains, domain)
+	return c.err
+}
+
+func TestEmailValidator(t *testing.T) {
+	first := &recordingCheck{}
+	second := &recordingCheck{err: ErrEmailDisposable}
+	third := &recordingCheck{}
+
+	v := NewEmailValidator(first, second, third)
+
+	tests := []struct {
+		email   string
+		wantErr error
+	}{
+		{email: "alice@Example.COM", wantErr: ErrEmailDisposable},
+		{email: "alice@localhost", wantErr: ErrEmailSyntax},
+		{email: "alice", wantErr: ErrEmailSyntax},
+		{email: "alice@example..com", wantErr: ErrEmailSyntax},
+		{email: "a@" + strings.Repeat("a", 250) + ".com", wantErr: ErrEmailSyntax},
+	}
+
+	for _, tt := range tests {
+		if err := v.Validate(context.Background(), tt.email); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Validate(%q) error = %v; want %v", tt.email, err, tt.wantErr)
+		}
+	}
+
+	// Only the address passing the syntax check reaches the checks, with its domain lower cased, up to the failing
+	// one.
+	if len(first.domains) != 1 || first.domains[0] != "example.com" || len(second.domains) != 1 ||
+		third.domains != nil {
+		t.Errorf("domains checked = %q, %q, %q; want example.com up to the second check", first.domains,
+			second.domains, third.domains)
+	}
+
+	if !NewEmailValidator().IsValid(context.Background(), "alice@example.com") {
+		t.Error("IsValid(alice@example.com) = false without checks; want true")
+	}
+}
+
+func TestMXCheck(t *testing.T) {
+	const ttl = time.Minute
+
+	tests := []struct {
+		name string
+		mx   []*net.MX
+		err  error
+
+		wantErr error
+
+		// wantCached is set when the result is cached, so that the second check does not look the domain up.
+		wantCached bool
+	}{
+		{name: "mx records", mx: []*net.MX{{Host: "mx.example.com.", Pref: 10}}, wantCached: true},
+		{name: "no mx records", wantErr: ErrEmailNoMXRecord, wantCached: true},
+		{
+			name: "unknown domain", err: &net.DNSError{Err: "no such host", IsNotFound: true},
+			wantErr: ErrEmailNoMXRecord, wantCached: true,
+		},
+		{name: "temporary failure", err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}},
+		{name: "timeout", err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}},
+		{name: "other failure", err: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &fakeResolver{mx: tt.mx, err: tt.err, lookups: make(map[string]int)}
+			check := NewMXCheck(resolver, ttl)
+
+			now := time.Now()
+			check.now = func() time.Time { return now }
+
+			for i := 0; i < 2; i++ {
+				if err := check.Check(context.Background(), "example.com"); !errors.Is(err, tt.wantErr) {
+					t.Errorf("Check() #%d error = %v; want %v", i+1, err, tt.wantErr)
+				}
+			}
+
+			wantLookups := 2
+			if tt.wantCached {
+				wantLookups = 1
+			}
+
+			if got := resolver.lookups["example.com"]; got != wantLookups {
+				t.Errorf("lookups = %d; want %d", got, wantLookups)
+			}
+
+			// Once expired, the domain is looked up again.
+			now = now.Add(ttl + time.Second)
+
+			if err := check.Check(context.Background(), "example.com"); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Check() after TTL error = %v; want %v", err, tt.wantErr)
+			}
+
+			if got := resolver.lookups["example.com"]; got != wantLookups+1 {
+				t.Errorf("lookups after TTL = %d; want %d", got, wantLookups+1)
+			}
+		})
+	}
+}
+
+func TestMXCheckPerDomain(t *testing.T) {
+	resolver := &fakeResolver{mx: []*net.MX{{Host: "mx.example.com."}}, lookups: make(map[string]int)}
+	check := NewMXCheck(resolver, time.Minute)
+
+	for _, domain := range []string{"example.com", "example.org", "example.com"} {
+		if err := check.Check(context.Background(), domain); err != nil {
+			t.Errorf("Check(%q) error = %v", domain, err)
+		}
+	}
+
+	if resolver.lookups["example.com"] != 1 || resolver.lookups["example.org"] != 1 {
+		t.Errorf("lookups = %v; want one per domain", resolver.lookups)
+	}
+}
+
+func TestMXCheckCacheSize(t *testing.T) {
+	resolver := &fakeResolver{mx: []*net.MX{{Host: "mx.example.com."}}, lookups: make(map[string]int)}
+	check := NewMXCheck(resolver, time.Minute)
+
+	now := time.Now()
+	check.now = func() time.Time { return now }
+
+	for i := 0; i < mxCacheSize+10; i++ {
+		if err := check.Check(context.Background(), fmt.Sprintf("example%d.com", i)); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+
+	if len(check.cache) != mxCacheSize {
+		t.Errorf("cached domains = %d; want at most %d", len(check.cache), mxCacheSize)
+	}
+
+	// Once expired, the domains cached are forgotten to make room for the next one.
+	now = now.Add(time.Minute + time.Second)
+
+	if err := check.Check(context.Background(), "example.org"); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if _, found := check.cache["example.org"]; !found || len(check.cache) != 1 {
+		t.Errorf("cached domains = %d; want only example.org after the others expired", len(check.cache))
+	}
+}
+
+func TestDomainSet(t *testing.T) {
+	set, err := ReadDomainSet(strings.NewReader("# disposable\nMailinator.com\n\n  yopmail.com  \n#example.com\n"))
+	if err != nil {
+		t.Fatalf("ReadDomainSet() error = %v", err)
+	}
+
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"mailinator.com", true},
+		{"eu.mailinator.com", true},
+		{"yopmail.com", true},
+		{"notmailinator.com", false},
+		{"com", false},
+		{"example.com", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := set.Contains(tt.domain); got != tt.want {
+			t.Errorf("Contains(%q) = %v; want %v", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestDisposableCheck(t *testing.T) {
+	check := DisposableCheck{Domains: DisposableDomains}
+
+	if err := check.Check(context.Background(), "mailinator.com"); !errors.Is(err, ErrEmailDisposable) {
+		t.Errorf("Check(mailinator.com) error = %v; want ErrEmailDisposable", err)
+	}
+
+	if err := check.Check(context.Background(), "example.com"); err != nil {
+		t.Errorf("Check(example.com) error = %v; want nil", err)
+	}
+}
+
+func TestDomainListCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   DomainListCheck
+		domain  string
+		wantErr error
+	}{
+		{name: "no lists", domain: "example.com"},
+		{name: "denied", check: DomainListCheck{Denied: NewDomainSet("example.com")}, domain: "example.com",
+			wantErr: ErrEmailDomainDeny},
+		{name: "subdomain of denied", check: DomainListCheck{Denied: NewDomainSet("example.com")},
+			domain: "mail.example.com", wantErr: ErrEmailDomainDeny},
+		{name: "not denied", check: DomainListCheck{Denied: NewDomainSet("example.com")}, domain: "example.org"},
+		{name: "allowed", check: DomainListCheck{Allowed: NewDomainSet("example.com")}, domain: "example.com"},
+		{name: "subdomain of allowed", check: DomainListCheck{Allowed: NewDomainSet("example.com")},
+			domain: "mail.example.com"},
+		{name: "not allowed", check: DomainListCheck{Allowed: NewDomainSet("example.com")}, domain: "example.org",
+			wantErr: ErrEmailDomainDeny},
+		{
+			name:    "denied over allowed",
+			check:   DomainListCheck{Allowed: NewDomainSet("example.com"), Denied: NewDomainSet("mail.example.com")},
+			domain:  "eu.mail.example.com",
+			wantErr: ErrEmailDomainDeny,
+		},
+		{
+			name:   "allowed beside denied",
+			check:  DomainListCheck{Allowed: NewDomainSet("example.com"), Denied: NewDomainSet("mail.example.com")},
+			domain: "www.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.check.Check(context.Background(), tt.domain); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Check(%q) error = %v; want %v", tt.domain, err, tt.wantErr)
+			}
+		})
+	}
+}
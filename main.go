@@ -3,7 +3,8 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
+	"flag"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,18 +14,82 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/maknahar/alpha-flow/internal/configs"
+	"github.com/maknahar/alpha-flow/internal/db"
+	"github.com/maknahar/alpha-flow/internal/health"
 	"github.com/maknahar/alpha-flow/internal/routes"
+	"github.com/maknahar/alpha-flow/internal/rpc"
+	"github.com/maknahar/alpha-flow/internal/services"
+	"github.com/maknahar/alpha-flow/internal/tracing"
 )
 
 func main() {
-	config, err := configs.Configure(context.Background())
+	settings, flags, err := configs.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		logrus.WithError(err).Fatal("Unable to start the application. Invalid configuration.")
+	}
+
+	if flags.PrintConfig {
+		if err = settings.Print(os.Stdout); err != nil {
+			logrus.WithError(err).Fatal("Unable to print the configuration.")
+		}
+
+		return
+	}
+
+	config, err := configs.Configure(context.Background(), settings)
 	if err != nil {
 		logrus.WithError(err).Panic("Unable to start the application. Error in configuration.")
 	}
 
+	if len(flags.Args) > 0 {
+		runCommand(config, flags.Args)
+		return
+	}
+
+	if err = migrateOnStart(config); err != nil {
+		config.Logger.WithError(err).Panic("Unable to start the application. Error in migrations.")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), config.TracingExporter, config.TracingEndpoint,
+		config.TracingSampleRatio)
+	if err != nil {
+		config.Logger.WithError(err).Panic("Unable to start the application. Error in tracing setup.")
+	}
+
+	// background is cancelled on shutdown, stopping the work running alongside the servers.
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	checker := health.New()
+
+	// The memory storage backend has no database to check.
+	if config.DB != nil {
+		checker.Add("database", config.ReadinessCheckTimeout, true, config.DB.PingContext)
+	}
+
+	if config.Replicas != nil {
+		// The primary serves the reads while no replica is healthy, so the service stays in rotation without them.
+		checker.Add("database_replicas", config.ReadinessCheckTimeout, false, config.Replicas.Ping)
+
+		go config.Replicas.Watch(background, config.ReplicaCheckInterval, config.ReadinessCheckTimeout)
+	}
+
+	if config.Database != nil {
+		checker.Add("migrations", config.ReadinessCheckTimeout, true, func(ctx context.Context) error {
+			return config.Database.CheckMigrations(ctx, config.DB, "")
+		})
+	}
+
+	// Only the rates and pairs depend on the pair provider, so the service stays in rotation without it.
+	checker.Add("pair_provider", config.ReadinessCheckTimeout, false, services.PingPairProvider(config))
+
 	server := &http.Server{
 		Addr:    config.Host,
-		Handler: routes.Get(config),
+		Handler: routes.Get(config, checker),
 	}
 
 	config.Logger.Info("Starting the service on ", config.Host)
@@ -35,11 +100,40 @@ func main() {
 		}
 	}()
 
+	grpcServer := rpc.NewServer(config)
+
+	grpcListener, err := net.Listen("tcp", config.GRPCHost)
+	if err != nil {
+		config.Logger.WithError(err).Panic("Unable to listen on gRPC host")
+	}
+
+	config.Logger.Info("Starting the gRPC service on ", config.GRPCHost)
+
+	go func() {
+		if sErr := grpcServer.Serve(grpcListener); sErr != nil {
+			config.Logger.Fatal(sErr)
+		}
+	}()
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	go func() {
+		for range reload {
+			reloadConfig(config)
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	config.Logger.Info("Service is shutting down")
 
+	// Keep serving while reported as not ready, so that no new traffic is routed to the service once it stops.
+	checker.Shutdown()
+	grpcServer.Drain()
+	time.Sleep(config.ShutdownGracePeriod)
+
 	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
@@ -47,5 +141,80 @@ func main() {
 		config.Logger.Errorln("Error during HTTP server shutdown:", err)
 	}
 
-	log.Println("Service Stopped")
+	grpcServer.Shutdown(timeoutCtx)
+
+	stopBackground()
+
+	if config.Replicas != nil {
+		if err = config.Replicas.Close(); err != nil {
+			config.Logger.Errorln("Error closing the database replicas:", err)
+		}
+	}
+
+	if err = shutdownTracing(timeoutCtx); err != nil {
+		config.Logger.Errorln("Error during trace exporter shutdown:", err)
+	}
+
+	config.Logger.Info("Service stopped")
+}
+
+// reloadConfig reads the configuration again and applies the settings that can be changed while serving. An invalid
+// configuration is rejected as a whole and the current one is kept.
+func reloadConfig(config *configs.Conf) {
+	settings, _, err := configs.Load(os.Args[1:])
+	if err != nil {
+		config.Logger.WithError(err).Error("Configuration reload rejected")
+		return
+	}
+
+	changed, restart, err := config.Reload(settings)
+	if err != nil {
+		config.Logger.WithError(err).Error("Configuration reload rejected")
+		return
+	}
+
+	if len(restart) > 0 {
+		config.Logger.WithField("settings", restart).Warn("Settings changed but only applied after a restart")
+	}
+
+	config.Logger.WithField("changed", changed).Info("Configuration reloaded")
+}
+
+// runCommand runs the command given on the command line instead of starting the service.
+func runCommand(config *configs.Conf, args []string) {
+	if args[0] != "migrate" {
+		config.Logger.Fatalf("Unknown command %q. Usage: %s", args[0], db.MigrateUsage)
+	}
+
+	if config.Database == nil {
+		config.Logger.Fatal("The migrate command only applies to the postgres storage backend. The sqlite one is " +
+			"migrated on start and the memory one has no schema.")
+	}
+
+	migrations, err := config.Database.Migrations(config.DSN, "")
+	if err == nil {
+		err = db.RunMigrateCommand(migrations, args[1:], os.Stdout)
+		migrations.Close()
+	}
+
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		config.Logger.WithError(err).Fatal("Unable to run the migrate command")
+	}
+}
+
+// migrateOnStart applies or verifies the Postgres migrations as set by config.MigrateOnStart. The other storage
+// backends are ready once configured.
+func migrateOnStart(config *configs.Conf) error {
+	if config.Database == nil {
+		return nil
+	}
+
+	switch config.MigrateOnStart {
+	case configs.MigrateOnStartMigrate:
+		return config.Database.Migrate(config.DSN, "")
+	case configs.MigrateOnStartVerify:
+		return config.Database.CheckMigrations(context.Background(), config.DB, "")
+	default:
+		return nil
+	}
 }
@@ -1,6 +1,6 @@
 #!/bin/bash
 
-server="http://localhost:9001"
+server="http://localhost:9001/v1"
 opts="--header 'Content-Type: application/json' --silent"
 
 now=$(date +%s%3N)
@@ -43,7 +43,7 @@ printf "Signup: $signup_cmd\n"
 signup_res=$(eval $signup_cmd)
 echo $signup_res
 
-if [[ "$signup_res" != *"\"error\":"* ]]; then
+if [[ "$signup_res" != *"\"code\":"* ]]; then
   printf "FAIL: Should not allow signup with week password\n"
   exit 1
 fi
@@ -55,7 +55,7 @@ printf "Signup: $signup_cmd\n"
 signup_res=$(eval $signup_cmd)
 echo $signup_res
 
-if [[ "$signup_res" != *"\"error\":"* ]]; then
+if [[ "$signup_res" != *"\"code\":"* ]]; then
   printf "FAIL: Should not allow signup with invalid email\n"
   exit 1
 fi
@@ -64,7 +64,7 @@ fi
 signup_res=$(eval $signup_cmd)
 echo $signup_res
 
-if [[ "$signup_res" != *"\"error\":"* ]]; then
+if [[ "$signup_res" != *"\"code\":"* ]]; then
   printf "FAIL: Should not allow signup for already signed up user\n"
   exit 1
 fi
@@ -173,7 +173,7 @@ printf "Update with bad email: $fail_patch_email_cmd\n"
 fail_patch_email_res=$(eval $fail_patch_email_cmd)
 echo $fail_patch_email_res
 
-if [[ "$fail_patch_email_res" != *"\"error\":\"validation error: email\""* ]]; then
+if [[ "$fail_patch_email_res" != *"\"code\":\"invalid_email\""* ]]; then
   printf "FAIL: Should return a validation error for email field\n"
   exit 1
 fi
@@ -210,7 +210,7 @@ printf "Update with bad password: $fail_patch_password_cmd\n"
 fail_patch_password_res=$(eval $fail_patch_password_cmd)
 echo $fail_patch_password_res
 
-if [[ "$fail_patch_password_res" != *"\"error\":\"validation error: password\""* ]]; then
+if [[ "$fail_patch_password_res" != *"\"code\":\"invalid_password\""* ]]; then
   printf "FAIL: Should return a validation error for password field\n"
   exit 1
 fi
@@ -226,7 +226,7 @@ printf "Get secret with bad token: $fail_get_secret_cmd\n"
 fail_get_secret_res=$(eval $fail_get_secret_cmd)
 echo $fail_get_secret_res
 
-if [[ "$fail_get_secret_res" != *"\"error\":\"token invalid\""* ]]; then
+if [[ "$fail_get_secret_res" != *"\"code\":\"invalid_token\""* ]]; then
   printf "FAIL: Should return a token invalid error\n"
   exit 1
 fi
@@ -0,0 +1,11 @@
+//go:build tools
+// +build tools
+
+// The code generators of the repository, imported so that go.mod pins their versions. They are run by go generate.
+package main
+
+import (
+	_ "github.com/golang/mock/mockgen"
+	_ "google.golang.org/grpc/cmd/protoc-gen-go-grpc"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go"
+)